@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"my-app/platform/artifact"
+)
+
+const bundleUsage = `usage: artifact-gateway bundle <command> [flags]
+
+commands:
+  keygen --out NAME                                write NAME.key and NAME.pub (ed25519, PEM)
+  create [--repo DIR] --key FILE --out FILE        pack api/, flows/, data/, schemas/ and migrations/
+  verify --keys FILE[,FILE] [--json] BUNDLE        check the signature and digests of a bundle
+`
+
+// bundleCommand creates, verifies and signs single-file contract bundles.
+func bundleCommand(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, bundleUsage)
+		return 2
+	}
+	fs := flag.NewFlagSet("bundle "+args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { fmt.Fprint(stderr, bundleUsage) }
+	switch args[0] {
+	case "keygen":
+		out := fs.String("out", "", "path prefix of the key files")
+		if err := fs.Parse(args[1:]); err != nil || *out == "" || fs.NArg() > 0 {
+			fs.Usage()
+			return 2
+		}
+		return bundleKeygen(*out, stdout, stderr)
+	case "create":
+		repoPath := fs.String("repo", envOr("REPO_PATH", "./repo"), "contract repo to pack")
+		keyFile := fs.String("key", "", "ed25519 private key (PEM)")
+		out := fs.String("out", "", "bundle file to write")
+		if err := fs.Parse(args[1:]); err != nil || *keyFile == "" || *out == "" || fs.NArg() > 0 {
+			fs.Usage()
+			return 2
+		}
+		return bundleCreate(*repoPath, *keyFile, *out, stdout, stderr)
+	case "verify":
+		keyFiles := fs.String("keys", os.Getenv("ARTIFACT_BUNDLE_KEYS"), "comma-separated public key files (PEM)")
+		asJSON := fs.Bool("json", false, "print the manifest as JSON")
+		if err := fs.Parse(args[1:]); err != nil || fs.NArg() != 1 {
+			fs.Usage()
+			return 2
+		}
+		keys, err := readPublicKeys(splitList(*keyFiles))
+		if err != nil {
+			fmt.Fprintln(stderr, "keys:", err)
+			return 2
+		}
+		data, err := os.ReadFile(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintln(stderr, "bundle:", err)
+			return 2
+		}
+		b, err := artifact.OpenBundle(bytes.NewReader(data), keys)
+		if err != nil {
+			fmt.Fprintln(stderr, "verify:", err)
+			return 1
+		}
+		return printManifest(stdout, b, *asJSON)
+	}
+	fmt.Fprintf(stderr, "unknown bundle command %q\n\n%s", args[0], bundleUsage)
+	return 2
+}
+
+func bundleKeygen(prefix string, stdout, stderr io.Writer) int {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		fmt.Fprintln(stderr, "keygen:", err)
+		return 1
+	}
+	privPEM, pubPEM, err := artifact.MarshalKeyPair(pub, priv)
+	if err != nil {
+		fmt.Fprintln(stderr, "keygen:", err)
+		return 1
+	}
+	if err := os.WriteFile(prefix+".key", privPEM, 0o600); err != nil {
+		fmt.Fprintln(stderr, "keygen:", err)
+		return 1
+	}
+	if err := os.WriteFile(prefix+".pub", pubPEM, 0o644); err != nil {
+		fmt.Fprintln(stderr, "keygen:", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "wrote %s.key and %s.pub (key id %s)\n", prefix, prefix, artifact.KeyID(pub))
+	return 0
+}
+
+func bundleCreate(repoPath, keyFile, out string, stdout, stderr io.Writer) int {
+	pemBytes, err := os.ReadFile(keyFile)
+	if err != nil {
+		fmt.Fprintln(stderr, "key:", err)
+		return 2
+	}
+	key, err := artifact.ParsePrivateKey(pemBytes)
+	if err != nil {
+		fmt.Fprintln(stderr, "key:", err)
+		return 2
+	}
+	var buf bytes.Buffer
+	manifest, err := artifact.WriteBundle(&buf, repoPath, key, time.Now())
+	if err != nil {
+		fmt.Fprintln(stderr, "bundle:", err)
+		return 1
+	}
+	if err := os.WriteFile(out, buf.Bytes(), 0o644); err != nil {
+		fmt.Fprintln(stderr, "bundle:", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "wrote %s: %d files, signed by key %s\n", out, len(manifest.Files), artifact.KeyID(key.Public().(ed25519.PublicKey)))
+	return 0
+}
+
+func printManifest(w io.Writer, b *artifact.Bundle, asJSON bool) int {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(map[string]any{"keyId": b.KeyID, "manifest": b.Manifest}); err != nil {
+			return 1
+		}
+		return 0
+	}
+	names := make([]string, 0, len(b.Manifest.Files))
+	for name := range b.Manifest.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(w, "verified: signed by key %s, created %s\n", b.KeyID, b.Manifest.Created.Format(time.RFC3339))
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s  %s\n", b.Manifest.Files[name], name)
+	}
+	return 0
+}
+
+// readPublicKeys collects the keys of every file.
+func readPublicKeys(files []string) ([]ed25519.PublicKey, error) {
+	var keys []ed25519.PublicKey
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		k, err := artifact.ParsePublicKeys(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		keys = append(keys, k...)
+	}
+	return keys, nil
+}
+
+// loadBundle verifies the configured bundle, or the one embedded at build
+// time, and unpacks it into cfg.BundleDir, which then becomes the served
+// repo. It does nothing when there is no bundle.
+func loadBundle(cfg *serveConfig) (*artifact.Bundle, error) {
+	var (
+		data []byte
+		err  error
+	)
+	switch {
+	case cfg.Bundle != "":
+		if data, err = os.ReadFile(cfg.Bundle); err != nil {
+			return nil, err
+		}
+	case len(embeddedBundle) > 0:
+		data = embeddedBundle
+	default:
+		return nil, nil
+	}
+	keys, err := readPublicKeys(cfg.BundleKeys)
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddedBundleKeys) > 0 {
+		embedded, err := artifact.ParsePublicKeys(embeddedBundleKeys)
+		if err != nil {
+			return nil, fmt.Errorf("embedded keys: %w", err)
+		}
+		keys = append(keys, embedded...)
+	}
+	b, err := artifact.OpenBundle(bytes.NewReader(data), keys)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.Extract(cfg.BundleDir); err != nil {
+		return nil, err
+	}
+	cfg.Repo = cfg.BundleDir
+	return b, nil
+}
@@ -0,0 +1,18 @@
+//go:build embedbundle
+
+package main
+
+import _ "embed"
+
+// Built with -tags embedbundle, the binary carries its contract bundle and
+// the public keys that verify it, so it needs no repo on disk:
+//
+//	artifact-gateway bundle create --key release.key --out cmd/artifact-gateway/embedded/bundle.tar.gz
+//	cp release.pub cmd/artifact-gateway/embedded/keys.pem
+//	go build -tags embedbundle ./cmd/artifact-gateway
+var (
+	//go:embed embedded/bundle.tar.gz
+	embeddedBundle []byte
+	//go:embed embedded/keys.pem
+	embeddedBundleKeys []byte
+)
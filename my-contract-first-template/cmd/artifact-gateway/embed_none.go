@@ -0,0 +1,9 @@
+//go:build !embedbundle
+
+package main
+
+// Without the embedbundle tag the gateway serves a repo or bundle from disk.
+var (
+	embeddedBundle     []byte
+	embeddedBundleKeys []byte
+)
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"my-app/platform/artifact"
+)
+
+// gatewayConfig is everything needed to serve one contract repo. In
+// multi-tenant mode every tenant gets its own config, registry, executor and
+// dataset state.
+type gatewayConfig struct {
+	repoPath      string
+	basePath      string
+	adminToken    string
+	repoToken     string
+	browseDirs    []string
+	recordDir     string
+	debug         bool
+	traceBuffer   int
+	changeLog     int
+	faults        bool
+	faultSeed     int64
+	enforceSunset bool
+	freezeTime    *time.Time
+	idSeed        *int64
+	requestOpts   artifact.RequestOptions
+	logger        *slog.Logger
+	redactor      *artifact.Redactor
+	registerer    prometheus.Registerer
+}
+
+// gateway is the router of one repo together with the background workers
+// that have to be stopped with it.
+type gateway struct {
+	http.Handler
+	repoPath  string
+	changes   *artifact.ChangeFeed
+	events    *artifact.EventBus
+	scheduler *artifact.Scheduler
+}
+
+// ready reports whether the repo's registry can still be loaded.
+func (g *gateway) ready() error {
+	_, err := artifact.LoadRegistry(filepath.Join(g.repoPath, "api", "index.json"))
+	return err
+}
+
+// closeStreams ends open SSE streams, which would otherwise keep a graceful
+// shutdown waiting until its deadline.
+func (g *gateway) closeStreams() { g.changes.Close() }
+
+// stop waits for running scheduled jobs and event deliveries. Call it once
+// in-flight requests have drained.
+func (g *gateway) stop() {
+	g.scheduler.Stop()
+	g.events.Stop()
+}
+
+// newGateway migrates the repo's datasets and builds the router serving its
+// endpoints, /repo browser and /_admin API.
+func newGateway(cfg gatewayConfig) (*gateway, error) {
+	logger := cfg.logger
+	metrics := artifact.NewMetrics(cfg.registerer)
+	changes := artifact.NewChangeFeed(cfg.changeLog)
+	events := artifact.NewEventBus(filepath.Join(cfg.repoPath, ".runtime", "outbox"), artifact.EventBusOptions{Logger: logger})
+	engine := artifact.NewExecutor(cfg.repoPath,
+		append(clockOptions(cfg.freezeTime, cfg.idSeed),
+			artifact.WithMetrics(metrics),
+			artifact.WithChangeFeed(changes),
+			artifact.WithEventBus(events),
+			artifact.WithLogger(logger),
+			artifact.WithRedactor(cfg.redactor),
+		)...,
+	)
+	migrated, err := engine.Migrate(false)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range migrated {
+		logger.Info("dataset migrated", "dataset", m.Dataset, "from", m.From, "to", m.To, "records", m.Records)
+	}
+
+	var recorder *artifact.Recorder
+	if cfg.freezeTime != nil || cfg.idSeed != nil {
+		logger.Warn("deterministic mode: time and generated ids are reproducible", "freeze_time", cfg.freezeTime, "id_seed", cfg.idSeed)
+	}
+	if cfg.recordDir != "" {
+		recorder = artifact.NewRecorder(cfg.recordDir)
+		logger.Warn("record mode: requests are serialised and captured as fixtures", "dir", cfg.recordDir)
+	}
+	traces := artifact.NewTraceBuffer(cfg.traceBuffer)
+
+	r := gin.New()
+	r.Use(gin.Recovery(), artifact.RequestID(), artifact.AccessLog(logger, cfg.redactor))
+	r.Use(otelgin.Middleware(serviceName))
+	browser := artifact.NewRepoBrowser(cfg.repoPath, cfg.browseDirs...)
+	repoGroup := r.Group("/repo", artifact.TokenAuth(cfg.repoToken))
+	repoGroup.GET("/*path", browser.Handle)
+	repoGroup.HEAD("/*path", browser.Handle)
+
+	index, err := artifact.LoadRegistry(cfg.repoPath + "/api/index.json")
+	if err != nil {
+		return nil, err
+	}
+	scheduler, err := artifact.NewScheduler(engine, index.Schedules, artifact.SchedulerOptions{Logger: logger})
+	if err != nil {
+		return nil, err
+	}
+
+	admin := r.Group("/_admin", artifact.TokenAuth(cfg.adminToken))
+	if cfg.adminToken != "" {
+		admin.GET("/schedules", scheduler.Handler)
+		if cfg.debug {
+			listTraces, getTrace := artifact.TraceHandlers(traces)
+			admin.GET("/traces", listTraces)
+			admin.GET("/traces/:id", getTrace)
+		}
+		artifact.RegisterStateAdmin(admin, engine)
+	} else {
+		logger.Info("admin endpoints disabled; set ADMIN_TOKEN to enable them")
+		if cfg.debug {
+			logger.Warn("debug traces need ADMIN_TOKEN and are not recorded")
+		}
+		if len(index.Schedules) > 0 {
+			logger.Info("schedule status needs ADMIN_TOKEN and is not served")
+		}
+	}
+
+	var faults *artifact.FaultInjector
+	if cfg.faults {
+		if faults, err = artifact.NewFaultInjector(cfg.faultSeed, nil, index.Endpoints); err != nil {
+			return nil, err
+		}
+		if cfg.adminToken != "" {
+			artifact.RegisterFaultAdmin(admin, faults, index.Endpoints)
+		}
+		logger.Warn("fault injection enabled", "seed", cfg.faultSeed)
+	}
+
+	routes, err := index.Routes(cfg.basePath)
+	if err != nil {
+		return nil, err
+	}
+	serve := map[string]gin.HandlerFunc{}
+	// preflight collects, per path, the policy of each method served there.
+	preflight := map[string]map[string]artifact.HTTPPolicy{}
+	addPreflight := func(method, path string, policy artifact.HTTPPolicy) {
+		if preflight[path] == nil {
+			preflight[path] = map[string]artifact.HTTPPolicy{}
+		}
+		preflight[path][method] = policy
+	}
+	for _, ep := range index.Endpoints {
+		if ep.Type == artifact.EndpointEvent {
+			events.Subscribe(ep.ID, ep.Topic, ep.Flow)
+			logger.Info("event subscription registered", "endpoint", ep.ID, "topic", ep.Topic, "flow", ep.Flow)
+			continue
+		}
+		policy := index.EndpointPolicy(ep)
+		if err := policy.Validate(); err != nil {
+			return nil, fmt.Errorf("endpoint %s policy: %w", ep.ID, err)
+		}
+		if ep.Type == artifact.EndpointStream {
+			mockPath := artifact.CleanJoin(cfg.basePath, ep.Path)
+			r.GET(mockPath, policy.Middleware(), artifact.StreamHandler(changes, ep, logger))
+			addPreflight(http.MethodGet, mockPath, policy)
+			logger.Info("stream registered", "endpoint", ep.ID, "path", mockPath, "dataset", ep.Dataset)
+			continue
+		}
+		requestOpts := cfg.requestOpts
+		requestOpts.BlobDir = filepath.Join(cfg.repoPath, ".runtime", "blobs")
+		if ep.MaxBodyBytes > 0 {
+			requestOpts.MaxBodyBytes = ep.MaxBodyBytes
+		}
+		// Versions of an endpoint can share a route, so the steps before
+		// the flow run inside one handler rather than as route middleware.
+		before := []gin.HandlerFunc{policy.Middleware(), artifact.Lifecycle(ep, artifact.LifecycleOptions{
+			Successor:     index.SuccessorURL(ep, cfg.basePath),
+			EnforceSunset: cfg.enforceSunset,
+		})}
+		if faults != nil {
+			before = append(before, faults.Middleware(ep.ID))
+		}
+		exec := func(def artifact.EndpointDef) gin.HandlerFunc {
+			return func(c *gin.Context) {
+				req, err := artifact.NewExecRequestFromGin(c, requestOpts)
+				if err != nil {
+					status := http.StatusBadRequest
+					switch {
+					case errors.Is(err, artifact.ErrOverrideRejected):
+						status = http.StatusForbidden
+					case errors.Is(err, artifact.ErrBodyTooLarge):
+						status = http.StatusRequestEntityTooLarge
+					case errors.Is(err, artifact.ErrUnsupportedMediaType):
+						status = http.StatusUnsupportedMediaType
+					}
+					c.JSON(status, map[string]string{"error": err.Error()})
+					return
+				}
+				ctx := c.Request.Context()
+				var trace *artifact.ExecTrace
+				if cfg.debug && cfg.adminToken != "" && artifact.DebugRequested(c) {
+					trace = artifact.NewExecTrace(def.Flow, req)
+					c.Header(artifact.TraceIDHeader, trace.ID)
+					ctx = artifact.WithTrace(ctx, trace)
+				}
+				var res *artifact.ExecResponse
+				if recorder != nil {
+					res, err = recorder.Run(ctx, engine, def.ID, def.Flow, req)
+				} else {
+					res, err = engine.Run(ctx, def.Flow, req)
+				}
+				if trace != nil {
+					traces.Add(trace)
+				}
+				if err != nil {
+					errRes := artifact.ResponseForError(err)
+					c.JSON(errRes.Status, errRes.Body)
+					return
+				}
+				contentType, data, err := res.Render()
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+					return
+				}
+				setResponseHeaders(c, res.Headers)
+				c.Data(res.Status, contentType, data)
+			}
+		}(ep)
+		serve[ep.ID] = func(id string) gin.HandlerFunc {
+			return func(c *gin.Context) {
+				start := time.Now()
+				defer func() { metrics.ObserveRequest(id, c.Writer.Status(), time.Since(start)) }()
+				for _, h := range before {
+					if h(c); c.IsAborted() {
+						return
+					}
+				}
+				exec(c)
+			}
+		}(ep.ID)
+	}
+	for _, rt := range routes {
+		policy := index.EndpointPolicy(rt.Endpoints[0])
+		addPreflight(rt.Method, rt.Path, policy)
+		if !rt.Versioned() {
+			ep := rt.Endpoints[0]
+			r.Handle(rt.Method, rt.Path, serve[ep.ID])
+			logger.Info("route registered", "endpoint", ep.ID, "method", rt.Method, "path", rt.Path, "flow", ep.Flow)
+			continue
+		}
+		r.Handle(rt.Method, rt.Path, func(rt artifact.Route, unmatched gin.HandlerFunc) gin.HandlerFunc {
+			return func(c *gin.Context) {
+				addVary(c.Writer.Header(), artifact.AcceptVersionHeader)
+				ep, err := rt.Select(c.GetHeader(artifact.AcceptVersionHeader))
+				if err != nil {
+					unmatched(c)
+					c.JSON(http.StatusNotAcceptable, map[string]string{"error": err.Error()})
+					return
+				}
+				serve[ep.ID](c)
+			}
+		}(rt, policy.Middleware()))
+		for _, ep := range rt.Endpoints {
+			logger.Info("route registered", "endpoint", ep.ID, "method", rt.Method, "path", rt.Path, "flow", ep.Flow, "version", ep.Version)
+		}
+	}
+	for path, policies := range preflight {
+		if _, ok := policies[http.MethodOptions]; ok {
+			continue
+		}
+		for _, p := range policies {
+			if p.CORS != nil {
+				r.OPTIONS(path, artifact.Preflight(policies))
+				break
+			}
+		}
+	}
+	if err := events.Start(engine); err != nil {
+		return nil, err
+	}
+
+	scheduler.Start()
+	for _, sd := range index.Schedules {
+		logger.Info("schedule registered", "schedule", sd.ID, "cron", sd.Cron, "flow", sd.Flow)
+	}
+	return &gateway{Handler: r, repoPath: cfg.repoPath, changes: changes, events: events, scheduler: scheduler}, nil
+}
+
+// clockOptions freezes the executor clock at freeze and seeds its id
+// generator with seed, each when set.
+func clockOptions(freeze *time.Time, seed *int64) []artifact.ExecutorOption {
+	clock := artifact.SystemClock()
+	if freeze != nil {
+		clock = artifact.NewManualClock(*freeze)
+	}
+	opts := []artifact.ExecutorOption{artifact.WithClock(clock)}
+	if seed != nil {
+		opts = append(opts, artifact.WithIDs(artifact.NewSeededIDGenerator(clock, *seed)))
+	}
+	return opts
+}
+
+// setResponseHeaders copies the headers a flow responded with. Vary is merged
+// with the values CORS and version routing added, so shared caches keep
+// telling responses for different origins and versions apart.
+func setResponseHeaders(c *gin.Context, headers map[string]string) {
+	for k, v := range headers {
+		if http.CanonicalHeaderKey(k) == "Vary" {
+			addVary(c.Writer.Header(), v)
+			continue
+		}
+		c.Header(k, v)
+	}
+}
+
+// addVary adds the comma-separated header names in value to Vary, skipping
+// those already listed.
+func addVary(h http.Header, value string) {
+	listed := map[string]bool{}
+	for _, line := range h.Values("Vary") {
+		for _, name := range strings.Split(line, ",") {
+			listed[http.CanonicalHeaderKey(strings.TrimSpace(name))] = true
+		}
+	}
+	for _, name := range strings.Split(value, ",") {
+		name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		if name != "" && !listed[name] {
+			listed[name] = true
+			h.Add("Vary", name)
+		}
+	}
+}
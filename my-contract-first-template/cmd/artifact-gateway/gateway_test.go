@@ -0,0 +1,98 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"my-app/platform/artifact"
+)
+
+// testGateway serves a repo made of the given files.
+func testGateway(t *testing.T, files map[string]string, adminToken string) *gateway {
+	t.Helper()
+	repo := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(repo, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	gw, err := newGateway(gatewayConfig{
+		repoPath:    repo,
+		basePath:    "/v1",
+		adminToken:  adminToken,
+		traceBuffer: 10,
+		changeLog:   10,
+		logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+		redactor:    artifact.DefaultRedactor(),
+		registerer:  prometheus.NewRegistry(),
+	})
+	require.NoError(t, err)
+	t.Cleanup(gw.stop)
+	return gw
+}
+
+func TestNegotiatedFormatKeepsCORSVary(t *testing.T) {
+	flow := `
+steps:
+  - op: respond
+    args: { status: 200, format: [json, csv], body: [{ id: u1 }] }
+`
+	gw := testGateway(t, map[string]string{
+		"api/index.json": `{
+  "versions": [{ "name": "2024-01" }, { "name": "2025-06" }],
+  "defaultVersion": "2025-06",
+  "policy": { "cors": { "allowOrigins": ["https://app.example.com"] } },
+  "endpoints": [
+    { "id": "users.old", "method": "GET", "path": "/users", "flow": "users.flow.yaml", "version": "2024-01" },
+    { "id": "users", "method": "GET", "path": "/users", "flow": "users.flow.yaml", "version": "2025-06" },
+    { "id": "teams", "method": "GET", "path": "/teams", "flow": "users.flow.yaml" }
+  ] }`,
+		"flows/users.flow.yaml": flow,
+	}, "")
+
+	for path, want := range map[string][]string{
+		"/v1/users": {"Accept-Version", "Origin", "Accept"},
+		"/v1/teams": {"Origin", "Accept"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Accept", "text/csv")
+		w := httptest.NewRecorder()
+		gw.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, path)
+		require.Contains(t, w.Header().Get("Content-Type"), "text/csv", path)
+		require.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"), path)
+		require.ElementsMatch(t, want, w.Header().Values("Vary"), path)
+	}
+}
+
+func TestScheduleStatusNeedsAdminToken(t *testing.T) {
+	files := map[string]string{
+		"api/index.json":      `{ "endpoints": [], "schedules": [{ "id": "nightly", "cron": "0 3 * * *", "flow": "job.flow.yaml" }] }`,
+		"flows/job.flow.yaml": "steps:\n  - op: now\n",
+	}
+	get := func(gw *gateway, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/_admin/schedules", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		gw.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(t, http.StatusNotFound, get(testGateway(t, files, ""), "").Code)
+
+	gw := testGateway(t, files, "s3cret")
+	require.Equal(t, http.StatusUnauthorized, get(gw, "").Code)
+	w := get(gw, "s3cret")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "nightly")
+}
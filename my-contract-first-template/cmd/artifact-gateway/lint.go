@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"my-app/platform/artifact"
+)
+
+// lintCommand checks a repo without serving it. Errors fail the command;
+// warnings only do with --strict.
+func lintCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	repoPath := fs.String("repo", envOr("REPO_PATH", "./repo"), "contract repo to check")
+	strict := fs.Bool("strict", false, "fail on warnings too")
+	asJSON := fs.Bool("json", false, "print issues as JSON")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		return 2
+	}
+
+	issues := artifact.LintRepo(*repoPath)
+	failing := 0
+	for _, i := range issues {
+		if i.Severity == artifact.LintError || *strict {
+			failing++
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(map[string]any{"issues": issues, "failed": failing > 0}); err != nil {
+			fmt.Fprintln(stderr, "encode:", err)
+			return 2
+		}
+	} else {
+		for _, i := range issues {
+			fmt.Fprintln(stdout, i)
+		}
+		fmt.Fprintf(stdout, "%d issues, %d failing\n", len(issues), failing)
+	}
+
+	if failing > 0 {
+		return 1
+	}
+	return 0
+}
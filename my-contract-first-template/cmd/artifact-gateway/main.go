@@ -1,68 +1,70 @@
 package main
 
 import (
-	"log"
-	"net/http"
+	"fmt"
+	"io"
 	"os"
-
-	"github.com/gin-gonic/gin"
-	"my-app/platform/artifact"
+	"strings"
 )
 
-func main() {
-	repoPath := os.Getenv("REPO_PATH")
-	if repoPath == "" {
-		repoPath = "./repo"
-	}
-	basePath := os.Getenv("BASE_PATH")
-	if basePath == "" {
-		basePath = "/v1"
-	}
-	addr := os.Getenv("GATEWAY_ADDR")
-	if addr == "" {
-		addr = ":8787"
-	}
+const usage = `usage: artifact-gateway <command> [flags]
 
-	engine := artifact.NewExecutor(repoPath)
+commands:
+  serve      serve the contract repo over HTTP (default when no command is given)
+  run        execute one flow offline and print its response
+  lint       check the registry, flows, schedules and seeds of a repo
+  test       run the declarative *.test.yaml cases of a repo
+  replay     re-run recorded fixtures and diff the responses
+  state      reset, snapshot, restore, export and import dataset state
+  migrate    apply pending dataset migrations
+  bundle     create, sign and verify single-file contract bundles
 
-	r := gin.Default()
-	r.Static("/repo", repoPath)
+Run "artifact-gateway <command> -h" for the flags of a command.
 
-	indexFile := repoPath + "/api/index.json"
-	index, err := artifact.LoadRegistry(indexFile)
-	if err != nil {
-		log.Fatal("Failed to load registry:", err)
-	}
+exit codes:
+  0  success
+  1  the command ran and found problems: failing tests or flows, lint errors,
+     changed fixtures, a server error
+  2  invalid usage, configuration or input files
+`
+
+var commands = map[string]func(args []string, stdout, stderr io.Writer) int{
+	"serve":   serveCommand,
+	"run":     runCommand,
+	"lint":    lintCommand,
+	"test":    testCommand,
+	"replay":  replayCommand,
+	"state":   stateCommand,
+	"migrate": migrateCommand,
+	"bundle":  bundleCommand,
+}
+
+func main() {
+	os.Exit(dispatch(os.Args[1:], os.Stdout, os.Stderr))
+}
 
-	for _, ep := range index.Endpoints {
-		mockPath := artifact.CleanJoin(basePath, ep.Path)
-		r.Handle(ep.Method, mockPath, func(def artifact.EndpointDef) gin.HandlerFunc {
-			return func(c *gin.Context) {
-				req, err := artifact.NewExecRequestFromGin(c)
-				if err != nil {
-					c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
-					return
-				}
-				res, err := engine.Run(c.Request.Context(), def.Flow, req)
-				if err != nil {
-					if stepErr, ok := err.(*artifact.StepError); ok {
-						c.JSON(stepErr.Status, map[string]any{"error": stepErr.Msg, "stepId": stepErr.StepID})
-						return
-					}
-					c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
-					return
-				}
-				for k, v := range res.Headers {
-					c.Header(k, v)
-				}
-				c.Data(res.Status, "application/json", res.BodyJSON())
-			}
-		}(ep))
-		log.Printf(" ROUTE %s %s → %s", ep.Method, mockPath, ep.Flow)
+func dispatch(args []string, stdout, stderr io.Writer) int {
+	// Without a command, or with only flags, behave like the old
+	// single-purpose binary and serve.
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") && args[0] != "-h" && args[0] != "--help" {
+		return serveCommand(args, stdout, stderr)
 	}
+	switch args[0] {
+	case "help", "-h", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+	return cmd(args[1:], stdout, stderr)
+}
 
-	log.Printf("🚀 Artifact Gateway running on %s (base: %s)", addr, basePath)
-	if err := r.Run(addr); err != nil {
-		log.Fatal("Server failed:", err)
+func splitList(v string) []string {
+	if strings.TrimSpace(v) == "" {
+		return nil
 	}
+	return strings.Split(v, ",")
 }
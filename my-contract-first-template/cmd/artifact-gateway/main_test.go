@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDispatchExitCodes(t *testing.T) {
+	cases := []struct {
+		name   string
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{name: "help", args: []string{"help"}, code: 0, stdout: "usage: artifact-gateway"},
+		{name: "help flag", args: []string{"--help"}, code: 0, stdout: "exit codes:"},
+		{name: "unknown command", args: []string{"deploy"}, code: 2, stderr: `unknown command "deploy"`},
+		{name: "bad subcommand flag", args: []string{"lint", "--nope"}, code: 2, stderr: "flag provided but not defined"},
+		{name: "lint clean repo", args: []string{"lint", "--repo", "../../repo"}, code: 0, stdout: "0 issues, 0 failing"},
+		{name: "lint missing repo", args: []string{"lint", "--repo", "testdata/missing"}, code: 1},
+		{name: "flags only serve", args: []string{"--tls-key", "key.pem"}, code: 2, stderr: "certFile and keyFile must be set together"},
+		{name: "serve stray argument", args: []string{"serve", "extra"}, code: 2, stderr: `unexpected argument "extra"`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("ARTIFACT_CONFIG", "")
+			var stdout, stderr bytes.Buffer
+			code := dispatch(tc.args, &stdout, &stderr)
+			require.Equal(t, tc.code, code, "stdout: %s\nstderr: %s", stdout.String(), stderr.String())
+			require.Contains(t, stdout.String(), tc.stdout)
+			require.Contains(t, stderr.String(), tc.stderr)
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"my-app/platform/artifact"
+)
+
+// migrateCommand applies pending dataset migrations, or with --dry-run shows
+// the records they would rewrite. The gateway also migrates on startup.
+func migrateCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	repoPath := fs.String("repo", envOr("REPO_PATH", "./repo"), "contract repo whose datasets are migrated")
+	dryRun := fs.Bool("dry-run", false, "show the effect without writing state")
+	status := fs.Bool("status", false, "print the applied version of each dataset")
+	asJSON := fs.Bool("json", false, "print results as JSON")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		return 2
+	}
+
+	exec := artifact.NewExecutor(*repoPath)
+	var (
+		result any
+		err    error
+	)
+	if *status {
+		result, err = exec.MigrationStatus()
+	} else {
+		result, err = exec.Migrate(*dryRun)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "migrate:", err)
+		return 1
+	}
+
+	results, isRun := result.([]artifact.MigrationResult)
+	if *asJSON || !isRun {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			fmt.Fprintln(stderr, "encode:", err)
+			return 1
+		}
+		return 0
+	}
+
+	if len(results) == 0 {
+		fmt.Fprintln(stdout, "all datasets are up to date")
+		return 0
+	}
+	verb := "migrated"
+	if *dryRun {
+		verb = "would migrate"
+	}
+	for _, r := range results {
+		fmt.Fprintf(stdout, "%s %s v%d -> v%d (%d records): %v\n", verb, r.Dataset, r.From, r.To, r.Records, r.Applied)
+		for _, c := range r.Changes {
+			fmt.Fprintf(stdout, "  record %d %s\n", c.Index, c.ID)
+			for _, d := range c.Differences {
+				fmt.Fprintf(stdout, "    %s: %s -> %s\n", d.Path, compact(d.Expected), compact(d.Actual))
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"my-app/platform/artifact"
+)
+
+// replayCommand re-runs recorded fixtures against the current flows and
+// prints a structured diff.
+func replayCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	repoPath := fs.String("repo", envOr("REPO_PATH", "./repo"), "contract repo whose flows are replayed")
+	dir := fs.String("fixtures", envOr("ARTIFACT_RECORD_DIR", "./fixtures"), "directory of recorded fixtures")
+	ignore := fs.String("ignore", "", "comma-separated paths to leave out of the diff, e.g. response.body.id")
+	asJSON := fs.Bool("json", false, "print results as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	fixtures, names, err := artifact.LoadFixtures(*dir)
+	if err != nil {
+		fmt.Fprintln(stderr, "load fixtures:", err)
+		return 2
+	}
+
+	opts := artifact.ReplayOptions{Ignore: splitList(*ignore)}
+	results := make([]artifact.ReplayResult, 0, len(names))
+	changed := 0
+	for _, name := range names {
+		r := artifact.ReplayFixture(context.Background(), *repoPath, name, fixtures[name], opts)
+		if len(r.Differences) > 0 || r.Error != "" {
+			changed++
+		}
+		results = append(results, r)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			fmt.Fprintln(stderr, "encode:", err)
+			return 2
+		}
+	} else {
+		for _, r := range results {
+			if len(r.Differences) == 0 && r.Error == "" {
+				fmt.Fprintf(stdout, "SAME    %s (%s)\n", r.Fixture, r.Flow)
+				continue
+			}
+			fmt.Fprintf(stdout, "CHANGED %s (%s)\n", r.Fixture, r.Flow)
+			if r.Error != "" {
+				fmt.Fprintf(stdout, "    error: %s\n", r.Error)
+			}
+			for _, d := range r.Differences {
+				fmt.Fprintf(stdout, "    %s\n      - %s\n      + %s\n", d.Path, compact(d.Expected), compact(d.Actual))
+			}
+		}
+		fmt.Fprintf(stdout, "\n%d unchanged, %d changed\n", len(results)-changed, changed)
+	}
+
+	if changed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func compact(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"my-app/platform/artifact"
+)
+
+const runUsage = `usage: artifact-gateway run [--repo DIR] [--persist] [--json] [--now TIME] [--seed N] <flow|endpoint> [request.json|-]
+
+Executes one flow with the ExecRequest read from request.json (stdin for -,
+an empty GET when omitted) and prints the response. The flow may be named by
+its file in flows/ or by the id of the endpoint that serves it. --now and
+--seed make the now op and generated ids reproducible.
+`
+
+// runCommand executes one flow offline. Dataset state is kept in memory
+// unless --persist is given, so runs do not change the repo.
+func runCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, runUsage)
+		fs.PrintDefaults()
+	}
+	repoPath := fs.String("repo", envOr("REPO_PATH", "./repo"), "contract repo containing the flow")
+	persist := fs.Bool("persist", false, "write dataset changes to the repo's .runtime state")
+	asJSON := fs.Bool("json", false, "print the ExecResponse as JSON")
+	verbose := fs.Bool("v", false, "log flow steps to stderr")
+	now := fs.String("now", "", "freeze the clock at this RFC 3339 time")
+	seed := fs.Int64("seed", 0, "seed the id generator")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	var (
+		freeze *time.Time
+		idSeed *int64
+	)
+	if *now != "" {
+		t, err := time.Parse(time.RFC3339, *now)
+		if err != nil {
+			fmt.Fprintln(stderr, "run: --now:", err)
+			return 2
+		}
+		freeze = &t
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			idSeed = seed
+		}
+	})
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		return 2
+	}
+
+	flowFile, err := resolveFlow(*repoPath, fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, "run:", err)
+		return 2
+	}
+	req, err := readExecRequest(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintln(stderr, "read request:", err)
+		return 2
+	}
+
+	level := slog.LevelWarn
+	if *verbose {
+		level = slog.LevelDebug
+	}
+	opts := append(clockOptions(freeze, idSeed), artifact.WithLogger(slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: level}))))
+	if !*persist {
+		opts = append(opts, artifact.WithStore(artifact.NewMemoryStore(nil)))
+	}
+	res, runErr := artifact.NewExecutor(*repoPath, opts...).Run(context.Background(), flowFile, req)
+	if runErr != nil {
+		res = artifact.ResponseForError(runErr)
+	}
+
+	if err := printResponse(stdout, res, *asJSON); err != nil {
+		fmt.Fprintln(stderr, "run:", err)
+		return 1
+	}
+	if runErr != nil {
+		return 1
+	}
+	return 0
+}
+
+// resolveFlow accepts a flow file name or an endpoint id from the registry.
+func resolveFlow(repoPath, name string) (string, error) {
+	if _, err := os.Stat(filepath.Join(repoPath, "flows", name)); err == nil {
+		return name, nil
+	}
+	reg, err := artifact.LoadRegistry(filepath.Join(repoPath, "api", "index.json"))
+	if err != nil {
+		return "", fmt.Errorf("no flow %s and no registry to look it up: %w", name, err)
+	}
+	for _, ep := range reg.Endpoints {
+		if ep.ID == name && ep.Flow != "" {
+			return ep.Flow, nil
+		}
+	}
+	return "", fmt.Errorf("%s is neither a flow file nor an endpoint id", name)
+}
+
+func readExecRequest(path string) (*artifact.ExecRequest, error) {
+	req := &artifact.ExecRequest{}
+	if path != "" {
+		var (
+			b   []byte
+			err error
+		)
+		if path == "-" {
+			b, err = io.ReadAll(os.Stdin)
+		} else {
+			b, err = os.ReadFile(path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, req); err != nil {
+			return nil, err
+		}
+	}
+	if req.RequestID == "" {
+		req.RequestID = "run"
+	}
+	if req.Method == "" {
+		req.Method = "GET"
+	}
+	req.Method = strings.ToUpper(req.Method)
+	if req.Params == nil {
+		req.Params = map[string]string{}
+	}
+	if req.Query == nil {
+		req.Query = map[string][]string{}
+	}
+	if req.Headers == nil {
+		req.Headers = map[string][]string{}
+	}
+	if req.Body == nil {
+		req.Body = map[string]any{}
+	}
+	if req.Dataset == nil {
+		req.Dataset = map[string]any{}
+	}
+	return req, nil
+}
+
+func printResponse(w io.Writer, res *artifact.ExecResponse, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(res)
+	}
+	contentType, body, err := res.Render()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%d\nContent-Type: %s\n", res.Status, contentType)
+	keys := make([]string, 0, len(res.Headers))
+	for k := range res.Headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s: %s\n", k, res.Headers[k])
+	}
+	fmt.Fprintf(w, "\n%s\n", body)
+	return nil
+}
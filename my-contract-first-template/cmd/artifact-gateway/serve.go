@@ -0,0 +1,466 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"gopkg.in/yaml.v3"
+	"my-app/platform/artifact"
+)
+
+// serveConfig is the serve configuration. Values come from the defaults,
+// then the YAML config file, then environment variables, then flags; each
+// source overrides the ones before it.
+type serveConfig struct {
+	Addr          string        `yaml:"addr"`
+	Repo          string        `yaml:"repo"`
+	BasePath      string        `yaml:"basePath"`
+	TenantsFile   string        `yaml:"tenantsFile"`
+	LogLevel      string        `yaml:"logLevel"`
+	AdminToken    string        `yaml:"adminToken"`
+	RepoToken     string        `yaml:"repoToken"`
+	BrowseDirs    []string      `yaml:"browseDirs"`
+	RecordDir     string        `yaml:"recordDir"`
+	Debug         bool          `yaml:"debug"`
+	TraceBuffer   int           `yaml:"traceBuffer"`
+	ChangeLog     int           `yaml:"changeLog"`
+	MaxBodyBytes  int64         `yaml:"maxBodyBytes"`
+	Faults        bool          `yaml:"faults"`
+	FaultSeed     *int64        `yaml:"faultSeed"`
+	EnforceSunset bool          `yaml:"enforceSunset"`
+	FreezeTime    *time.Time    `yaml:"freezeTime"`
+	IDSeed        *int64        `yaml:"idSeed"`
+	Bundle        string        `yaml:"bundle"`
+	BundleKeys    []string      `yaml:"bundleKeys"`
+	BundleDir     string        `yaml:"bundleDir"`
+	TLS           tlsConfig     `yaml:"tls"`
+	Timeouts      timeoutConfig `yaml:"timeouts"`
+}
+
+// tlsConfig enables HTTPS when CertFile and KeyFile are set. ClientCAFile
+// additionally requires clients to present a certificate it signed.
+type tlsConfig struct {
+	CertFile     string `yaml:"certFile"`
+	KeyFile      string `yaml:"keyFile"`
+	ClientCAFile string `yaml:"clientCAFile"`
+}
+
+// timeoutConfig holds the HTTP server timeouts, written like "30s".
+// Shutdown bounds how long in-flight requests may take to drain.
+type timeoutConfig struct {
+	ReadHeader time.Duration `yaml:"readHeader"`
+	Read       time.Duration `yaml:"read"`
+	Write      time.Duration `yaml:"write"`
+	Idle       time.Duration `yaml:"idle"`
+	Shutdown   time.Duration `yaml:"shutdown"`
+}
+
+func defaultServeConfig() serveConfig {
+	return serveConfig{
+		Addr:        ":8787",
+		Repo:        "./repo",
+		BasePath:    "/v1",
+		BundleDir:   "./.artifact-bundle",
+		TraceBuffer: 100,
+		ChangeLog:   1000,
+		Timeouts: timeoutConfig{
+			ReadHeader: 10 * time.Second,
+			Read:       30 * time.Second,
+			Write:      60 * time.Second,
+			Idle:       120 * time.Second,
+			Shutdown:   30 * time.Second,
+		},
+	}
+}
+
+// loadServeConfig reads path, when set, over the defaults and applies the
+// environment. ${VAR} references in the file are expanded so secrets can
+// stay out of it.
+func loadServeConfig(path string) (serveConfig, error) {
+	cfg := defaultServeConfig()
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, err
+		}
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+			return cfg, fmt.Errorf("%s: %w", path, err)
+		}
+		cfg.AdminToken = os.ExpandEnv(cfg.AdminToken)
+		cfg.RepoToken = os.ExpandEnv(cfg.RepoToken)
+		// Paths in the file are relative to it, not to the working directory.
+		dir := filepath.Dir(path)
+		paths := []*string{&cfg.Repo, &cfg.TenantsFile, &cfg.RecordDir, &cfg.Bundle, &cfg.BundleDir, &cfg.TLS.CertFile, &cfg.TLS.KeyFile, &cfg.TLS.ClientCAFile}
+		for i := range cfg.BundleKeys {
+			paths = append(paths, &cfg.BundleKeys[i])
+		}
+		for _, p := range paths {
+			if *p != "" && !filepath.IsAbs(*p) {
+				*p = filepath.Join(dir, *p)
+			}
+		}
+	}
+	return cfg, cfg.applyEnv()
+}
+
+// validate checks the settings that only make sense together.
+func (c *serveConfig) validate() error {
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return errors.New("tls: certFile and keyFile must be set together")
+	}
+	if c.TLS.ClientCAFile != "" && c.TLS.CertFile == "" {
+		return errors.New("tls: clientCAFile needs certFile and keyFile")
+	}
+	for name, d := range map[string]time.Duration{
+		"readHeader": c.Timeouts.ReadHeader, "read": c.Timeouts.Read, "write": c.Timeouts.Write,
+		"idle": c.Timeouts.Idle, "shutdown": c.Timeouts.Shutdown,
+	} {
+		if d < 0 {
+			return fmt.Errorf("timeouts.%s must not be negative", name)
+		}
+	}
+	return nil
+}
+
+func (c *serveConfig) applyEnv() error {
+	for key, dst := range map[string]*string{
+		"GATEWAY_ADDR":        &c.Addr,
+		"REPO_PATH":           &c.Repo,
+		"BASE_PATH":           &c.BasePath,
+		"TENANTS_FILE":        &c.TenantsFile,
+		"LOG_LEVEL":           &c.LogLevel,
+		"ADMIN_TOKEN":         &c.AdminToken,
+		"REPO_TOKEN":          &c.RepoToken,
+		"ARTIFACT_RECORD_DIR": &c.RecordDir,
+		"ARTIFACT_BUNDLE":     &c.Bundle,
+		"ARTIFACT_BUNDLE_DIR": &c.BundleDir,
+		"TLS_CERT_FILE":       &c.TLS.CertFile,
+		"TLS_KEY_FILE":        &c.TLS.KeyFile,
+		"TLS_CLIENT_CA_FILE":  &c.TLS.ClientCAFile,
+	} {
+		if v := os.Getenv(key); v != "" {
+			*dst = v
+		}
+	}
+	if v := os.Getenv("REPO_BROWSE_DIRS"); v != "" {
+		c.BrowseDirs = splitList(v)
+	}
+	if v := os.Getenv("ARTIFACT_BUNDLE_KEYS"); v != "" {
+		c.BundleKeys = splitList(v)
+	}
+	for key, dst := range map[string]*bool{
+		"ARTIFACT_DEBUG":          &c.Debug,
+		"ARTIFACT_FAULTS":         &c.Faults,
+		"ARTIFACT_ENFORCE_SUNSET": &c.EnforceSunset,
+	} {
+		if v := os.Getenv(key); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			*dst = b
+		}
+	}
+	for key, dst := range map[string]*int{
+		"ARTIFACT_TRACE_BUFFER": &c.TraceBuffer,
+		"ARTIFACT_CHANGE_LOG":   &c.ChangeLog,
+	} {
+		if v := os.Getenv(key); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			*dst = n
+		}
+	}
+	for key, dst := range map[string]*time.Duration{
+		"HTTP_READ_HEADER_TIMEOUT": &c.Timeouts.ReadHeader,
+		"HTTP_READ_TIMEOUT":        &c.Timeouts.Read,
+		"HTTP_WRITE_TIMEOUT":       &c.Timeouts.Write,
+		"HTTP_IDLE_TIMEOUT":        &c.Timeouts.Idle,
+		"SHUTDOWN_TIMEOUT":         &c.Timeouts.Shutdown,
+	} {
+		if v := os.Getenv(key); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			*dst = d
+		}
+	}
+	if v := os.Getenv("ARTIFACT_MAX_BODY_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("ARTIFACT_MAX_BODY_BYTES: %w", err)
+		}
+		c.MaxBodyBytes = n
+	}
+	if v := os.Getenv("ARTIFACT_FAULT_SEED"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("ARTIFACT_FAULT_SEED: %w", err)
+		}
+		c.FaultSeed = &n
+	}
+	if v := os.Getenv("ARTIFACT_ID_SEED"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("ARTIFACT_ID_SEED: %w", err)
+		}
+		c.IDSeed = &n
+	}
+	if v := os.Getenv("ARTIFACT_FREEZE_TIME"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return fmt.Errorf("ARTIFACT_FREEZE_TIME: %w", err)
+		}
+		c.FreezeTime = &t
+	}
+	return nil
+}
+
+// errBadFlags reports flags the flag set has already complained about.
+var errBadFlags = errors.New("invalid flags")
+
+// serveConfigFromArgs resolves the serve configuration from the defaults,
+// the config file, the environment and args, in that order.
+func serveConfigFromArgs(args []string, stderr io.Writer) (serveConfig, error) {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configPath := fs.String("config", os.Getenv("ARTIFACT_CONFIG"), "YAML config file")
+	addr := fs.String("addr", "", "listen address")
+	repo := fs.String("repo", "", "contract repo to serve")
+	basePath := fs.String("base-path", "", "path prefix of the mock endpoints")
+	tenants := fs.String("tenants", "", "tenants file; serves several repos")
+	logLevel := fs.String("log-level", "", "debug, info, warn or error")
+	debug := fs.Bool("debug", false, "record step traces of requests sent with X-Artifact-Debug")
+	faults := fs.Bool("faults", false, "apply the fault rules of the registry")
+	bundle := fs.String("bundle", "", "signed bundle to serve instead of a repo directory")
+	bundleKeys := fs.String("bundle-keys", "", "comma-separated public key files that may sign the bundle")
+	tlsCert := fs.String("tls-cert", "", "TLS certificate file; enables HTTPS")
+	tlsKey := fs.String("tls-key", "", "TLS private key file")
+	tlsClientCA := fs.String("tls-client-ca", "", "CA file that client certificates must chain to")
+	if err := fs.Parse(args); err != nil {
+		return serveConfig{}, errBadFlags
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "unexpected argument %q\n", fs.Arg(0))
+		return serveConfig{}, errBadFlags
+	}
+	cfg, err := loadServeConfig(*configPath)
+	if err != nil {
+		return cfg, err
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			cfg.Addr = *addr
+		case "repo":
+			cfg.Repo = *repo
+		case "base-path":
+			cfg.BasePath = *basePath
+		case "tenants":
+			cfg.TenantsFile = *tenants
+		case "log-level":
+			cfg.LogLevel = *logLevel
+		case "debug":
+			cfg.Debug = *debug
+		case "faults":
+			cfg.Faults = *faults
+		case "bundle":
+			cfg.Bundle = *bundle
+		case "bundle-keys":
+			cfg.BundleKeys = splitList(*bundleKeys)
+		case "tls-cert":
+			cfg.TLS.CertFile = *tlsCert
+		case "tls-key":
+			cfg.TLS.KeyFile = *tlsKey
+		case "tls-client-ca":
+			cfg.TLS.ClientCAFile = *tlsClientCA
+		}
+	})
+	return cfg, cfg.validate()
+}
+
+// serveCommand runs the gateway until SIGINT or SIGTERM, then drains
+// in-flight requests and stops background work before exiting.
+func serveCommand(args []string, stdout, stderr io.Writer) int {
+	cfg, err := serveConfigFromArgs(args, stderr)
+	if err != nil {
+		if !errors.Is(err, errBadFlags) {
+			fmt.Fprintln(stderr, "config:", err)
+		}
+		return 2
+	}
+
+	logger, err := artifact.NewLogger(stdout, cfg.LogLevel)
+	if err != nil {
+		fmt.Fprintln(stderr, "invalid log level:", err)
+		return 2
+	}
+	slog.SetDefault(logger)
+	redactor := artifact.DefaultRedactor()
+	if headers, fields := os.Getenv("LOG_REDACT_HEADERS"), os.Getenv("LOG_REDACT_FIELDS"); headers != "" || fields != "" {
+		redactor = artifact.NewRedactor(strings.Split(headers, ","), strings.Split(fields, ","))
+	}
+
+	if b, err := loadBundle(&cfg); err != nil {
+		logger.Error("failed to load bundle", "error", err.Error())
+		return 2
+	} else if b != nil {
+		logger.Info("bundle verified", "keyId", b.KeyID, "created", b.Manifest.Created, "files", len(b.Manifest.Files), "dir", cfg.BundleDir)
+	}
+
+	overrides, err := overridePolicyFromEnv(logger)
+	if err != nil {
+		logger.Error("invalid override configuration", "error", err.Error())
+		return 2
+	}
+
+	shutdownTracing, err := setupTracing(context.Background())
+	if err != nil {
+		logger.Error("failed to set up tracing", "error", err.Error())
+		return 2
+	}
+	defer func() { _ = shutdownTracing(context.Background()) }()
+
+	promRegistry := prometheus.NewRegistry()
+	promRegistry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+
+	seed := time.Now().UnixNano()
+	if cfg.FaultSeed != nil {
+		seed = *cfg.FaultSeed
+	}
+	gin.SetMode(gin.ReleaseMode)
+	base := gatewayConfig{
+		repoPath:      cfg.Repo,
+		basePath:      cfg.BasePath,
+		adminToken:    cfg.AdminToken,
+		repoToken:     cfg.RepoToken,
+		browseDirs:    cfg.BrowseDirs,
+		recordDir:     cfg.RecordDir,
+		debug:         cfg.Debug,
+		traceBuffer:   cfg.TraceBuffer,
+		changeLog:     cfg.ChangeLog,
+		faults:        cfg.Faults,
+		faultSeed:     seed,
+		enforceSunset: cfg.EnforceSunset,
+		freezeTime:    cfg.FreezeTime,
+		idSeed:        cfg.IDSeed,
+		requestOpts:   artifact.RequestOptions{Overrides: overrides, MaxBodyBytes: cfg.MaxBodyBytes},
+		logger:        logger,
+		redactor:      redactor,
+		registerer:    promRegistry,
+	}
+
+	var (
+		handler  http.Handler
+		gateways map[string]*gateway
+	)
+	if cfg.TenantsFile != "" {
+		handler, gateways, err = newTenantHandler(cfg.TenantsFile, base)
+	} else {
+		var gw *gateway
+		gw, err = newGateway(base)
+		handler, gateways = gw, map[string]*gateway{"default": gw}
+	}
+	if err != nil {
+		logger.Error("failed to build gateway", "error", err.Error())
+		return 2
+	}
+	srv, err := newServer(cfg, handler, promRegistry, gateways)
+	if err != nil {
+		logger.Error("invalid TLS configuration", "error", err.Error())
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return srv.run(ctx, logger)
+}
+
+// newTenantHandler serves every tenant of a tenants file from its own repo.
+// Tenant settings left empty fall back to the process-wide ones in base.
+func newTenantHandler(path string, base gatewayConfig) (http.Handler, map[string]*gateway, error) {
+	tenants, err := artifact.LoadTenants(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	handlers := map[string]http.Handler{}
+	gateways := map[string]*gateway{}
+	for _, t := range tenants.Tenants {
+		cfg := base
+		cfg.repoPath = t.Repo
+		cfg.logger = base.logger.With("tenant", t.Name)
+		cfg.registerer = prometheus.WrapRegistererWith(prometheus.Labels{"tenant": t.Name}, base.registerer)
+		if t.BasePath != "" {
+			cfg.basePath = t.BasePath
+		}
+		if t.AdminToken != "" {
+			cfg.adminToken = t.AdminToken
+		}
+		if t.RepoToken != "" {
+			cfg.repoToken = t.RepoToken
+		}
+		if base.recordDir != "" {
+			cfg.recordDir = filepath.Join(base.recordDir, t.Name)
+		}
+		gw, err := newGateway(cfg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("tenant %s: %w", t.Name, err)
+		}
+		handlers[t.Name], gateways[t.Name] = gw, gw
+		cfg.logger.Info("tenant loaded", "repo", t.Repo, "hosts", t.Hosts, "pathPrefix", t.PathPrefix)
+	}
+	router, err := artifact.NewTenantRouter(tenants, handlers)
+	return router, gateways, err
+}
+
+// overridePolicyFromEnv enables X-Artifact-Request only in ARTIFACT_DEV_MODE
+// or, when ARTIFACT_OVERRIDE_KEY is set, for HMAC-signed headers.
+func overridePolicyFromEnv(logger *slog.Logger) (*artifact.OverridePolicy, error) {
+	devMode := os.Getenv("ARTIFACT_DEV_MODE") == "true"
+	key := os.Getenv("ARTIFACT_OVERRIDE_KEY")
+	if !devMode && key == "" {
+		return nil, nil
+	}
+	fields, keys, err := artifact.ParseOverrideAllowlist(os.Getenv("ARTIFACT_OVERRIDE_ALLOW"))
+	if err != nil {
+		return nil, err
+	}
+	var maxAge time.Duration
+	if v := os.Getenv("ARTIFACT_OVERRIDE_MAX_AGE"); v != "" {
+		if maxAge, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("ARTIFACT_OVERRIDE_MAX_AGE: %w", err)
+		}
+	}
+	if devMode {
+		logger.Warn("dev mode: unsigned X-Artifact-Request overrides are accepted")
+	}
+	return &artifact.OverridePolicy{
+		DevMode:       devMode,
+		HMACKey:       []byte(key),
+		AllowedFields: fields,
+		AllowedKeys:   keys,
+		Logger:        logger,
+		MaxAge:        maxAge,
+	}, nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "gateway.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestServeConfigPrecedence(t *testing.T) {
+	cases := []struct {
+		name  string
+		yaml  string
+		env   map[string]string
+		args  []string
+		check func(t *testing.T, cfg serveConfig, dir string)
+	}{
+		{
+			name: "defaults",
+			check: func(t *testing.T, cfg serveConfig, _ string) {
+				require.Equal(t, defaultServeConfig(), cfg)
+			},
+		},
+		{
+			name: "file over defaults",
+			yaml: "addr: \":9000\"\nrepo: contracts\ntraceBuffer: 5\ntimeouts: { write: 10s }\nadminToken: ${TEST_ADMIN_TOKEN}\n",
+			env:  map[string]string{"TEST_ADMIN_TOKEN": "s3cret"},
+			check: func(t *testing.T, cfg serveConfig, dir string) {
+				require.Equal(t, ":9000", cfg.Addr)
+				require.Equal(t, filepath.Join(dir, "contracts"), cfg.Repo, "file paths are relative to the file")
+				require.Equal(t, 5, cfg.TraceBuffer)
+				require.Equal(t, 10*time.Second, cfg.Timeouts.Write)
+				require.Equal(t, 30*time.Second, cfg.Timeouts.Read, "unset keys keep their default")
+				require.Equal(t, "/v1", cfg.BasePath)
+				require.Equal(t, "s3cret", cfg.AdminToken)
+			},
+		},
+		{
+			name: "env over file",
+			yaml: "addr: \":9000\"\ntraceBuffer: 5\ndebug: false\n",
+			env:  map[string]string{"GATEWAY_ADDR": ":9100", "ARTIFACT_TRACE_BUFFER": "7", "ARTIFACT_DEBUG": "true", "REPO_BROWSE_DIRS": "api,flows"},
+			check: func(t *testing.T, cfg serveConfig, _ string) {
+				require.Equal(t, ":9100", cfg.Addr)
+				require.Equal(t, 7, cfg.TraceBuffer)
+				require.True(t, cfg.Debug)
+				require.Equal(t, []string{"api", "flows"}, cfg.BrowseDirs)
+			},
+		},
+		{
+			name: "flags over env",
+			yaml: "addr: \":9000\"\n",
+			env:  map[string]string{"GATEWAY_ADDR": ":9100", "ARTIFACT_DEBUG": "true", "BASE_PATH": "/api"},
+			args: []string{"--addr", ":9200", "--debug=false"},
+			check: func(t *testing.T, cfg serveConfig, _ string) {
+				require.Equal(t, ":9200", cfg.Addr)
+				require.False(t, cfg.Debug, "an explicit false flag still overrides")
+				require.Equal(t, "/api", cfg.BasePath, "flags that were not given leave env values alone")
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("ARTIFACT_CONFIG", "")
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			args, dir := tc.args, ""
+			if tc.yaml != "" {
+				path := writeTestConfig(t, tc.yaml)
+				args, dir = append([]string{"--config", path}, args...), filepath.Dir(path)
+			}
+			cfg, err := serveConfigFromArgs(args, io.Discard)
+			require.NoError(t, err)
+			tc.check(t, cfg, dir)
+		})
+	}
+}
+
+func TestServeConfigErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		yaml string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{name: "unknown file key", yaml: "adress: \":9000\"\n", want: "field adress not found"},
+		{name: "missing file", args: []string{"--config", "/nonexistent/gateway.yaml"}, want: "no such file"},
+		{name: "bad env bool", env: map[string]string{"ARTIFACT_FAULTS": "maybe"}, want: "ARTIFACT_FAULTS"},
+		{name: "bad env duration", env: map[string]string{"HTTP_READ_TIMEOUT": "30"}, want: "HTTP_READ_TIMEOUT"},
+		{name: "negative timeout", yaml: "timeouts: { idle: -1s }\n", want: "timeouts.idle must not be negative"},
+		{name: "cert without key", args: []string{"--tls-cert", "cert.pem"}, want: "certFile and keyFile must be set together"},
+		{name: "client CA without cert", env: map[string]string{"TLS_CLIENT_CA_FILE": "ca.pem"}, want: "clientCAFile needs certFile"},
+		{name: "unknown flag", args: []string{"--nope"}, want: errBadFlags.Error()},
+		{name: "stray argument", args: []string{"repo"}, want: errBadFlags.Error()},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("ARTIFACT_CONFIG", "")
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			args := tc.args
+			if tc.yaml != "" {
+				args = append([]string{"--config", writeTestConfig(t, tc.yaml)}, args...)
+			}
+			_, err := serveConfigFromArgs(args, io.Discard)
+			require.ErrorContains(t, err, tc.want)
+		})
+	}
+}
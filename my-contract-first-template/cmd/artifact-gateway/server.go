@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// server wraps the HTTP server with health endpoints and the shutdown
+// sequence of the gateways it serves.
+type server struct {
+	http     *http.Server
+	tls      tlsConfig
+	shutdown timeoutConfig
+	gateways map[string]*gateway
+	draining atomic.Bool
+}
+
+func newServer(cfg serveConfig, handler http.Handler, reg *prometheus.Registry, gateways map[string]*gateway) (*server, error) {
+	s := &server{tls: cfg.TLS, shutdown: cfg.Timeouts, gateways: gateways}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/healthz", s.healthz)
+	mux.HandleFunc("/readyz", s.readyz)
+	mux.Handle("/", handler)
+
+	s.http = &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           mux,
+		ReadHeaderTimeout: cfg.Timeouts.ReadHeader,
+		ReadTimeout:       cfg.Timeouts.Read,
+		WriteTimeout:      cfg.Timeouts.Write,
+		IdleTimeout:       cfg.Timeouts.Idle,
+	}
+	if cfg.TLS.CertFile != "" {
+		tc, err := serverTLSConfig(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		s.http.TLSConfig = tc
+		if cfg.Faults {
+			// Injected connection resets hijack the connection, which
+			// HTTP/2 does not allow.
+			s.http.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+		}
+	}
+	s.http.RegisterOnShutdown(func() {
+		for _, gw := range gateways {
+			gw.closeStreams()
+		}
+	})
+	return s, nil
+}
+
+// serverTLSConfig loads the client CA when mutual TLS is configured. The
+// certificate itself is loaded by ListenAndServeTLS.
+func serverTLSConfig(c tlsConfig) (*tls.Config, error) {
+	tc := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.ClientCAFile == "" {
+		return tc, nil
+	}
+	pem, err := os.ReadFile(c.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%s: no PEM certificates found", c.ClientCAFile)
+	}
+	tc.ClientCAs = pool
+	tc.ClientAuth = tls.RequireAndVerifyClientCert
+	return tc, nil
+}
+
+// run serves until ctx is cancelled, then stops accepting connections,
+// drains in-flight requests for up to the shutdown timeout and stops the
+// scheduler and event bus so no state write is cut off half way.
+func (s *server) run(ctx context.Context, logger *slog.Logger) int {
+	errc := make(chan error, 1)
+	go func() {
+		if s.tls.CertFile != "" {
+			errc <- s.http.ListenAndServeTLS(s.tls.CertFile, s.tls.KeyFile)
+			return
+		}
+		errc <- s.http.ListenAndServe()
+	}()
+	logger.Info("artifact gateway running", "addr", s.http.Addr, "tls", s.tls.CertFile != "", "mtls", s.tls.ClientCAFile != "")
+
+	select {
+	case err := <-errc:
+		logger.Error("server failed", "error", err.Error())
+		s.stopGateways()
+		return 1
+	case <-ctx.Done():
+	}
+
+	logger.Info("shutting down", "timeout", s.shutdown.Shutdown.String())
+	s.draining.Store(true)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdown.Shutdown)
+	defer cancel()
+	err := s.http.Shutdown(shutdownCtx)
+	s.stopGateways()
+	if err != nil {
+		logger.Error("requests still running at the shutdown deadline", "error", err.Error())
+		return 1
+	}
+	logger.Info("shutdown complete")
+	return 0
+}
+
+func (s *server) stopGateways() {
+	for _, gw := range s.gateways {
+		gw.stop()
+	}
+}
+
+// healthz reports that the process is up.
+func (s *server) healthz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, map[string]any{"status": "ok"})
+}
+
+// readyz reports whether every repo's registry loads and the server is not
+// shutting down, so load balancers stop routing before connections close.
+func (s *server) readyz(w http.ResponseWriter, r *http.Request) {
+	if s.draining.Load() {
+		writeHealth(w, http.StatusServiceUnavailable, map[string]any{"status": "shutting down"})
+		return
+	}
+	status, code := "ready", http.StatusOK
+	repos := map[string]string{}
+	for name, gw := range s.gateways {
+		repos[name] = "ok"
+		if err := gw.ready(); err != nil {
+			repos[name] = err.Error()
+			status, code = "not ready", http.StatusServiceUnavailable
+		}
+	}
+	writeHealth(w, code, map[string]any{"status": status, "repos": repos})
+}
+
+func writeHealth(w http.ResponseWriter, code int, body map[string]any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthAndReadiness(t *testing.T) {
+	gateways := map[string]*gateway{"default": {repoPath: "../../repo"}}
+	srv, err := newServer(defaultServeConfig(), http.NotFoundHandler(), prometheus.NewRegistry(), gateways)
+	require.NoError(t, err)
+	get := func(path string) (int, map[string]any) {
+		rec := httptest.NewRecorder()
+		srv.http.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var body map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+		return rec.Code, body
+	}
+
+	code, body := get("/healthz")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "ok", body["status"])
+
+	code, body = get("/readyz")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, map[string]any{"status": "ready", "repos": map[string]any{"default": "ok"}}, body)
+
+	gateways["broken"] = &gateway{repoPath: t.TempDir()}
+	code, body = get("/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, "not ready", body["status"])
+	repos := body["repos"].(map[string]any)
+	require.Equal(t, "ok", repos["default"])
+	require.Contains(t, repos["broken"], "index.json")
+
+	delete(gateways, "broken")
+	srv.draining.Store(true)
+	code, body = get("/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, "shutting down", body["status"])
+	code, _ = get("/healthz")
+	require.Equal(t, http.StatusOK, code, "the process stays healthy while draining")
+}
+
+func TestFaultsServeTLSOverHTTP1(t *testing.T) {
+	cfg := defaultServeConfig()
+	cfg.TLS = tlsConfig{CertFile: "cert.pem", KeyFile: "key.pem"}
+	srv, err := newServer(cfg, http.NotFoundHandler(), prometheus.NewRegistry(), nil)
+	require.NoError(t, err)
+	require.Nil(t, srv.http.TLSNextProto, "HTTP/2 stays on without faults")
+
+	cfg.Faults = true
+	srv, err = newServer(cfg, http.NotFoundHandler(), prometheus.NewRegistry(), nil)
+	require.NoError(t, err)
+	require.NotNil(t, srv.http.TLSNextProto)
+	require.Empty(t, srv.http.TLSNextProto, "resets need hijackable HTTP/1.1 connections")
+}
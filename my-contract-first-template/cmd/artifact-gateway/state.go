@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"my-app/platform/artifact"
+)
+
+const stateUsage = `usage: artifact-gateway state [--repo DIR] <command>
+
+commands:
+  reset [dataset...]         reset datasets (all when none given) to their seeds
+  snapshot <name>            save the current state under name
+  restore <name>             restore a named snapshot
+  snapshots                  list saved snapshots
+  export <dataset> [file]    write a dataset as JSON (stdout when no file)
+  import <dataset> <file>    replace a dataset with the records in file
+`
+
+// stateCommand manages dataset state of a repo offline. Stop the gateway
+// first; it does not see changes made behind its back until it reloads.
+func stateCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("state", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { fmt.Fprint(stderr, stateUsage) }
+	repoPath := fs.String("repo", envOr("REPO_PATH", "./repo"), "contract repo whose state is managed")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	rest := fs.Args()
+	if len(rest) == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	exec := artifact.NewExecutor(*repoPath)
+	cmd, rest := rest[0], rest[1:]
+	var (
+		result any
+		err    error
+	)
+	switch {
+	case cmd == "reset":
+		result, err = exec.ResetDatasets(rest...)
+	case cmd == "snapshot" && len(rest) == 1:
+		result, err = exec.SaveSnapshot(rest[0])
+	case cmd == "restore" && len(rest) == 1:
+		result, err = exec.RestoreSnapshot(rest[0])
+	case cmd == "snapshots" && len(rest) == 0:
+		result, err = exec.ListSnapshots()
+	case cmd == "export" && (len(rest) == 1 || len(rest) == 2):
+		var records []any
+		records, err = exec.ExportDataset(rest[0])
+		if err == nil && len(rest) == 2 {
+			err = writeJSONFile(rest[1], records)
+			result = map[string]any{"dataset": rest[0], "records": len(records), "file": rest[1]}
+		} else {
+			result = records
+		}
+	case cmd == "import" && len(rest) == 2:
+		var records []any
+		records, err = readRecords(rest[1])
+		if err == nil {
+			err = exec.ImportDataset(rest[0], records)
+		}
+		result = map[string]any{"dataset": rest[0], "records": len(records)}
+	default:
+		fs.Usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "state %s: %v\n", cmd, err)
+		return 1
+	}
+
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(result); err != nil {
+		fmt.Fprintln(stderr, "encode:", err)
+		return 1
+	}
+	return 0
+}
+
+func readRecords(path string) ([]any, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var records []any
+	if err := json.Unmarshal(b, &records); err != nil {
+		return nil, fmt.Errorf("%s must hold a JSON array: %w", path, err)
+	}
+	return records, nil
+}
+
+func writeJSONFile(path string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
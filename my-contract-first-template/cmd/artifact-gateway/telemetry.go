@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const serviceName = "artifact-gateway"
+
+// setupTracing installs an OTLP/HTTP span exporter when OTLP_ENDPOINT is set.
+// Without it the global no-op tracer stays in place.
+func setupTracing(ctx context.Context) (func(context.Context) error, error) {
+	endpoint := os.Getenv("OTLP_ENDPOINT")
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint)}
+	if os.Getenv("OTLP_INSECURE") == "true" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return tp.Shutdown, nil
+}
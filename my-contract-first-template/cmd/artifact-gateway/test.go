@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"my-app/platform/artifact"
+)
+
+// testCommand runs the declarative *.test.yaml cases of a repo.
+func testCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	repoPath := fs.String("repo", envOr("REPO_PATH", "./repo"), "contract repo to test")
+	junitPath := fs.String("junit", "", "write a JUnit XML report to this file")
+	filter := fs.String("run", "", "only run cases whose file or name contains this text")
+	asJSON := fs.Bool("json", false, "print results as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	files, err := artifact.LoadFlowTests(*repoPath)
+	if err != nil {
+		fmt.Fprintln(stderr, "load tests:", err)
+		return 2
+	}
+	if *filter != "" {
+		for _, f := range files {
+			kept := f.Cases[:0]
+			for _, tc := range f.Cases {
+				if strings.Contains(tc.Name, *filter) || strings.Contains(f.Flow, *filter) {
+					kept = append(kept, tc)
+				}
+			}
+			f.Cases = kept
+		}
+	}
+
+	results := artifact.RunFlowTests(context.Background(), *repoPath, files)
+	failed := 0
+	for _, r := range results {
+		if !r.Passed() {
+			failed++
+		}
+	}
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			fmt.Fprintln(stderr, "encode:", err)
+			return 2
+		}
+	} else {
+		printTestResults(stdout, results, failed)
+	}
+
+	if *junitPath != "" {
+		out, err := os.Create(*junitPath)
+		if err != nil {
+			fmt.Fprintln(stderr, "write junit:", err)
+			return 2
+		}
+		defer out.Close()
+		if err := artifact.WriteJUnit(out, results); err != nil {
+			fmt.Fprintln(stderr, "write junit:", err)
+			return 2
+		}
+	}
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func printTestResults(w io.Writer, results []artifact.FlowTestResult, failed int) {
+	for _, r := range results {
+		if r.Passed() {
+			fmt.Fprintf(w, "PASS %s › %s (%s)\n", r.Flow, r.Name, r.Duration.Round(time.Microsecond))
+			continue
+		}
+		fmt.Fprintf(w, "FAIL %s › %s\n", r.Flow, r.Name)
+		if r.Error != "" {
+			fmt.Fprintf(w, "    error: %s\n", r.Error)
+		}
+		for _, f := range r.Failures {
+			fmt.Fprintf(w, "    %s\n", f)
+		}
+	}
+	fmt.Fprintf(w, "\n%d passed, %d failed\n", len(results)-failed, failed)
+}
@@ -0,0 +1,213 @@
+package artifact
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultMaxBodyBytes caps request bodies when neither the endpoint nor the
+// gateway configures a limit.
+const DefaultMaxBodyBytes int64 = 10 << 20
+
+var (
+	// ErrBodyTooLarge is returned when a body exceeds its size limit.
+	ErrBodyTooLarge = errors.New("request body too large")
+	// ErrUnsupportedMediaType is returned for bodies that cannot be decoded.
+	ErrUnsupportedMediaType = errors.New("unsupported content type")
+)
+
+// UploadedFile is a multipart file part. Its content is stored once per
+// digest under the blob directory and exposed to flows as $request.files.
+// Path is relative to the blob directory so host paths do not end up in
+// datasets or fixtures.
+type UploadedFile struct {
+	Field       string `json:"field"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+	Path        string `json:"path"`
+}
+
+// decodeRequestBody decodes r.Body by Content-Type: JSON of any kind (also
+// assumed when no type is sent), URL-encoded and multipart forms, and text.
+// Empty bodies decode to an empty object so $request.body.x stays usable.
+func decodeRequestBody(r *http.Request, opts RequestOptions) (any, map[string][]UploadedFile, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return map[string]any{}, nil, nil
+	}
+	defer r.Body.Close()
+
+	limit := opts.MaxBodyBytes
+	if limit <= 0 {
+		limit = DefaultMaxBodyBytes
+	}
+	body := http.MaxBytesReader(nil, r.Body, limit)
+
+	mediaType := "application/json"
+	var params map[string]string
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		var err error
+		if mediaType, params, err = mime.ParseMediaType(ct); err != nil {
+			return nil, nil, fmt.Errorf("%w: %s", ErrUnsupportedMediaType, ct)
+		}
+	}
+
+	if mediaType == "multipart/form-data" {
+		fields, files, err := readMultipart(multipart.NewReader(body, params["boundary"]), opts.BlobDir)
+		return fields, files, bodyError(err)
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, nil, bodyError(err)
+	}
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		if len(bytes.TrimSpace(data)) == 0 {
+			return map[string]any{}, nil, nil
+		}
+		var v any
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, nil, err
+		}
+		if v == nil {
+			v = map[string]any{}
+		}
+		return v, nil, nil
+	case mediaType == "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(data))
+		if err != nil {
+			return nil, nil, err
+		}
+		return queryToSimple(values), nil, nil
+	case strings.HasPrefix(mediaType, "text/"):
+		return string(data), nil, nil
+	default:
+		return nil, nil, fmt.Errorf("%w: %s", ErrUnsupportedMediaType, mediaType)
+	}
+}
+
+func bodyError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return fmt.Errorf("%w: limit is %d bytes", ErrBodyTooLarge, tooLarge.Limit)
+	}
+	return err
+}
+
+// readMultipart decodes a multipart form. When it fails, the blobs it
+// created are removed again so nothing is left without a reference.
+func readMultipart(mr *multipart.Reader, blobDir string) (_ map[string]any, _ map[string][]UploadedFile, err error) {
+	values := url.Values{}
+	files := map[string][]UploadedFile{}
+	var created []string
+	defer func() {
+		if err != nil {
+			for _, path := range created {
+				_ = os.Remove(path)
+			}
+		}
+	}()
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if part.FileName() == "" {
+			b, err := io.ReadAll(part)
+			if err != nil {
+				return nil, nil, err
+			}
+			values.Add(part.FormName(), string(b))
+			continue
+		}
+		if blobDir == "" {
+			return nil, nil, errors.New("file uploads are not enabled")
+		}
+		f, isNew, err := saveBlob(blobDir, part)
+		if err != nil {
+			return nil, nil, err
+		}
+		if isNew {
+			created = append(created, filepath.Join(blobDir, f.Path))
+		}
+		f.Field = part.FormName()
+		f.Filename = filepath.Base(part.FileName())
+		f.ContentType = part.Header.Get("Content-Type")
+		files[f.Field] = append(files[f.Field], f)
+	}
+	return queryToSimple(values), files, nil
+}
+
+// saveBlob streams r into dir, naming the file after its SHA-256 digest so
+// identical uploads share storage. isNew reports whether the blob was not
+// stored before.
+func saveBlob(dir string, r io.Reader) (f UploadedFile, isNew bool, err error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return UploadedFile{}, false, err
+	}
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return UploadedFile{}, false, err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return UploadedFile{}, false, err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	f = UploadedFile{Size: n, SHA256: sum, Path: sum}
+	if _, err := os.Stat(filepath.Join(dir, sum)); err == nil {
+		return f, false, nil
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, sum)); err != nil {
+		return UploadedFile{}, false, err
+	}
+	return f, true, nil
+}
+
+// filesToSimple exposes uploads the way query values are exposed: a single
+// file per field as an object, several as an array.
+func filesToSimple(files map[string][]UploadedFile) map[string]any {
+	out := make(map[string]any, len(files))
+	for field, list := range files {
+		items := make([]any, len(list))
+		for i, f := range list {
+			items[i] = map[string]any{
+				"field":       f.Field,
+				"filename":    f.Filename,
+				"contentType": f.ContentType,
+				"size":        f.Size,
+				"sha256":      f.SHA256,
+				"path":        f.Path,
+			}
+		}
+		if len(items) == 1 {
+			out[field] = items[0]
+		} else {
+			out[field] = items
+		}
+	}
+	return out
+}
@@ -0,0 +1,120 @@
+package artifact
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeRequestBody(t *testing.T) {
+	cases := []struct {
+		name, contentType, body string
+		want                    any
+	}{
+		{"empty", "", "", map[string]any{}},
+		{"json object without type", "", `{"a":1}`, map[string]any{"a": 1.0}},
+		{"json array", "application/json", `[{"id":"1"},{"id":"2"}]`, []any{map[string]any{"id": "1"}, map[string]any{"id": "2"}}},
+		{"vendor json", "application/vnd.api+json; charset=utf-8", `"x"`, "x"},
+		{"form", "application/x-www-form-urlencoded", "name=Ada&tag=a&tag=b", map[string]any{"name": "Ada", "tag": []any{"a", "b"}}},
+		{"text", "text/plain; charset=utf-8", "hello\n", "hello\n"},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+		if tc.contentType != "" {
+			r.Header.Set("Content-Type", tc.contentType)
+		}
+		got, files, err := decodeRequestBody(r, RequestOptions{})
+		require.NoError(t, err, tc.name)
+		require.Empty(t, files, tc.name)
+		require.Equal(t, tc.want, got, tc.name)
+	}
+}
+
+func TestDecodeRequestBodyRejects(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"a":"`+strings.Repeat("x", 64)+`"}`))
+	_, _, err := decodeRequestBody(r, RequestOptions{MaxBodyBytes: 16})
+	require.True(t, errors.Is(err, ErrBodyTooLarge), err)
+
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("<a/>"))
+	r.Header.Set("Content-Type", "application/xml")
+	_, _, err = decodeRequestBody(r, RequestOptions{})
+	require.True(t, errors.Is(err, ErrUnsupportedMediaType), err)
+}
+
+func TestDecodeMultipartSavesBlobs(t *testing.T) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	require.NoError(t, mw.WriteField("title", "report"))
+	fw, err := mw.CreateFormFile("attachment", "../../report.txt")
+	require.NoError(t, err)
+	_, _ = fw.Write([]byte("quarterly numbers"))
+	require.NoError(t, mw.Close())
+
+	payload := buf.Bytes()
+	blobs := t.TempDir()
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(payload))
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	body, files, err := decodeRequestBody(r, RequestOptions{BlobDir: blobs})
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"title": "report"}, body)
+	require.Len(t, files["attachment"], 1)
+
+	f := files["attachment"][0]
+	require.Equal(t, "report.txt", f.Filename)
+	require.EqualValues(t, 17, f.Size)
+	require.Equal(t, f.SHA256, f.Path, "paths are relative to the blob directory")
+	content, err := os.ReadFile(filepath.Join(blobs, f.Path))
+	require.NoError(t, err)
+	require.Equal(t, "quarterly numbers", string(content))
+
+	simple := filesToSimple(files)
+	require.Equal(t, "report.txt", simple["attachment"].(map[string]any)["filename"])
+
+	r = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(payload))
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	_, _, err = decodeRequestBody(r, RequestOptions{})
+	require.Error(t, err, "uploads need a blob directory")
+}
+
+func TestFailedMultipartRemovesNewBlobs(t *testing.T) {
+	form := func(contents ...string) (*bytes.Buffer, string) {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		for i, c := range contents {
+			fw, err := mw.CreateFormFile(fmt.Sprintf("f%d", i), "f.txt")
+			require.NoError(t, err)
+			_, _ = fw.Write([]byte(c))
+		}
+		require.NoError(t, mw.Close())
+		return &buf, mw.FormDataContentType()
+	}
+	blobs := t.TempDir()
+	decode := func(limit int64, contents ...string) error {
+		body, contentType := form(contents...)
+		r := httptest.NewRequest(http.MethodPost, "/", body)
+		r.Header.Set("Content-Type", contentType)
+		_, _, err := decodeRequestBody(r, RequestOptions{BlobDir: blobs, MaxBodyBytes: limit})
+		return err
+	}
+
+	require.NoError(t, decode(0, "kept"))
+	err := decode(1024, "kept", "new", strings.Repeat("x", 2048))
+	require.ErrorIs(t, err, ErrBodyTooLarge)
+
+	entries, err := os.ReadDir(blobs)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "only the blob stored by the earlier request remains")
+	sum := sha256.Sum256([]byte("kept"))
+	require.Equal(t, hex.EncodeToString(sum[:]), entries[0].Name())
+}
@@ -0,0 +1,178 @@
+package artifact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultBrowseDirs are the repo directories exposed by RepoBrowser when none
+// are configured.
+var DefaultBrowseDirs = []string{"api", "flows", "schemas"}
+
+// RepoBrowser serves a read-only view of allowlisted repo directories.
+// Hidden entries such as .runtime are never served, whatever the allowlist.
+type RepoBrowser struct {
+	root string
+	dirs []string
+}
+
+// BrowseEntry describes one file or directory in a listing.
+type BrowseEntry struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Type string `json:"type"`
+	Size int64  `json:"size,omitempty"`
+}
+
+// NewRepoBrowser exposes dirs of repoPath, or DefaultBrowseDirs when empty.
+func NewRepoBrowser(repoPath string, dirs ...string) *RepoBrowser {
+	if len(dirs) == 0 {
+		dirs = DefaultBrowseDirs
+	}
+	allowed := make([]string, 0, len(dirs))
+	for _, d := range dirs {
+		d = strings.Trim(strings.TrimSpace(d), "/")
+		if d != "" && !strings.HasPrefix(d, ".") && !strings.Contains(d, "/") {
+			allowed = append(allowed, d)
+		}
+	}
+	return &RepoBrowser{root: repoPath, dirs: allowed}
+}
+
+// Handle serves GET and HEAD requests for a route registered as "/prefix/*path".
+func (b *RepoBrowser) Handle(c *gin.Context) {
+	rel := strings.Trim(path.Clean("/"+c.Param("path")), "/")
+	if rel == "" {
+		entries := make([]BrowseEntry, 0, len(b.dirs))
+		for _, d := range b.dirs {
+			if fi, err := os.Stat(filepath.Join(b.root, d)); err == nil && fi.IsDir() {
+				entries = append(entries, BrowseEntry{Name: d, Path: d, Type: "dir"})
+			}
+		}
+		c.JSON(http.StatusOK, map[string]any{"path": "", "entries": entries})
+		return
+	}
+
+	full, ok := b.resolve(rel)
+	if !ok {
+		c.JSON(http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+	fi, err := os.Stat(full)
+	if err != nil {
+		c.JSON(http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+	if fi.IsDir() {
+		b.list(c, rel, full)
+		return
+	}
+	b.serveFile(c, full, fi)
+}
+
+// resolve maps a cleaned relative path to a file inside an allowed directory,
+// rejecting hidden segments and symlinks that escape it.
+func (b *RepoBrowser) resolve(rel string) (string, bool) {
+	segments := strings.Split(rel, "/")
+	for _, s := range segments {
+		if s == "" || strings.HasPrefix(s, ".") {
+			return "", false
+		}
+	}
+	allowed := false
+	for _, d := range b.dirs {
+		if segments[0] == d {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return "", false
+	}
+
+	base, err := filepath.EvalSymlinks(filepath.Join(b.root, segments[0]))
+	if err != nil {
+		return "", false
+	}
+	full, err := filepath.EvalSymlinks(filepath.Join(b.root, filepath.FromSlash(rel)))
+	if err != nil {
+		return "", false
+	}
+	if full != base && !strings.HasPrefix(full, base+string(filepath.Separator)) {
+		return "", false
+	}
+	return full, true
+}
+
+func (b *RepoBrowser) list(c *gin.Context, rel, full string) {
+	items, err := os.ReadDir(full)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to read directory"})
+		return
+	}
+	entries := make([]BrowseEntry, 0, len(items))
+	for _, it := range items {
+		if strings.HasPrefix(it.Name(), ".") {
+			continue
+		}
+		e := BrowseEntry{Name: it.Name(), Path: rel + "/" + it.Name(), Type: "file"}
+		if it.IsDir() {
+			e.Type = "dir"
+		} else if info, infoErr := it.Info(); infoErr == nil {
+			e.Size = info.Size()
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	c.JSON(http.StatusOK, map[string]any{"path": rel, "entries": entries})
+}
+
+func (b *RepoBrowser) serveFile(c *gin.Context, full string, fi os.FileInfo) {
+	f, err := os.Open(full)
+	if err != nil {
+		c.JSON(http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to read file"})
+		return
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to read file"})
+		return
+	}
+
+	c.Header("ETag", `"`+hex.EncodeToString(h.Sum(nil))+`"`)
+	c.Header("Content-Type", browseContentType(full))
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Content-Type-Options", "nosniff")
+	http.ServeContent(c.Writer, c.Request, fi.Name(), fi.ModTime(), f)
+}
+
+func browseContentType(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return "application/json; charset=utf-8"
+	case ".yaml", ".yml":
+		return "application/yaml; charset=utf-8"
+	case ".md":
+		return "text/markdown; charset=utf-8"
+	}
+	if ct := mime.TypeByExtension(filepath.Ext(name)); ct != "" {
+		return ct
+	}
+	return "text/plain; charset=utf-8"
+}
@@ -0,0 +1,80 @@
+package artifact
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func newBrowseRouter(t *testing.T) (*gin.Engine, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	repo := t.TempDir()
+	for name, content := range map[string]string{
+		"api/index.json":          `{"endpoints":[]}`,
+		"flows/a.flow.yaml":       "version: 1\n",
+		"data/seed.users.v1.json": `[]`,
+		".runtime/state/u.json":   `[{"secret":true}]`,
+		"flows/.hidden.yaml":      "x",
+	} {
+		p := filepath.Join(repo, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+	r := gin.New()
+	r.GET("/repo/*path", NewRepoBrowser(repo).Handle)
+	return r, repo
+}
+
+func TestRepoBrowserServesAllowlistedFilesWithETag(t *testing.T) {
+	r, _ := newBrowseRouter(t)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/repo/api/index.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	req := httptest.NewRequest("GET", "/repo/api/index.json", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotModified, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/repo/flows/a.flow.yaml", nil))
+	require.Equal(t, "application/yaml; charset=utf-8", w.Header().Get("Content-Type"))
+}
+
+func TestRepoBrowserHidesRuntimeAndUnlistedDirs(t *testing.T) {
+	r, _ := newBrowseRouter(t)
+
+	for _, p := range []string{
+		"/repo/.runtime/state/u.json",
+		"/repo/data/seed.users.v1.json",
+		"/repo/flows/.hidden.yaml",
+		"/repo/flows/../.runtime/state/u.json",
+		"/repo/api/../../etc/passwd",
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", p, nil))
+		require.Equal(t, http.StatusNotFound, w.Code, p)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/repo/flows", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var listing struct {
+		Entries []BrowseEntry `json:"entries"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listing))
+	require.Len(t, listing.Entries, 1)
+	require.Equal(t, "flows/a.flow.yaml", listing.Entries[0].Path)
+}
@@ -0,0 +1,259 @@
+package artifact
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// BulkResult is the multi-status outcome of bulkInsert, bulkUpdate and
+// bulkDelete. Status is 200 when every item succeeded and 207 when some
+// failed; an atomic op that failed commits nothing and takes the status of
+// its first failed item, reporting the items it would have applied as 424.
+type BulkResult struct {
+	Status    int              `json:"status"`
+	Committed bool             `json:"committed"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Results   []BulkItemResult `json:"results"`
+}
+
+// BulkItemResult reports one item of a bulk op by its index in the input.
+type BulkItemResult struct {
+	Index  int            `json:"index"`
+	ID     string         `json:"id,omitempty"`
+	Status int            `json:"status"`
+	Record map[string]any `json:"record,omitempty"`
+	Error  string         `json:"error,omitempty"`
+}
+
+// bulk applies the items of one bulk op to a working copy of the dataset,
+// its seed while nothing is saved, which commit then saves in a single write.
+type bulk struct {
+	e       *Executor
+	dataset string
+	items   []any
+	atomic  bool
+	schema  any
+	data    []any
+	index   map[string]int // live record id -> position in data
+	removed map[int]bool
+	result  BulkResult
+	changes []historyChange
+	events  []ChangeEvent
+}
+
+func (e *Executor) newBulk(op, itemsArg string, args map[string]any, rt map[string]any) (*bulk, error) {
+	dataset := str(args["dataset"])
+	if dataset == "" {
+		return nil, fmt.Errorf("%s requires dataset name", op)
+	}
+	items, ok := toSlice(getExpr(rt, args[itemsArg], nil))
+	if !ok {
+		return nil, fmt.Errorf("%s requires an array of %s", op, itemsArg)
+	}
+	data, _ := toSlice(e.loadDataset(dataset, ""))
+	b := &bulk{
+		e:       e,
+		dataset: dataset,
+		items:   items,
+		atomic:  args["atomic"] == true,
+		schema:  args["schema"],
+		data:    data,
+		index:   map[string]int{},
+		removed: map[int]bool{},
+		result:  BulkResult{Results: make([]BulkItemResult, 0, len(items))},
+	}
+	if b.schema == nil && e.datasets[dataset].Schema != nil {
+		b.schema = e.datasets[dataset].Schema
+	}
+	for i, it := range b.data {
+		if m, ok := toMap(it); ok && !e.softDeleted(dataset, m) {
+			if id := toString(m["id"]); id != "" {
+				b.index[id] = i
+			}
+		}
+	}
+	return b, nil
+}
+
+// validate checks a record against the op's schema arg or the dataset schema.
+func (b *bulk) validate(record map[string]any) error {
+	if b.schema == nil {
+		return nil
+	}
+	return validateSchema(b.schema, record)
+}
+
+func (b *bulk) fail(i int, id string, err error) {
+	status := http.StatusBadRequest
+	var stepErr *StepError
+	if errors.As(err, &stepErr) {
+		status = stepErr.Status
+	}
+	b.result.Failed++
+	b.result.Results = append(b.result.Results, BulkItemResult{Index: i, ID: id, Status: status, Error: err.Error()})
+}
+
+func (b *bulk) succeed(i, status int, change historyChange, event string, record map[string]any) {
+	b.result.Succeeded++
+	b.result.Results = append(b.result.Results, BulkItemResult{Index: i, ID: change.id, Status: status, Record: deepCopyMap(record)})
+	b.changes = append(b.changes, change)
+	published := change.after
+	if event == ChangeDelete {
+		published = change.before
+	}
+	b.events = append(b.events, ChangeEvent{Type: event, Dataset: b.dataset, RecordID: change.id, Record: deepCopy(published)})
+}
+
+// commit saves the dataset and its history once and publishes the changes,
+// unless an atomic op had a failed item.
+func (b *bulk) commit(clock Clock, rt map[string]any) (any, error) {
+	res := &b.result
+	if b.atomic && res.Failed > 0 {
+		for i := range res.Results {
+			if r := &res.Results[i]; r.Error == "" {
+				r.Status, r.Record, r.Error = http.StatusFailedDependency, nil, "not applied: another item failed"
+			} else if res.Status == 0 {
+				res.Status = r.Status
+			}
+		}
+		res.Succeeded, res.Failed = 0, len(res.Results)
+		return deepCopy(res), nil
+	}
+	if len(b.changes) > 0 {
+		data := make([]any, 0, len(b.data))
+		for i, it := range b.data {
+			if !b.removed[i] {
+				data = append(data, it)
+			}
+		}
+		if err := b.e.saveChanges(clock, rt, b.dataset, data, b.changes); err != nil {
+			return nil, fmt.Errorf("failed to save records: %w", err)
+		}
+		now := clock.Now().UTC()
+		for _, ev := range b.events {
+			ev.Time = now
+			b.e.changes.Publish(ev)
+		}
+	}
+	res.Committed = len(b.changes) > 0
+	res.Status = http.StatusOK
+	if res.Failed > 0 {
+		res.Status = http.StatusMultiStatus
+	}
+	return deepCopy(res), nil
+}
+
+func (e *Executor) opBulkInsert(clock Clock, ids *IDGenerator, args map[string]any, rt map[string]any) (any, error) {
+	defer e.lockDataset(str(args["dataset"]))()
+	b, err := e.newBulk("bulkInsert", "records", args, rt)
+	if err != nil {
+		return nil, err
+	}
+	assign, hasAssign := toMap(args["assignId"])
+	if hasAssign {
+		assign = deepCopyMap(assign)
+		assign["dataset"] = b.dataset
+	}
+	for i, item := range b.items {
+		record, ok := toMap(item)
+		if !ok {
+			b.fail(i, "", errors.New("record must be an object"))
+			continue
+		}
+		record = deepCopyMap(record)
+		if hasAssign && toString(record["id"]) == "" {
+			id, err := assignID(ids, assign, func(string) []any { return b.data })
+			if err != nil {
+				return nil, err
+			}
+			record["id"] = id
+		}
+		id := toString(record["id"])
+		if err := b.validate(record); err != nil {
+			b.fail(i, id, err)
+			continue
+		}
+		if _, taken := b.index[id]; taken && id != "" {
+			b.fail(i, id, &StepError{Status: http.StatusConflict, Msg: fmt.Sprintf("record %s already exists", id)})
+			continue
+		}
+		b.data = append(b.data, record)
+		if id != "" {
+			b.index[id] = len(b.data) - 1
+		}
+		b.succeed(i, http.StatusCreated, historyChange{op: HistoryInsert, id: id, after: record}, ChangeInsert, record)
+	}
+	return b.commit(clock, rt)
+}
+
+func (e *Executor) opBulkUpdate(clock Clock, args map[string]any, rt map[string]any) (any, error) {
+	mode, err := patchMode(toString(getExpr(rt, args["mode"], PatchShallow)))
+	if err != nil {
+		return nil, err
+	}
+	defer e.lockDataset(str(args["dataset"]))()
+	b, err := e.newBulk("bulkUpdate", "updates", args, rt)
+	if err != nil {
+		return nil, err
+	}
+	for i, item := range b.items {
+		update, ok := toMap(item)
+		id := toString(update["id"])
+		if !ok || id == "" {
+			b.fail(i, "", errors.New(`update must be an object {"id": ..., "patch": ...}`))
+			continue
+		}
+		pos, found := b.index[id]
+		if !found {
+			b.fail(i, id, &StepError{Status: http.StatusNotFound, Msg: "record not found"})
+			continue
+		}
+		before, _ := toMap(b.data[pos])
+		updated, err := applyPatch(mode, before, update["patch"])
+		if err == nil && mode != PatchShallow && toString(updated["id"]) != id {
+			err = &StepError{Status: http.StatusUnprocessableEntity, Msg: "patch must not change the record id"}
+		}
+		if err == nil {
+			err = b.validate(updated)
+		}
+		if err != nil {
+			b.fail(i, id, err)
+			continue
+		}
+		b.data[pos] = updated
+		b.succeed(i, http.StatusOK, historyChange{op: HistoryUpdate, id: id, before: before, after: updated}, ChangeUpdate, updated)
+	}
+	return b.commit(clock, rt)
+}
+
+func (e *Executor) opBulkDelete(clock Clock, args map[string]any, rt map[string]any) (any, error) {
+	defer e.lockDataset(str(args["dataset"]))()
+	b, err := e.newBulk("bulkDelete", "ids", args, rt)
+	if err != nil {
+		return nil, err
+	}
+	soft := e.datasets[b.dataset].SoftDelete
+	for i, item := range b.items {
+		id := toString(item)
+		pos, found := b.index[id]
+		if id == "" || !found {
+			b.fail(i, id, &StepError{Status: http.StatusNotFound, Msg: "record not found"})
+			continue
+		}
+		deleted, _ := toMap(b.data[pos])
+		var after map[string]any
+		if soft {
+			after = deepCopyMap(deleted)
+			after[DeletedAtField] = clock.Now().UTC().Format(time.RFC3339)
+			b.data[pos] = after
+		} else {
+			b.removed[pos] = true
+		}
+		delete(b.index, id)
+		b.succeed(i, http.StatusNoContent, historyChange{op: HistoryDelete, id: id, before: deleted, after: after}, ChangeDelete, nil)
+	}
+	return b.commit(clock, rt)
+}
@@ -0,0 +1,142 @@
+package artifact
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// bulkFlows call the bulk ops, each with the args it takes.
+var bulkFlows = map[string]string{
+	"insert.flow.yaml": `
+steps:
+  - op: bulkInsert
+    args: { dataset: users, records: "$request.body", assignId: { strategy: sequence, prefix: "u" } }
+    out: result
+  - op: respond
+    args: { status: "$ctx.result.status", bodyFrom: "$ctx.result" }
+`,
+	"insert-atomic.flow.yaml": `
+steps:
+  - op: bulkInsert
+    args: { dataset: users, records: "$request.body", atomic: true }
+    out: result
+  - op: respond
+    args: { status: "$ctx.result.status", bodyFrom: "$ctx.result" }
+`,
+	"update.flow.yaml": `
+steps:
+  - op: bulkUpdate
+    args:
+      dataset: users
+      updates: "$request.body"
+      mode: merge-patch
+      schema: { type: object, required: [name] }
+    out: result
+  - op: respond
+    args: { status: "$ctx.result.status", bodyFrom: "$ctx.result" }
+`,
+	"delete.flow.yaml": `
+steps:
+  - op: bulkDelete
+    args: { dataset: users, ids: "$request.body", atomic: true }
+    out: result
+  - op: respond
+    args: { status: "$ctx.result.status", bodyFrom: "$ctx.result" }
+`,
+}
+
+func bulkExecutor(t *testing.T) (*Executor, *MemoryStore) {
+	t.Helper()
+	return usersExecutor(t, DatasetDef{
+		History: true,
+		Schema: map[string]any{
+			"type":       "object",
+			"required":   []any{"name"},
+			"properties": map[string]any{"name": map[string]any{"type": "string", "minLength": 1}},
+		},
+	}, bulkFlows)
+}
+
+func runBulk(t *testing.T, exec *Executor, flow string, body any) (int, map[string]any) {
+	t.Helper()
+	res, err := exec.Run(context.Background(), flow+".flow.yaml", &ExecRequest{RequestID: "req-bulk", Method: "POST", Body: body})
+	require.NoError(t, err)
+	return res.Status, res.Body.(map[string]any)
+}
+
+func itemStatuses(body map[string]any) []float64 {
+	var out []float64
+	for _, r := range body["results"].([]any) {
+		out = append(out, r.(map[string]any)["status"].(float64))
+	}
+	return out
+}
+
+func TestBulkOpsReportEachItem(t *testing.T) {
+	exec, store := bulkExecutor(t)
+
+	status, body := runBulk(t, exec, "insert", []any{
+		map[string]any{"name": "Cid"},
+		map[string]any{"id": "u1", "name": "Dup"},
+		map[string]any{"name": ""},
+		"nope",
+		map[string]any{"name": "Dee"},
+	})
+	require.Equal(t, 207, status)
+	require.Equal(t, []float64{201, 409, 400, 400, 201}, itemStatuses(body))
+	require.Equal(t, float64(2), body["succeeded"])
+	require.Equal(t, true, body["committed"])
+	results := body["results"].([]any)
+	require.Equal(t, "u3", results[0].(map[string]any)["id"])
+	require.Equal(t, "u5", results[4].(map[string]any)["id"], "rejected records still draw an id")
+	require.Contains(t, results[2].(map[string]any)["error"], "validation failed")
+
+	status, body = runBulk(t, exec, "update", []any{
+		map[string]any{"id": "u3", "patch": map[string]any{"name": "Cyd", "team": "ops"}},
+		map[string]any{"id": "u9", "patch": map[string]any{"name": "Ghost"}},
+		map[string]any{"id": "u5", "patch": map[string]any{"name": nil}},
+	})
+	require.Equal(t, 207, status)
+	require.Equal(t, []float64{200, 404, 400}, itemStatuses(body))
+
+	status, body = runBulk(t, exec, "delete", []any{"u2", "u5"})
+	require.Equal(t, 200, status)
+	require.Equal(t, []float64{204, 204}, itemStatuses(body))
+
+	saved, _, _ := store.Load("users")
+	require.Equal(t, []any{
+		map[string]any{"id": "u1", "name": "Ann", "role": "admin"},
+		map[string]any{"id": "u3", "name": "Cyd", "team": "ops"},
+	}, saved)
+	history, _, _ := store.Load("users" + HistorySuffix)
+	require.Len(t, history, 5, "two inserts, one update and two deletes")
+}
+
+func TestAtomicBulkCommitsNothingOnFailure(t *testing.T) {
+	exec, store := bulkExecutor(t)
+	feed := NewChangeFeed(0)
+	exec.changes = feed
+	_, events, _, cancel := feed.Subscribe(0)
+	defer cancel()
+
+	status, body := runBulk(t, exec, "insert-atomic", []any{
+		map[string]any{"name": "Cid"},
+		map[string]any{"id": "u2", "name": "Dup"},
+	})
+	require.Equal(t, 409, status)
+	require.Equal(t, false, body["committed"])
+	require.Equal(t, []float64{424, 409}, itemStatuses(body))
+	saved, _, _ := store.Load("users")
+	require.Len(t, saved, 2)
+	_, found, _ := store.Load("users" + HistorySuffix)
+	require.False(t, found)
+
+	status, body = runBulk(t, exec, "delete", []any{"u1", "u2"})
+	require.Equal(t, 200, status)
+	require.Equal(t, true, body["committed"])
+	saved, _, _ = store.Load("users")
+	require.Empty(t, saved)
+	require.Equal(t, ChangeDelete, (<-events).Type, "only the committed batch is published")
+}
@@ -0,0 +1,336 @@
+package artifact
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Bundle layout: the repo files plus a manifest of their digests and a
+// detached ed25519 signature of the manifest.
+const (
+	BundleManifestFile  = "manifest.json"
+	BundleSignatureFile = "manifest.sig"
+)
+
+// BundleDirs are the repo directories packed into a bundle. Runtime state
+// and anything else in the repo stays out.
+var BundleDirs = []string{"api", "flows", "data", "schemas", "migrations"}
+
+// maxBundleBytes caps the unpacked size so a crafted archive cannot
+// exhaust memory.
+const maxBundleBytes = 256 << 20
+
+var (
+	// ErrBundleSignature means no configured key verifies the manifest.
+	ErrBundleSignature = errors.New("bundle signature is not valid for any configured key")
+	// ErrBundleTampered means the archive content does not match its manifest.
+	ErrBundleTampered = errors.New("bundle content does not match its manifest")
+)
+
+// BundleManifest lists every packed file with its SHA-256 digest.
+type BundleManifest struct {
+	Version int               `json:"version"`
+	Created time.Time         `json:"created"`
+	Files   map[string]string `json:"files"`
+}
+
+// BundleSignature is the content of manifest.sig.
+type BundleSignature struct {
+	KeyID     string `json:"keyId"`
+	Signature []byte `json:"signature"`
+}
+
+// Bundle is a verified bundle held in memory.
+type Bundle struct {
+	Manifest BundleManifest
+	KeyID    string
+	files    map[string][]byte
+}
+
+// KeyID names a public key by the first 8 bytes of its SHA-256 digest.
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// WriteBundle packs the bundle directories of repoPath into w as a signed
+// tar.gz. Entries are sorted and carry the manifest time, so the same repo,
+// key and time produce the same archive.
+func WriteBundle(w io.Writer, repoPath string, key ed25519.PrivateKey, created time.Time) (*BundleManifest, error) {
+	created = created.UTC().Truncate(time.Second)
+	files := map[string][]byte{}
+	for _, dir := range BundleDirs {
+		root := filepath.Join(repoPath, dir)
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) && p == root {
+					return nil
+				}
+				return err
+			}
+			if strings.HasPrefix(d.Name(), ".") && p != root {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(repoPath, p)
+			if err != nil {
+				return err
+			}
+			b, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			files[filepath.ToSlash(rel)] = b
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s has none of %v", repoPath, BundleDirs)
+	}
+
+	manifest := &BundleManifest{Version: 1, Created: created, Files: map[string]string{}}
+	for name, b := range files {
+		sum := sha256.Sum256(b)
+		manifest.Files[name] = hex.EncodeToString(sum[:])
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	sig, err := json.Marshal(BundleSignature{
+		KeyID:     KeyID(key.Public().(ed25519.PublicKey)),
+		Signature: ed25519.Sign(key, manifestJSON),
+	})
+	if err != nil {
+		return nil, err
+	}
+	files[BundleManifestFile] = manifestJSON
+	files[BundleSignatureFile] = sig
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, name := range names {
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(files[name])), ModTime: created, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, gz.Close()
+}
+
+// OpenBundle reads a bundle and verifies it: the manifest must be signed by
+// one of keys, and the archive must hold exactly the files it lists with
+// matching digests.
+func OpenBundle(r io.Reader, keys []ed25519.PublicKey) (*Bundle, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no public keys configured to verify the bundle")
+	}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("read bundle: %w", err)
+	}
+	tr := tar.NewReader(gz)
+	files := map[string][]byte{}
+	var total int64
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read bundle: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("%w: %s is not a regular file", ErrBundleTampered, hdr.Name)
+		}
+		if _, dup := files[hdr.Name]; dup {
+			return nil, fmt.Errorf("%w: %s appears twice", ErrBundleTampered, hdr.Name)
+		}
+		total += hdr.Size
+		if total > maxBundleBytes {
+			return nil, fmt.Errorf("read bundle: larger than %d bytes", maxBundleBytes)
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("read bundle: %w", err)
+		}
+		files[hdr.Name] = b
+	}
+
+	manifestJSON, sigJSON := files[BundleManifestFile], files[BundleSignatureFile]
+	if manifestJSON == nil || sigJSON == nil {
+		return nil, fmt.Errorf("%w: %s or %s is missing", ErrBundleTampered, BundleManifestFile, BundleSignatureFile)
+	}
+	var sig BundleSignature
+	if err := json.Unmarshal(sigJSON, &sig); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrBundleTampered, BundleSignatureFile, err)
+	}
+	verified := false
+	for _, k := range keys {
+		if ed25519.Verify(k, manifestJSON, sig.Signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("%w (signed by key %s)", ErrBundleSignature, sig.KeyID)
+	}
+
+	b := &Bundle{KeyID: sig.KeyID, files: map[string][]byte{}}
+	if err := json.Unmarshal(manifestJSON, &b.Manifest); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrBundleTampered, BundleManifestFile, err)
+	}
+	delete(files, BundleManifestFile)
+	delete(files, BundleSignatureFile)
+	for name, want := range b.Manifest.Files {
+		if !validBundlePath(name) {
+			return nil, fmt.Errorf("%w: invalid path %s", ErrBundleTampered, name)
+		}
+		content, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is missing", ErrBundleTampered, name)
+		}
+		sum := sha256.Sum256(content)
+		if hex.EncodeToString(sum[:]) != want {
+			return nil, fmt.Errorf("%w: digest of %s differs", ErrBundleTampered, name)
+		}
+		b.files[name] = content
+	}
+	for name := range files {
+		if _, ok := b.Manifest.Files[name]; !ok {
+			return nil, fmt.Errorf("%w: %s is not in the manifest", ErrBundleTampered, name)
+		}
+	}
+	return b, nil
+}
+
+// validBundlePath accepts clean relative paths inside a bundle directory.
+func validBundlePath(name string) bool {
+	if name != path.Clean(name) || path.IsAbs(name) || strings.HasPrefix(name, "../") {
+		return false
+	}
+	dir, _, ok := strings.Cut(name, "/")
+	return ok && containsString(BundleDirs, dir)
+}
+
+// Extract writes the bundle into dir as a repo. The bundle directories are
+// replaced as a whole; .runtime and other content of dir is kept, so dataset
+// state survives redeploys.
+func (b *Bundle) Extract(dir string) error {
+	for _, d := range BundleDirs {
+		if err := os.RemoveAll(filepath.Join(dir, d)); err != nil {
+			return err
+		}
+	}
+	for name, content := range b.files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(p, content, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParsePublicKeys reads every PEM "PUBLIC KEY" block holding an ed25519 key.
+func ParsePublicKeys(data []byte) ([]ed25519.PublicKey, error) {
+	var keys []ed25519.PublicKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "PUBLIC KEY" {
+			continue
+		}
+		k, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		pub, ok := k.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("public key is %T, not ed25519", k)
+		}
+		keys = append(keys, pub)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no PEM public key found")
+	}
+	return keys, nil
+}
+
+// ParsePrivateKey reads a PEM PKCS #8 ed25519 private key.
+func ParsePrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM private key found")
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	priv, ok := k.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is %T, not ed25519", k)
+	}
+	return priv, nil
+}
+
+// MarshalKeyPair encodes a key pair as PEM, private key first.
+func MarshalKeyPair(pub ed25519.PublicKey, priv ed25519.PrivateKey) ([]byte, []byte, error) {
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, nil, err
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, nil, err
+	}
+	var privPEM, pubPEM bytes.Buffer
+	if err := pem.Encode(&privPEM, &pem.Block{Type: "PRIVATE KEY", Bytes: privDER}); err != nil {
+		return nil, nil, err
+	}
+	if err := pem.Encode(&pubPEM, &pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}); err != nil {
+		return nil, nil, err
+	}
+	return privPEM.Bytes(), pubPEM.Bytes(), nil
+}
@@ -0,0 +1,154 @@
+package artifact
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testBundleRepo(t *testing.T) string {
+	t.Helper()
+	repo := t.TempDir()
+	writeTestFlow(t, repo, "ping.flow.yaml", "steps:\n  - op: respond\n")
+	require.NoError(t, os.MkdirAll(filepath.Join(repo, "api"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "api", "index.json"), []byte(`{"endpoints": []}`), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(repo, ".runtime", "state"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(repo, ".runtime", "state", "users.json"), []byte(`[]`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "flows", ".draft.flow.yaml"), []byte("x"), 0o644))
+	return repo
+}
+
+// rewriteBundle copies a bundle, letting edit replace or drop (nil) entries
+// and add extra ones.
+func rewriteBundle(t *testing.T, src []byte, edit func(name string, b []byte) []byte, extra map[string][]byte) []byte {
+	t.Helper()
+	gr, err := gzip.NewReader(bytes.NewReader(src))
+	require.NoError(t, err)
+	tr := tar.NewReader(gr)
+	var out bytes.Buffer
+	gw := gzip.NewWriter(&out)
+	tw := tar.NewWriter(gw)
+	write := func(name string, b []byte) {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(b)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write(b)
+		require.NoError(t, err)
+	}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		b, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		if b = edit(hdr.Name, b); b != nil {
+			write(hdr.Name, b)
+		}
+	}
+	for name, b := range extra {
+		write(name, b)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+	return out.Bytes()
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	repo := testBundleRepo(t)
+
+	var buf bytes.Buffer
+	created := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	manifest, err := WriteBundle(&buf, repo, priv, created)
+	require.NoError(t, err)
+	require.Len(t, manifest.Files, 2, "hidden files and .runtime stay out")
+	require.Contains(t, manifest.Files, "flows/ping.flow.yaml")
+
+	var again bytes.Buffer
+	_, err = WriteBundle(&again, repo, priv, created)
+	require.NoError(t, err)
+	require.Equal(t, buf.Bytes(), again.Bytes(), "bundles are reproducible")
+
+	b, err := OpenBundle(bytes.NewReader(buf.Bytes()), []ed25519.PublicKey{pub})
+	require.NoError(t, err)
+	require.Equal(t, KeyID(pub), b.KeyID)
+
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "flows"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "flows", "stale.flow.yaml"), []byte("x"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".runtime"), 0o755))
+	require.NoError(t, b.Extract(dir))
+	_, err = LoadFlow(dir, "ping.flow.yaml")
+	require.NoError(t, err)
+	require.NoFileExists(t, filepath.Join(dir, "flows", "stale.flow.yaml"))
+	require.DirExists(t, filepath.Join(dir, ".runtime"))
+
+	privPEM, pubPEM, err := MarshalKeyPair(pub, priv)
+	require.NoError(t, err)
+	parsedPriv, err := ParsePrivateKey(privPEM)
+	require.NoError(t, err)
+	require.Equal(t, priv, parsedPriv)
+	parsedPub, err := ParsePublicKeys(append(pubPEM, pubPEM...))
+	require.NoError(t, err)
+	require.Equal(t, []ed25519.PublicKey{pub, pub}, parsedPub)
+}
+
+func TestOpenBundleRejectsTampering(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	_, err = WriteBundle(&buf, testBundleRepo(t), priv, time.Now())
+	require.NoError(t, err)
+	keep := func(_ string, b []byte) []byte { return b }
+	open := func(data []byte, keys ...ed25519.PublicKey) error {
+		_, err := OpenBundle(bytes.NewReader(data), keys)
+		return err
+	}
+
+	require.ErrorIs(t, open(buf.Bytes(), otherPub), ErrBundleSignature)
+	require.Error(t, open(buf.Bytes()))
+
+	edited := rewriteBundle(t, buf.Bytes(), func(name string, b []byte) []byte {
+		if name == "flows/ping.flow.yaml" {
+			return []byte("steps:\n  - op: respond\n    args: { status: 500 }\n")
+		}
+		return b
+	}, nil)
+	require.ErrorIs(t, open(edited, pub), ErrBundleTampered)
+
+	extra := rewriteBundle(t, buf.Bytes(), keep, map[string][]byte{"flows/evil.flow.yaml": []byte("x")})
+	require.ErrorIs(t, open(extra, pub), ErrBundleTampered)
+
+	missing := rewriteBundle(t, buf.Bytes(), func(name string, b []byte) []byte {
+		if name == "api/index.json" {
+			return nil
+		}
+		return b
+	}, nil)
+	require.ErrorIs(t, open(missing, pub), ErrBundleTampered)
+
+	forged := rewriteBundle(t, buf.Bytes(), func(name string, b []byte) []byte {
+		if name == BundleManifestFile {
+			return bytes.Replace(b, []byte(`"version": 1`), []byte(`"version": 2`), 1)
+		}
+		return b
+	}, nil)
+	require.ErrorIs(t, open(forged, pub), ErrBundleSignature)
+
+	require.True(t, validBundlePath("flows/a.yaml"))
+	for _, p := range []string{"../etc/passwd", "/flows/a", "flows/../../x", "secrets/a", "flows"} {
+		require.False(t, validBundlePath(p), p)
+	}
+}
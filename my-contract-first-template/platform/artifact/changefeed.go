@@ -0,0 +1,248 @@
+package artifact
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Change event types published by the record ops.
+const (
+	ChangeInsert = "insert"
+	ChangeUpdate = "update"
+	ChangeDelete = "delete"
+	// ChangeReset tells a resuming client that events were missed and it
+	// should refetch instead of applying deltas.
+	ChangeReset = "reset"
+)
+
+// ChangeEvent describes one write to a dataset.
+type ChangeEvent struct {
+	ID       uint64    `json:"id"`
+	Type     string    `json:"type"`
+	Dataset  string    `json:"dataset"`
+	RecordID string    `json:"recordId,omitempty"`
+	Record   any       `json:"record,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// ChangeFeed fans change events out to subscribers and keeps the last
+// events in a bounded log so clients can resume with Last-Event-ID.
+// IDs start at 1 and only grow within a process.
+type ChangeFeed struct {
+	mu     sync.Mutex
+	log    []ChangeEvent
+	size   int
+	lastID uint64
+	subs   map[chan ChangeEvent]struct{}
+	closed bool
+	// clock stamps events published without a time; NewExecutor sets it to
+	// the executor's clock.
+	clock Clock
+}
+
+// NewChangeFeed keeps up to size events for resumption.
+func NewChangeFeed(size int) *ChangeFeed {
+	if size <= 0 {
+		size = 1000
+	}
+	return &ChangeFeed{size: size, subs: map[chan ChangeEvent]struct{}{}}
+}
+
+func (f *ChangeFeed) now() time.Time {
+	if f.clock == nil {
+		return time.Now().UTC()
+	}
+	return f.clock.Now().UTC()
+}
+
+// Publish assigns ev the next ID and delivers it. A subscriber whose buffer
+// is full is dropped; it reconnects and resumes from its last event ID.
+// A nil feed ignores events.
+func (f *ChangeFeed) Publish(ev ChangeEvent) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastID++
+	ev.ID = f.lastID
+	if ev.Time.IsZero() {
+		ev.Time = f.now()
+	}
+	if len(f.log) == f.size {
+		copy(f.log, f.log[1:])
+		f.log = f.log[:f.size-1]
+	}
+	f.log = append(f.log, ev)
+	for ch := range f.subs {
+		select {
+		case ch <- ev:
+		default:
+			delete(f.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe returns the retained events after afterID and a channel of new
+// ones. complete is false when events after afterID were already evicted
+// or afterID is unknown to this process. cancel must be called when done.
+func (f *ChangeFeed) Subscribe(afterID uint64) (backlog []ChangeEvent, live <-chan ChangeEvent, complete bool, cancel func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	complete = afterID <= f.lastID
+	if len(f.log) > 0 && afterID+1 < f.log[0].ID {
+		complete = false
+	}
+	if complete {
+		for _, ev := range f.log {
+			if ev.ID > afterID {
+				backlog = append(backlog, ev)
+			}
+		}
+	}
+	ch := make(chan ChangeEvent, 64)
+	if f.closed {
+		close(ch)
+		return backlog, ch, complete, func() {}
+	}
+	f.subs[ch] = struct{}{}
+	return backlog, ch, complete, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if _, ok := f.subs[ch]; ok {
+			delete(f.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Close ends every subscription, and those made later, so streams return.
+// Events are still logged.
+func (f *ChangeFeed) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	for ch := range f.subs {
+		delete(f.subs, ch)
+		close(ch)
+	}
+}
+
+// streamHeartbeat keeps idle SSE connections open through proxies.
+var streamHeartbeat = 15 * time.Second
+
+// StreamHandler serves a stream endpoint as server-sent events. Events are
+// limited to def.Dataset when set and to those matching def.Filter, which is
+// evaluated like a step's when against $event, $record and $request.
+// A nil logger uses slog.Default.
+func StreamHandler(feed *ChangeFeed, def EndpointDef, logger *slog.Logger) gin.HandlerFunc {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return func(c *gin.Context) {
+		var after uint64
+		resume := c.GetHeader("Last-Event-ID")
+		if resume == "" {
+			resume = c.Query("lastEventId")
+		}
+		if resume != "" {
+			id, err := strconv.ParseUint(resume, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid Last-Event-ID"})
+				return
+			}
+			after = id
+		}
+		request := map[string]any{
+			"params":  stringMapToAny(paramsOf(c)),
+			"query":   queryToSimple(c.Request.URL.Query()),
+			"headers": queryToSimple(c.Request.Header),
+		}
+		match := func(ev ChangeEvent) bool {
+			if def.Dataset != "" && ev.Dataset != def.Dataset && ev.Type != ChangeReset {
+				return false
+			}
+			if def.Filter == "" || ev.Type == ChangeReset {
+				return true
+			}
+			ok, err := evalCondition(def.Filter, map[string]any{
+				"event":   map[string]any{"id": ev.ID, "type": ev.Type, "dataset": ev.Dataset, "recordId": ev.RecordID},
+				"record":  deepCopy(ev.Record),
+				"request": request,
+			})
+			return err == nil && ok
+		}
+
+		backlog, live, complete, cancel := feed.Subscribe(after)
+		defer cancel()
+
+		w := c.Writer
+		// Streams outlive the server's write timeout by design. Writers that
+		// cannot lift it end the stream at the timeout instead.
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+			logger.Warn("stream keeps the server write timeout", "path", c.FullPath(), "error", err.Error())
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		// New clients only get live events; resuming ones get what they missed.
+		if resume != "" {
+			if !complete {
+				writeEvent(w, ChangeEvent{Type: ChangeReset, Time: feed.now()})
+			}
+			for _, ev := range backlog {
+				if match(ev) {
+					writeEvent(w, ev)
+				}
+			}
+		}
+		w.Flush()
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case ev, ok := <-live:
+				if !ok {
+					return
+				}
+				if match(ev) {
+					writeEvent(w, ev)
+					w.Flush()
+				}
+			case <-heartbeat.C:
+				_, _ = fmt.Fprint(w, ": ping\n\n")
+				w.Flush()
+			}
+		}
+	}
+}
+
+func writeEvent(w gin.ResponseWriter, ev ChangeEvent) {
+	data, _ := json.Marshal(ev)
+	if ev.ID > 0 {
+		_, _ = fmt.Fprintf(w, "id: %d\n", ev.ID)
+	}
+	_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data)
+}
+
+func paramsOf(c *gin.Context) map[string]string {
+	params := map[string]string{}
+	for _, p := range c.Params {
+		params[p.Key] = p.Value
+	}
+	return params
+}
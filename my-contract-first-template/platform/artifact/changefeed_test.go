@@ -0,0 +1,157 @@
+package artifact
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeFeedBacklog(t *testing.T) {
+	feed := NewChangeFeed(3)
+	for i := 0; i < 5; i++ {
+		feed.Publish(ChangeEvent{Type: ChangeInsert, Dataset: "users"})
+	}
+
+	backlog, _, complete, cancel := feed.Subscribe(3)
+	cancel()
+	require.True(t, complete)
+	require.Len(t, backlog, 2)
+	require.EqualValues(t, 4, backlog[0].ID)
+
+	_, _, complete, cancel = feed.Subscribe(1)
+	cancel()
+	require.False(t, complete, "event 2 was evicted")
+
+	_, _, complete, cancel = feed.Subscribe(9)
+	cancel()
+	require.False(t, complete, "ID from another process")
+}
+
+func TestChangeFeedCloseEndsSubscriptions(t *testing.T) {
+	feed := NewChangeFeed(3)
+	_, live, _, cancel := feed.Subscribe(0)
+	defer cancel()
+	feed.Close()
+	_, ok := <-live
+	require.False(t, ok)
+
+	feed.Publish(ChangeEvent{Type: ChangeInsert, Dataset: "users"})
+	backlog, live, _, cancel := feed.Subscribe(0)
+	defer cancel()
+	require.Len(t, backlog, 1)
+	_, ok = <-live
+	require.False(t, ok)
+}
+
+// readEvents reads n SSE events, skipping heartbeats.
+func readEvents(t *testing.T, r *bufio.Reader, n int) []ChangeEvent {
+	t.Helper()
+	var events []ChangeEvent
+	for len(events) < n {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		if data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: "); ok {
+			var ev ChangeEvent
+			require.NoError(t, json.Unmarshal([]byte(data), &ev))
+			events = append(events, ev)
+		}
+	}
+	return events
+}
+
+func TestStreamHandlerDeliversFilteredChanges(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := t.TempDir()
+	writeTestFlow(t, repo, "notes.flow.yaml", recordFlow)
+	feed := NewChangeFeed(10)
+	exec := NewExecutor(repo, WithStore(NewMemoryStore(nil)), WithChangeFeed(feed))
+
+	r := gin.New()
+	r.GET("/notes/stream", StreamHandler(feed, EndpointDef{Type: EndpointStream, Dataset: "notes", Filter: "$record.public == true"}, nil))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/notes/stream", nil)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	insert := func(body map[string]any) {
+		_, err := exec.Run(context.Background(), "notes.flow.yaml", &ExecRequest{Body: body})
+		require.NoError(t, err)
+	}
+	insert(map[string]any{"id": "hidden", "public": false})
+	insert(map[string]any{"id": "shown", "public": true})
+	feed.Publish(ChangeEvent{Type: ChangeInsert, Dataset: "other", Record: map[string]any{"public": true}})
+	insert(map[string]any{"id": "shown-2", "public": true})
+
+	events := readEvents(t, bufio.NewReader(resp.Body), 2)
+	require.Equal(t, "shown", events[0].RecordID)
+	require.Equal(t, "shown-2", events[1].RecordID)
+	require.EqualValues(t, 2, events[0].ID)
+
+	// Resuming after the first shown event replays only what was missed.
+	cancel()
+	req, _ = http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL+"/notes/stream", nil)
+	req.Header.Set("Last-Event-ID", "2")
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	resp2, err := http.DefaultClient.Do(req.WithContext(ctx2))
+	require.NoError(t, err)
+	defer resp2.Body.Close()
+	events = readEvents(t, bufio.NewReader(resp2.Body), 1)
+	require.Equal(t, "shown-2", events[0].RecordID)
+}
+
+func TestStreamHandlerSignalsResetWhenLogIsGone(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	feed := NewChangeFeed(1)
+	feed.Publish(ChangeEvent{Type: ChangeInsert, Dataset: "notes"})
+	feed.Publish(ChangeEvent{Type: ChangeInsert, Dataset: "notes"})
+
+	r := gin.New()
+	r.GET("/stream", StreamHandler(feed, EndpointDef{Type: EndpointStream}, nil))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/stream?lastEventId=0", nil)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	events := readEvents(t, bufio.NewReader(resp.Body), 1)
+	require.Equal(t, ChangeReset, events[0].Type)
+}
+
+func TestRecordEventsUseTheExecutorClock(t *testing.T) {
+	repo := t.TempDir()
+	writeTestFlow(t, repo, "insert.flow.yaml", `
+steps:
+  - op: insertRecord
+    args: { dataset: notes, record: { id: n1 } }
+`)
+	frozen := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	feed := NewChangeFeed(10)
+	exec := NewExecutor(repo, WithStore(NewMemoryStore(nil)), WithClock(NewManualClock(frozen)), WithChangeFeed(feed))
+	_, err := exec.Run(context.Background(), "insert.flow.yaml", &ExecRequest{Method: "POST"})
+	require.NoError(t, err)
+	feed.Publish(ChangeEvent{Type: ChangeReset})
+
+	backlog, _, _, cancel := feed.Subscribe(0)
+	defer cancel()
+	require.Len(t, backlog, 2)
+	require.Equal(t, frozen, backlog[0].Time)
+	require.Equal(t, frozen, backlog[1].Time, "events without a time get the executor clock")
+}
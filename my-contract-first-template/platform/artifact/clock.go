@@ -0,0 +1,86 @@
+package artifact
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the time source for scheduling so tests can control it.
+type Clock interface {
+	Now() time.Time
+	// After fires once the clock has advanced by d.
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// SystemClock returns the wall clock.
+func SystemClock() Clock { return systemClock{} }
+
+// ManualClock only moves when told to. Timers created with After fire
+// during Advance or Set once their deadline is reached.
+type ManualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []manualWaiter
+}
+
+type manualWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// NewManualClock starts a manual clock at t.
+func NewManualClock(t time.Time) *ManualClock {
+	return &ManualClock{now: t}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *ManualClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, manualWaiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward by d.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Set moves the clock to t and fires every timer that is due.
+func (c *ManualClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(t) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- t
+	}
+	c.waiters = pending
+}
+
+// Waiters reports how many timers are pending, letting tests wait until a
+// goroutine is blocked on the clock before advancing it.
+func (c *ManualClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
@@ -0,0 +1,144 @@
+package artifact
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a parsed cron expression: the five standard fields
+// (minute hour day-of-month month day-of-week) with *, lists, ranges and
+// steps, the @hourly-style descriptors, or "@every <duration>".
+type CronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+	every                         time.Duration
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron parses expr.
+func ParseCron(expr string) (*CronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if rest, ok := strings.CutPrefix(expr, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil || d < time.Second {
+			return nil, fmt.Errorf("cron %q: @every needs a duration of at least 1s", expr)
+		}
+		return &CronSchedule{every: d}, nil
+	}
+	if std, ok := cronDescriptors[expr]; ok {
+		expr = std
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron %q: want 5 fields, got %d", expr, len(fields))
+	}
+	s := &CronSchedule{}
+	var err error
+	bounds := []struct {
+		dst      *uint64
+		min, max int
+	}{
+		{&s.minute, 0, 59}, {&s.hour, 0, 23}, {&s.dom, 1, 31}, {&s.month, 1, 12}, {&s.dow, 0, 7},
+	}
+	for i, b := range bounds {
+		if *b.dst, err = parseCronField(fields[i], b.min, b.max); err != nil {
+			return nil, fmt.Errorf("cron %q: %w", expr, err)
+		}
+	}
+	// Sunday may be written as 0 or 7.
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = fields[2] == "*" || fields[2] == "?"
+	s.dowStar = fields[4] == "*" || fields[4] == "?"
+	return s, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if r, st, ok := strings.Cut(part, "/"); ok {
+			n, err := strconv.Atoi(st)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			rangePart, step = r, n
+		}
+		lo, hi := min, max
+		switch {
+		case rangePart == "*" || rangePart == "?":
+		case strings.Contains(rangePart, "-"):
+			a, b, _ := strings.Cut(rangePart, "-")
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(a)
+			hi, err2 = strconv.Atoi(b)
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+		default:
+			n, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			lo, hi = n, n
+			if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Next returns the first activation strictly after t, in t's location.
+// It returns the zero time if none exists within five years.
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.Add(s.every)
+	}
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches follows cron: when both day fields are restricted, either one
+// matching is enough.
+func (s *CronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -5,22 +5,136 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/xeipuuv/gojsonschema"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func NewExecutor(repoPath string) *Executor { return &Executor{repoPath: repoPath} }
+var tracer = otel.Tracer("my-app/platform/artifact")
+
+func NewExecutor(repoPath string, opts ...ExecutorOption) *Executor {
+	e := &Executor{
+		repoPath: repoPath,
+		store:    NewFileStore(filepath.Join(repoPath, ".runtime", "state")),
+		logger:   slog.Default(),
+		redactor: DefaultRedactor(),
+		flows:    newFlowCache(),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if e.clock == nil {
+		e.clock = SystemClock()
+	}
+	if e.ids == nil {
+		e.ids = NewIDGenerator(e.clock, nil)
+	}
+	if e.changes != nil && e.changes.clock == nil {
+		e.changes.clock = e.clock
+	}
+	if e.datasets == nil {
+		if reg, err := LoadRegistry(filepath.Join(repoPath, "api", "index.json")); err == nil {
+			e.datasets = reg.Datasets
+		}
+	}
+	return e
+}
 
 type Executor struct {
 	repoPath string
+	store    Store
+	stateMu  sync.RWMutex
+	// recordMu holds a *sync.Mutex per dataset; see lockDataset.
+	recordMu sync.Map
+	metrics  *Metrics
+	logger   *slog.Logger
+	redactor *Redactor
+	flows    *flowCache
+	changes  *ChangeFeed
+	events   *EventBus
+	clock    Clock
+	ids      *IDGenerator
+	datasets map[string]DatasetDef
+}
+
+// ExecutorOption customises an Executor at construction time.
+type ExecutorOption func(*Executor)
+
+// WithStore replaces the default file-backed dataset state.
+func WithStore(s Store) ExecutorOption {
+	return func(e *Executor) { e.store = s }
+}
+
+// WithChangeFeed publishes a ChangeEvent for every record insert, update and
+// delete a flow performs, stamped with the run's clock.
+func WithChangeFeed(f *ChangeFeed) ExecutorOption {
+	return func(e *Executor) { e.changes = f }
+}
+
+// WithEventBus lets the emit op publish to bus.
+func WithEventBus(bus *EventBus) ExecutorOption {
+	return func(e *Executor) { e.events = bus }
+}
+
+// WithLogger sets the logger used for step logs and the log op.
+func WithLogger(l *slog.Logger) ExecutorOption {
+	return func(e *Executor) { e.logger = l }
+}
+
+// WithRedactor sets which headers and body fields are masked in logs.
+func WithRedactor(r *Redactor) ExecutorOption {
+	return func(e *Executor) { e.redactor = r }
+}
+
+// WithClock sets the time source of the now op and of time based ids.
+func WithClock(c Clock) ExecutorOption {
+	return func(e *Executor) { e.clock = c }
+}
+
+// WithIDs sets the generator behind the assignId op, e.g. a seeded one for
+// reproducible tests.
+func WithIDs(g *IDGenerator) ExecutorOption {
+	return func(e *Executor) { e.ids = g }
+}
+
+// WithMetrics makes the executor report step errors and dataset sizes.
+func WithMetrics(m *Metrics) ExecutorOption {
+	return func(e *Executor) { e.metrics = m }
 }
 
 func (e *Executor) Run(ctx context.Context, flowFile string, req *ExecRequest) (*ExecResponse, error) {
-	flow, err := LoadFlow(e.repoPath, flowFile)
+	ctx, span := tracer.Start(ctx, "flow "+flowFile, trace.WithAttributes(
+		attribute.String("artifact.flow", flowFile),
+		attribute.String("artifact.request.method", req.Method),
+		attribute.String("artifact.request.path", req.Path),
+	))
+
+	// Admin state operations take the write lock, so a flow never observes
+	// a half-reset or half-restored dataset.
+	e.stateMu.RLock()
+	res, err := e.run(ctx, flowFile, req)
+	e.stateMu.RUnlock()
+	traceFromContext(ctx).finish(res, err)
+	endSpan(span, res, err)
+	return res, err
+}
+
+func (e *Executor) run(ctx context.Context, flowFile string, req *ExecRequest) (*ExecResponse, error) {
+	tr := traceFromContext(ctx)
+	logger := e.logger.With("request_id", req.RequestID, "flow", flowFile)
+	clock, ids := e.runSources(ctx)
+
+	flow, err := e.flows.load(e.repoPath, flowFile)
 	if err != nil {
 		return nil, &StepError{Status: 500, Msg: "failed to load flow: " + err.Error()}
 	}
@@ -41,42 +155,67 @@ func (e *Executor) Run(ctx context.Context, flowFile string, req *ExecRequest) (
 
 	rt := map[string]any{
 		"request": map[string]any{
+			"id":      req.RequestID,
 			"method":  req.Method,
 			"path":    req.Path,
-			"params":  req.Params,
+			"params":  stringMapToAny(req.Params),
 			"query":   queryToSimple(req.Query),
 			"headers": headers,
 			"body":    deepCopy(req.Body),
+			"files":   filesToSimple(req.Files),
 			"dataset": req.Dataset,
 		},
 		"ctx": map[string]any{},
 	}
 
-	for _, step := range flow.Steps {
+	logger.DebugContext(ctx, "flow started",
+		"method", req.Method,
+		"path", req.Path,
+		"headers", e.redactor.Headers(req.Headers),
+		"body", e.redactor.Value(req.Body),
+	)
+
+	for i, step := range flow.Steps {
 		select {
 		case <-ctx.Done():
 			return nil, ctx.Err()
 		default:
 		}
 
+		st := tr.beginStep(i, step, e.redactor)
+		_, span := tracer.Start(ctx, "step "+step.Op, trace.WithAttributes(
+			attribute.String("artifact.step.id", step.ID),
+			attribute.String("artifact.step.op", step.Op),
+			attribute.Int("artifact.step.index", i),
+		))
 		if step.When != "" {
 			ok, condErr := evalCondition(step.When, rt)
 			if condErr != nil {
+				st.end(nil, condErr)
+				logger.WarnContext(ctx, "step failed", "step", step.ID, "op", step.Op, "error", condErr.Error())
+				e.metrics.stepError(step.Op)
+				endSpan(span, nil, condErr)
 				return handleError(step, &StepError{
 					StepID: step.ID,
 					Status: 500,
 					Msg:    "when eval failed: " + condErr.Error(),
 				})
 			}
+			span.SetAttributes(attribute.Bool("artifact.step.matched", ok))
 			if !ok {
+				st.skip()
+				span.End()
+				logger.DebugContext(ctx, "step skipped", "step", step.ID, "op", step.Op, "when", step.When)
 				continue
 			}
 		}
+		st.resolve(step.Args, rt)
 
 		var out any
+		started := time.Now()
 		switch step.Op {
 		case "loadDataset":
-			out, err = opLoadDataset(e.repoPath, step.Args)
+			out, err = e.opLoadDataset(step.Args)
 		case "filterAndPaginate":
 			out, err = opFilterAndPaginate(step.Args, rt)
 		case "findById":
@@ -86,26 +225,49 @@ func (e *Executor) Run(ctx context.Context, flowFile string, req *ExecRequest) (
 		case "checkUnique":
 			err = opCheckUnique(step.Args, rt)
 		case "assignId":
-			out, err = opAssignId(step.Args, rt)
+			out, err = e.opAssignId(ids, step.Args)
 		case "insertRecord":
-			out, err = opInsertRecord(e.repoPath, step.Args, rt)
+			out, err = e.opInsertRecord(clock, step.Args, rt)
 		case "updateRecord":
-			out, err = opUpdateRecord(e.repoPath, step.Args, rt)
+			out, err = e.opUpdateRecord(clock, step.Args, rt)
 		case "deleteRecord":
-			err = opDeleteRecord(e.repoPath, step.Args, rt)
+			err = e.opDeleteRecord(clock, step.Args, rt)
+		case "bulkInsert":
+			out, err = e.opBulkInsert(clock, ids, step.Args, rt)
+		case "bulkUpdate":
+			out, err = e.opBulkUpdate(clock, step.Args, rt)
+		case "bulkDelete":
+			out, err = e.opBulkDelete(clock, step.Args, rt)
+		case "history":
+			out, err = e.opHistory(step.Args, rt)
+		case "restoreRecord":
+			out, err = e.opRestoreRecord(clock, step.Args, rt)
 		case "now":
-			out, err = opNow()
+			out, err = opNow(clock)
 		case "set":
 			err = opSet(step.Args, rt)
+		case "emit":
+			out, err = e.opEmit(ctx, step.Args, rt, flowFile)
+		case "log":
+			err = opLog(ctx, logger.With("step", step.ID), e.redactor, step.Args, rt)
 		case "respond":
-			return opRespond(step.Args, rt)
+			res, respErr := opRespond(step.Args, rt)
+			st.end(nil, respErr)
+			endSpan(span, res, respErr)
+			return res, respErr
 		default:
 			err = fmt.Errorf("unknown op: %s", step.Op)
 		}
 
+		st.end(out, err)
+		endSpan(span, nil, err)
 		if err != nil {
+			logger.WarnContext(ctx, "step failed", "step", step.ID, "op", step.Op, "error", err.Error())
+			e.metrics.stepError(step.Op)
 			return handleError(step, err)
 		}
+		logger.DebugContext(ctx, "step completed", "step", step.ID, "op", step.Op,
+			"duration_ms", float64(time.Since(started).Microseconds())/1000)
 
 		if step.Out != "" {
 			ctxMap := rt["ctx"].(map[string]any)
@@ -132,34 +294,102 @@ func handleError(step FlowStep, err error) (*ExecResponse, error) {
 	return nil, &StepError{StepID: step.ID, Status: status, Msg: msg}
 }
 
-func opLoadDataset(repoPath string, args map[string]any) (any, error) {
+func endSpan(span trace.Span, res *ExecResponse, err error) {
+	if res != nil {
+		span.SetAttributes(attribute.Int("artifact.response.status", res.Status))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// readState returns the persisted records of dataset, or nil when the
+// dataset has not been written yet.
+func (e *Executor) readState(dataset string) []any {
+	data, _, _ := e.store.Load(dataset)
+	return data
+}
+
+// lockDataset serializes the read-modify-write cycle of record ops on one
+// dataset. Flows run concurrently under stateMu.RLock, and without it two
+// writers would overwrite each other's records and history entries.
+func (e *Executor) lockDataset(dataset string) (unlock func()) {
+	mu, _ := e.recordMu.LoadOrStore(dataset, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+func (e *Executor) writeState(dataset string, data []any) error {
+	if err := e.store.Save(dataset, data); err != nil {
+		return err
+	}
+	e.metrics.datasetSize(dataset, len(data))
+	return nil
+}
+
+func (e *Executor) opLoadDataset(args map[string]any) (any, error) {
 	ds := str(args["dataset"])
 	if ds == "" {
 		return nil, errors.New("loadDataset requires dataset")
 	}
-	statePath := filepath.Join(repoPath, ".runtime", "state", ds+".json")
 
-	if b, err := readJSONFile(statePath); err == nil {
-		var v any
-		if json.Unmarshal(b, &v) == nil {
-			return v, nil
+	data := e.loadDataset(ds, str(args["seed"]))
+	if records, ok := toSlice(data); ok && e.datasets[ds].SoftDelete && args["includeDeleted"] != true {
+		live := make([]any, 0, len(records))
+		for _, r := range records {
+			if m, ok := toMap(r); !ok || !e.softDeleted(ds, m) {
+				live = append(live, r)
+			}
 		}
+		return live, nil
+	}
+	return data, nil
+}
+
+// softDeleted reports whether record has been soft deleted from dataset.
+func (e *Executor) softDeleted(dataset string, record map[string]any) bool {
+	return e.datasets[dataset].SoftDelete && record[DeletedAtField] != nil
+}
+
+// loadDataset returns the saved records of ds, falling back to its seed file
+// (the highest seed.<ds>.vN.json unless seedName is given) and then to an
+// empty list.
+func (e *Executor) loadDataset(ds, seedName string) any {
+	if data, found, err := e.store.Load(ds); err == nil && found {
+		e.metrics.datasetSize(ds, len(data))
+		return data
 	}
 
-	seedName := str(args["seed"])
 	if seedName == "" {
-		seedName = "seed." + ds + ".v1.json"
+		seedName = e.latestSeed(ds)
 	}
-	seedPath := filepath.Join(repoPath, "data", seedName)
+	seedPath := filepath.Join(e.repoPath, "data", seedName)
 
 	if b, err := readJSONFile(seedPath); err == nil {
 		var v any
 		if json.Unmarshal(b, &v) == nil {
-			return v, nil
+			e.metrics.datasetValue(ds, v)
+			return v
 		}
 	}
 
-	return []any{}, nil
+	return []any{}
+}
+
+// latestSeed returns the file name of the newest seed version of ds.
+func (e *Executor) latestSeed(ds string) string {
+	name, best := "seed."+ds+".v1.json", 1
+	matches, _ := filepath.Glob(filepath.Join(e.repoPath, "data", "seed."+ds+".v*.json"))
+	for _, m := range matches {
+		base := filepath.Base(m)
+		v, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(base, "seed."+ds+".v"), ".json"))
+		if err == nil && v > best {
+			name, best = base, v
+		}
+	}
+	return name
 }
 
 func opFilterAndPaginate(args map[string]any, rt map[string]any) (any, error) {
@@ -260,17 +490,23 @@ func opValidateBody(args map[string]any, rt map[string]any) error {
 		return nil
 	}
 
-	schemaBytes, _ := json.Marshal(schemaRaw)
 	bodyVal := getByPath(rt, []string{"request", "body"})
 	if bodyVal == nil {
 		bodyVal = map[string]any{}
 	}
-	bodyBytes, _ := json.Marshal(bodyVal)
+	return validateSchema(schemaRaw, bodyVal)
+}
+
+// validateSchema checks value against a JSON Schema and reports every
+// violation in one 400 StepError.
+func validateSchema(schema, value any) error {
+	schemaBytes, _ := json.Marshal(schema)
+	valueBytes, _ := json.Marshal(value)
 
 	schemaLoader := gojsonschema.NewBytesLoader(schemaBytes)
-	bodyLoader := gojsonschema.NewBytesLoader(bodyBytes)
+	valueLoader := gojsonschema.NewBytesLoader(valueBytes)
 
-	result, err := gojsonschema.Validate(schemaLoader, bodyLoader)
+	result, err := gojsonschema.Validate(schemaLoader, valueLoader)
 	if err != nil {
 		return fmt.Errorf("schema validation setup failed: %w", err)
 	}
@@ -278,7 +514,7 @@ func opValidateBody(args map[string]any, rt map[string]any) error {
 	if !result.Valid() {
 		details := make([]string, 0, len(result.Errors()))
 		for _, desc := range result.Errors() {
-			details = append(details, fmt.Sprintf("%s (%s)", desc.Message(), desc.Field()))
+			details = append(details, fmt.Sprintf("%s (%s)", desc.Description(), desc.Field()))
 		}
 		return &StepError{
 			Status: 400,
@@ -321,18 +557,44 @@ func opCheckUnique(args map[string]any, rt map[string]any) error {
 	return nil
 }
 
-func opAssignId(args map[string]any, rt map[string]any) (any, error) {
-	prefix := str(args["prefix"])
-	if prefix == "" {
+// opAssignId mints an id with the strategy arg (default timestamp). The
+// prefix defaults to "id_" for the timestamp strategy only; the sequence
+// strategy counts per dataset, looking at the field arg (default "id").
+func (e *Executor) opAssignId(ids *IDGenerator, args map[string]any) (any, error) {
+	return assignID(ids, args, func(dataset string) []any {
+		// Seeded datasets count too, or the first id would repeat a seed's.
+		records, _ := toSlice(e.loadDataset(dataset, ""))
+		return records
+	})
+}
+
+// assignID generates an id; sequences continue from the highest one among
+// the records that existing returns for the dataset.
+func assignID(ids *IDGenerator, args map[string]any, existing func(dataset string) []any) (any, error) {
+	strategy := str(args["strategy"])
+	prefix, hasPrefix := args["prefix"]
+	if !hasPrefix && (strategy == "" || strategy == IDTimestamp) {
 		prefix = "id_"
 	}
-
-	timestamp := time.Now().UnixNano()
-	id := fmt.Sprintf("%s%d", prefix, timestamp)
-	return id, nil
+	if strategy == IDSequence {
+		dataset := str(args["dataset"])
+		if dataset == "" {
+			return nil, errors.New("assignId with strategy sequence requires dataset")
+		}
+		field := str(args["field"])
+		if field == "" {
+			field = "id"
+		}
+		return ids.Sequence(dataset, str(prefix), field, existing(dataset)), nil
+	}
+	id, err := ids.New(strategy)
+	if err != nil {
+		return nil, err
+	}
+	return str(prefix) + id, nil
 }
 
-func opInsertRecord(repoPath string, args map[string]any, rt map[string]any) (any, error) {
+func (e *Executor) opInsertRecord(clock Clock, args map[string]any, rt map[string]any) (any, error) {
 	dataset := str(args["dataset"])
 	record := getExpr(rt, args["record"], nil)
 
@@ -348,23 +610,20 @@ func opInsertRecord(repoPath string, args map[string]any, rt map[string]any) (an
 		return nil, errors.New("record must be an object")
 	}
 
-	stateFile := filepath.Join(repoPath, ".runtime", "state", dataset+".json")
-
-	var data []any
-	if b, err := readJSONFile(stateFile); err == nil {
-		_ = json.Unmarshal(b, &data)
-	}
+	defer e.lockDataset(dataset)()
+	data := e.readState(dataset)
 
 	data = append(data, recordMap)
 
-	if err := writeJSONPretty(stateFile, data); err != nil {
+	if err := e.saveChange(clock, rt, dataset, data, HistoryInsert, toString(recordMap["id"]), nil, recordMap); err != nil {
 		return nil, fmt.Errorf("failed to save record: %w", err)
 	}
+	e.changes.Publish(ChangeEvent{Type: ChangeInsert, Dataset: dataset, RecordID: toString(recordMap["id"]), Record: deepCopy(recordMap), Time: clock.Now().UTC()})
 
 	return recordMap, nil
 }
 
-func opUpdateRecord(repoPath string, args map[string]any, rt map[string]any) (any, error) {
+func (e *Executor) opUpdateRecord(clock Clock, args map[string]any, rt map[string]any) (any, error) {
 	dataset := str(args["dataset"])
 	id := toString(getExpr(rt, args["id"], ""))
 	patch := getExpr(rt, args["patch"], nil)
@@ -376,32 +635,30 @@ func opUpdateRecord(repoPath string, args map[string]any, rt map[string]any) (an
 		return nil, errors.New("updateRecord requires record id")
 	}
 
-	patchMap, ok := toMap(patch)
-	if !ok {
-		return nil, errors.New("patch must be an object")
+	mode, err := patchMode(toString(getExpr(rt, args["mode"], PatchShallow)))
+	if err != nil {
+		return nil, err
 	}
 
-	stateFile := filepath.Join(repoPath, ".runtime", "state", dataset+".json")
-
-	var data []any
-	if b, err := readJSONFile(stateFile); err == nil {
-		_ = json.Unmarshal(b, &data)
-	}
+	defer e.lockDataset(dataset)()
+	data := e.readState(dataset)
 
 	found := false
-	var updated map[string]any
+	var before, updated map[string]any
 	for i, it := range data {
 		m, ok := toMap(it)
 		if !ok {
 			continue
 		}
-		if toString(m["id"]) == id {
-			// Merge patch into existing record
-			for k, v := range patchMap {
-				m[k] = v
+		if toString(m["id"]) == id && !e.softDeleted(dataset, m) {
+			before = deepCopyMap(m)
+			if updated, err = applyPatch(mode, m, patch); err != nil {
+				return nil, err
 			}
-			data[i] = m
-			updated = m
+			if mode != PatchShallow && toString(updated["id"]) != id {
+				return nil, &StepError{Status: 422, Msg: "patch must not change the record id"}
+			}
+			data[i] = updated
 			found = true
 			break
 		}
@@ -411,14 +668,17 @@ func opUpdateRecord(repoPath string, args map[string]any, rt map[string]any) (an
 		return nil, &StepError{Status: 404, Msg: "record not found"}
 	}
 
-	if err := writeJSONPretty(stateFile, data); err != nil {
+	if err := e.saveChange(clock, rt, dataset, data, HistoryUpdate, id, before, updated); err != nil {
 		return nil, fmt.Errorf("failed to update record: %w", err)
 	}
+	e.changes.Publish(ChangeEvent{Type: ChangeUpdate, Dataset: dataset, RecordID: id, Record: deepCopy(updated), Time: clock.Now().UTC()})
 
 	return updated, nil
 }
 
-func opDeleteRecord(repoPath string, args map[string]any, rt map[string]any) error {
+// opDeleteRecord drops a record, or marks it with DeletedAtField in soft
+// delete datasets.
+func (e *Executor) opDeleteRecord(clock Clock, args map[string]any, rt map[string]any) error {
 	dataset := str(args["dataset"])
 	id := toString(getExpr(rt, args["id"], ""))
 
@@ -429,14 +689,11 @@ func opDeleteRecord(repoPath string, args map[string]any, rt map[string]any) err
 		return errors.New("deleteRecord requires record id")
 	}
 
-	stateFile := filepath.Join(repoPath, ".runtime", "state", dataset+".json")
-
-	var data []any
-	if b, err := readJSONFile(stateFile); err == nil {
-		_ = json.Unmarshal(b, &data)
-	}
+	defer e.lockDataset(dataset)()
+	data := e.readState(dataset)
 
 	found := false
+	var deleted, after map[string]any
 	newData := make([]any, 0, len(data))
 	for _, it := range data {
 		m, ok := toMap(it)
@@ -444,8 +701,15 @@ func opDeleteRecord(repoPath string, args map[string]any, rt map[string]any) err
 			newData = append(newData, it)
 			continue
 		}
-		if toString(m["id"]) == id {
+		if toString(m["id"]) == id && !e.softDeleted(dataset, m) {
 			found = true
+			deleted = m
+			if e.datasets[dataset].SoftDelete {
+				marked := deepCopyMap(m)
+				marked[DeletedAtField] = clock.Now().UTC().Format(time.RFC3339)
+				newData = append(newData, marked)
+				after = marked
+			}
 			continue
 		}
 		newData = append(newData, m)
@@ -455,15 +719,16 @@ func opDeleteRecord(repoPath string, args map[string]any, rt map[string]any) err
 		return &StepError{Status: 404, Msg: "record not found"}
 	}
 
-	if err := writeJSONPretty(stateFile, newData); err != nil {
+	if err := e.saveChange(clock, rt, dataset, newData, HistoryDelete, id, deleted, after); err != nil {
 		return fmt.Errorf("failed to delete record: %w", err)
 	}
+	e.changes.Publish(ChangeEvent{Type: ChangeDelete, Dataset: dataset, RecordID: id, Record: deepCopy(deleted), Time: clock.Now().UTC()})
 
 	return nil
 }
 
-func opNow() (any, error) {
-	return time.Now().Format(time.RFC3339), nil
+func opNow(clock Clock) (any, error) {
+	return clock.Now().Format(time.RFC3339), nil
 }
 
 func opSet(args map[string]any, rt map[string]any) error {
@@ -490,9 +755,36 @@ func opRespond(args map[string]any, rt map[string]any) (*ExecResponse, error) {
 		body = deepCopy(getByPath(rt, toPath(bodyExpr)))
 	} else if raw, ok := args["body"].(map[string]any); ok {
 		body = deepCopy(raw)
+	} else if raw, ok := args["body"]; ok && raw != nil {
+		body = deepCopy(raw)
 	} else {
 		body = map[string]any{}
 	}
 
-	return &ExecResponse{Status: status, Headers: headers, Body: body}, nil
+	res := &ExecResponse{Status: status, Headers: headers, Body: body}
+	if args["format"] == nil {
+		return res, nil
+	}
+	candidates := toStringSlice(args["format"])
+	for _, f := range candidates {
+		if _, ok := formatContentTypes[f]; !ok {
+			return nil, fmt.Errorf("respond: unknown format %q", f)
+		}
+	}
+	accept := strings.Join(normalizeStrings(getByPath(rt, []string{"request", "headers", "Accept"})), ",")
+	format, ok := negotiateFormat(accept, candidates)
+	if !ok {
+		return nil, &StepError{Status: 406, Msg: "not acceptable: " + accept}
+	}
+	if _, set := headers["Vary"]; !set && len(candidates) > 1 {
+		headers["Vary"] = "Accept"
+	}
+	res.Format = format
+	res.ContentType = str(args["contentType"])
+	res.Columns = toStringSlice(args["columns"])
+	if len(res.Columns) == 0 {
+		res.Columns = nil
+	}
+	res.XMLRoot = str(args["root"])
+	return res, nil
 }
@@ -0,0 +1,318 @@
+package artifact
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EndpointEvent marks a registry entry whose flow runs for every event
+// published on its topic rather than for an HTTP route.
+const EndpointEvent = "event"
+
+// Event is a domain event published by the emit op.
+type Event struct {
+	ID        string    `json:"id"`
+	Topic     string    `json:"topic"`
+	Payload   any       `json:"payload"`
+	Source    string    `json:"source,omitempty"`
+	RequestID string    `json:"requestId,omitempty"`
+	EmittedAt time.Time `json:"emittedAt"`
+	// Delivered lists the subscriptions that already handled the event, so
+	// a retry or restart only redelivers to the ones that failed.
+	Delivered []string `json:"delivered,omitempty"`
+	Attempts  int      `json:"attempts"`
+	LastError string   `json:"lastError,omitempty"`
+}
+
+// EventBusOptions tunes delivery retries.
+type EventBusOptions struct {
+	// MaxAttempts before an event is dead-lettered. Defaults to 5.
+	MaxAttempts int
+	// Backoff before the first retry, doubled for each later one.
+	// Defaults to one second.
+	Backoff time.Duration
+	Logger  *slog.Logger
+}
+
+type subscription struct {
+	id    string
+	topic string
+	flow  string
+}
+
+// EventBus delivers emitted events to subscribed flows in the background.
+// Events are written to an on-disk outbox before emit returns and removed
+// once every subscriber handled them, giving at-least-once delivery across
+// restarts. Events that keep failing are appended to dead-letter.jsonl.
+type EventBus struct {
+	dir  string
+	opts EventBusOptions
+
+	mu     sync.Mutex
+	subs   []subscription
+	queue  []string
+	notify chan struct{}
+	exec   *Executor
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewEventBus keeps its outbox and dead-letter file in dir.
+func NewEventBus(dir string, opts EventBusOptions) *EventBus {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = time.Second
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	return &EventBus{dir: dir, opts: opts, notify: make(chan struct{}, 1)}
+}
+
+// Subscribe runs flow for events on topic. A topic ending in ".*" matches
+// every topic with that prefix.
+func (b *EventBus) Subscribe(id, topic, flow string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs = append(b.subs, subscription{id: id, topic: topic, flow: flow})
+}
+
+// Start redelivers events left in the outbox and then delivers new ones
+// through e until Stop is called.
+func (b *EventBus) Start(e *Executor) error {
+	pending, err := filepath.Glob(filepath.Join(b.dir, "*.event.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(pending)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b.mu.Lock()
+	b.exec = e
+	b.cancel = cancel
+	b.done = make(chan struct{})
+	// The outbox already holds anything emitted before Start.
+	b.queue = pending
+	b.mu.Unlock()
+	if len(pending) > 0 {
+		b.opts.Logger.Info("redelivering outbox events", "count", len(pending))
+	}
+	b.wake()
+	go b.loop(ctx)
+	return nil
+}
+
+// Stop waits for the in-flight delivery to finish. Undelivered events stay
+// in the outbox for the next Start.
+func (b *EventBus) Stop() {
+	b.mu.Lock()
+	cancel, done := b.cancel, b.done
+	b.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// Emit stores ev in the outbox and queues it for delivery.
+func (b *EventBus) Emit(ev *Event) error {
+	if ev.ID == "" {
+		ev.ID = newRequestID()
+	}
+	if ev.EmittedAt.IsZero() {
+		ev.EmittedAt = time.Now().UTC()
+	}
+	path := filepath.Join(b.dir, fmt.Sprintf("%020d-%s.event.json", ev.EmittedAt.UnixNano(), ev.ID))
+	if err := writeJSONPretty(path, ev); err != nil {
+		return fmt.Errorf("write outbox: %w", err)
+	}
+	b.enqueue(path)
+	return nil
+}
+
+func (b *EventBus) enqueue(path string) {
+	b.mu.Lock()
+	b.queue = append(b.queue, path)
+	b.mu.Unlock()
+	b.wake()
+}
+
+func (b *EventBus) wake() {
+	select {
+	case b.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (b *EventBus) loop(ctx context.Context) {
+	defer close(b.done)
+	for {
+		b.mu.Lock()
+		var path string
+		if len(b.queue) > 0 {
+			path, b.queue = b.queue[0], b.queue[1:]
+		}
+		b.mu.Unlock()
+		if path == "" {
+			select {
+			case <-ctx.Done():
+				return
+			case <-b.notify:
+			}
+			continue
+		}
+		b.deliver(ctx, path)
+	}
+}
+
+// deliver runs every matching subscription that has not yet handled the
+// event, then removes it from the outbox, schedules a retry or dead-letters it.
+func (b *EventBus) deliver(ctx context.Context, path string) {
+	logger := b.opts.Logger
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		// Queued twice around Start and already delivered.
+		return
+	}
+	if err != nil {
+		logger.Error("read outbox event", "path", path, "error", err.Error())
+		return
+	}
+	ev := &Event{}
+	if err := json.Unmarshal(raw, ev); err != nil {
+		logger.Error("parse outbox event", "path", path, "error", err.Error())
+		b.deadLetter(path, ev, "unreadable outbox entry: "+err.Error())
+		return
+	}
+
+	b.mu.Lock()
+	subs := append([]subscription(nil), b.subs...)
+	exec := b.exec
+	b.mu.Unlock()
+
+	var failures []string
+	for _, s := range subs {
+		if !topicMatches(s.topic, ev.Topic) || containsString(ev.Delivered, s.id) {
+			continue
+		}
+		if err := runEventFlow(ctx, exec, s, ev); err != nil {
+			failures = append(failures, s.id+": "+err.Error())
+			continue
+		}
+		ev.Delivered = append(ev.Delivered, s.id)
+	}
+	if ctx.Err() != nil {
+		// Shutting down: keep the event as is for the next start.
+		return
+	}
+	if len(failures) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			logger.Error("remove delivered event", "path", path, "error", err.Error())
+		}
+		return
+	}
+
+	ev.Attempts++
+	ev.LastError = strings.Join(failures, "; ")
+	if ev.Attempts >= b.opts.MaxAttempts {
+		logger.Error("event dead-lettered", "event", ev.ID, "topic", ev.Topic, "attempts", ev.Attempts, "error", ev.LastError)
+		b.deadLetter(path, ev, ev.LastError)
+		return
+	}
+	if err := writeJSONPretty(path, ev); err != nil {
+		logger.Error("update outbox event", "path", path, "error", err.Error())
+	}
+	delay := b.opts.Backoff << (ev.Attempts - 1)
+	logger.Warn("event delivery failed; retrying", "event", ev.ID, "topic", ev.Topic, "attempt", ev.Attempts, "retry_in", delay.String(), "error", ev.LastError)
+	time.AfterFunc(delay, func() { b.enqueue(path) })
+}
+
+func runEventFlow(ctx context.Context, e *Executor, s subscription, ev *Event) error {
+	res, err := e.Run(ctx, s.flow, &ExecRequest{
+		RequestID: ev.RequestID,
+		Method:    "EVENT",
+		Path:      ev.Topic,
+		Headers:   map[string][]string{"X-Event-Id": {ev.ID}, "X-Event-Topic": {ev.Topic}},
+		Body:      deepCopy(ev.Payload),
+		Dataset:   map[string]any{},
+	})
+	if err != nil {
+		return err
+	}
+	if res.Status >= 500 {
+		return fmt.Errorf("flow responded %d", res.Status)
+	}
+	return nil
+}
+
+// DeadLetterFile is where events are appended after their last attempt.
+func (b *EventBus) DeadLetterFile() string {
+	return filepath.Join(b.dir, "dead-letter.jsonl")
+}
+
+func (b *EventBus) deadLetter(path string, ev *Event, reason string) {
+	ev.LastError = reason
+	line, _ := json.Marshal(ev)
+	f, err := os.OpenFile(b.DeadLetterFile(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err == nil {
+		_, err = f.Write(append(line, '\n'))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		b.opts.Logger.Error("write dead letter", "event", ev.ID, "error", err.Error())
+		return
+	}
+	_ = os.Remove(path)
+}
+
+func topicMatches(pattern, topic string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, ".*"); ok {
+		return strings.HasPrefix(topic, prefix+".")
+	}
+	return pattern == topic
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// opEmit publishes args.payload on args.topic. Without an event bus the
+// event is logged and dropped, which keeps flow tests side-effect free.
+func (e *Executor) opEmit(ctx context.Context, args map[string]any, rt map[string]any, flowFile string) (any, error) {
+	topic := toString(getExpr(rt, args["topic"], ""))
+	if topic == "" {
+		return nil, fmt.Errorf("emit requires topic")
+	}
+	ev := &Event{
+		Topic:     topic,
+		Payload:   deepCopy(getExpr(rt, args["payload"], map[string]any{})),
+		Source:    flowFile,
+		RequestID: toString(getByPath(rt, []string{"request", "id"})),
+	}
+	if e.events == nil {
+		e.logger.DebugContext(ctx, "no event bus configured; event dropped", "topic", topic)
+		return map[string]any{"topic": topic}, nil
+	}
+	if err := e.events.Emit(ev); err != nil {
+		return nil, err
+	}
+	return map[string]any{"id": ev.ID, "topic": topic}, nil
+}
@@ -0,0 +1,113 @@
+package artifact
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const signupFlow = `
+version: 1
+steps:
+  - op: insertRecord
+    args: { dataset: users, record: "$request.body" }
+    out: user
+  - op: emit
+    args: { topic: user.created, payload: "$ctx.user" }
+  - op: respond
+    args: { status: 201, bodyFrom: "$ctx.user" }
+`
+
+const welcomeFlow = `
+version: 1
+steps:
+  - op: insertRecord
+    args: { dataset: welcomes, record: "$request.body" }
+  - op: respond
+    args: { status: 204 }
+`
+
+func outboxEntries(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := filepath.Glob(filepath.Join(dir, "*.event.json"))
+	require.NoError(t, err)
+	return entries
+}
+
+func TestEmitTriggersSubscribedFlow(t *testing.T) {
+	repo := t.TempDir()
+	writeTestFlow(t, repo, "signup.flow.yaml", signupFlow)
+	writeTestFlow(t, repo, "welcome.flow.yaml", welcomeFlow)
+	outbox := filepath.Join(repo, ".runtime", "outbox")
+
+	bus := NewEventBus(outbox, EventBusOptions{})
+	exec := NewExecutor(repo, WithStore(NewMemoryStore(nil)), WithEventBus(bus))
+	bus.Subscribe("users.welcome", "user.*", "welcome.flow.yaml")
+
+	// Emitted before Start: only the outbox holds it until the bus runs.
+	res, err := exec.Run(context.Background(), "signup.flow.yaml", &ExecRequest{Body: map[string]any{"id": "u1"}})
+	require.NoError(t, err)
+	require.Equal(t, 201, res.Status)
+	require.Len(t, outboxEntries(t, outbox), 1)
+
+	require.NoError(t, bus.Start(exec))
+	defer bus.Stop()
+	_, err = exec.Run(context.Background(), "signup.flow.yaml", &ExecRequest{Body: map[string]any{"id": "u2"}})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		welcomes, _ := exec.ExportDataset("welcomes")
+		return len(welcomes) == 2
+	}, 2*time.Second, 5*time.Millisecond)
+	welcomes, _ := exec.ExportDataset("welcomes")
+	require.Equal(t, []any{map[string]any{"id": "u1"}, map[string]any{"id": "u2"}}, welcomes)
+	require.Eventually(t, func() bool { return len(outboxEntries(t, outbox)) == 0 }, time.Second, 5*time.Millisecond)
+}
+
+func TestFailingEventsAreRetriedThenDeadLettered(t *testing.T) {
+	repo := t.TempDir()
+	writeTestFlow(t, repo, "welcome.flow.yaml", welcomeFlow)
+	outbox := filepath.Join(repo, ".runtime", "outbox")
+
+	bus := NewEventBus(outbox, EventBusOptions{MaxAttempts: 3, Backoff: time.Millisecond})
+	exec := NewExecutor(repo, WithStore(NewMemoryStore(nil)), WithEventBus(bus))
+	bus.Subscribe("ok", "user.created", "welcome.flow.yaml")
+	bus.Subscribe("broken", "user.created", "missing.flow.yaml")
+	require.NoError(t, bus.Start(exec))
+	defer bus.Stop()
+
+	require.NoError(t, bus.Emit(&Event{Topic: "user.created", Payload: map[string]any{"id": "u1"}}))
+
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(bus.DeadLetterFile())
+		return err == nil
+	}, 2*time.Second, 5*time.Millisecond)
+	require.Empty(t, outboxEntries(t, outbox))
+
+	f, err := os.Open(bus.DeadLetterFile())
+	require.NoError(t, err)
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	require.True(t, scanner.Scan())
+	var ev Event
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), &ev))
+	require.Equal(t, 3, ev.Attempts)
+	require.Equal(t, []string{"ok"}, ev.Delivered)
+	require.Contains(t, ev.LastError, "broken: failed to load flow")
+
+	welcomes, _ := exec.ExportDataset("welcomes")
+	require.Len(t, welcomes, 1, "the healthy subscriber runs once despite retries")
+}
+
+func TestTopicMatches(t *testing.T) {
+	require.True(t, topicMatches("user.created", "user.created"))
+	require.True(t, topicMatches("user.*", "user.deleted"))
+	require.False(t, topicMatches("user.*", "users.deleted"))
+	require.False(t, topicMatches("user.created", "user.deleted"))
+}
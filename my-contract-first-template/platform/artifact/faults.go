@@ -0,0 +1,383 @@
+package artifact
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FaultRule degrades an endpoint to exercise client retry and timeout
+// handling. Percentages are 0-100.
+type FaultRule struct {
+	Latency *LatencySpec `json:"latency,omitempty"`
+	// ErrorPercent of requests are answered with ErrorStatus (default 503).
+	ErrorPercent float64 `json:"errorPercent,omitempty"`
+	ErrorStatus  int     `json:"errorStatus,omitempty"`
+	// ResetPercent of connections are closed abruptly without a response.
+	ResetPercent float64        `json:"resetPercent,omitempty"`
+	SlowDrip     *SlowDripSpec  `json:"slowDrip,omitempty"`
+	Outages      []OutageWindow `json:"outages,omitempty"`
+}
+
+// LatencySpec adds a delay drawn from Distribution: "fixed" (Ms),
+// "uniform" (MinMs-MaxMs), "normal" (MeanMs, StddevMs) or "exponential"
+// (MeanMs). MaxMs also caps normal and exponential samples when set.
+type LatencySpec struct {
+	Distribution string  `json:"distribution"`
+	Ms           float64 `json:"ms,omitempty"`
+	MinMs        float64 `json:"minMs,omitempty"`
+	MaxMs        float64 `json:"maxMs,omitempty"`
+	MeanMs       float64 `json:"meanMs,omitempty"`
+	StddevMs     float64 `json:"stddevMs,omitempty"`
+}
+
+// SlowDripSpec writes the body ChunkBytes at a time, IntervalMs apart.
+type SlowDripSpec struct {
+	ChunkBytes int `json:"chunkBytes"`
+	IntervalMs int `json:"intervalMs"`
+}
+
+// OutageWindow makes the endpoint unavailable, either between Start and End
+// (RFC 3339) or for Duration after every activation of Cron.
+type OutageWindow struct {
+	Start    *time.Time `json:"start,omitempty"`
+	End      *time.Time `json:"end,omitempty"`
+	Cron     string     `json:"cron,omitempty"`
+	Duration string     `json:"duration,omitempty"`
+	Status   int        `json:"status,omitempty"`
+}
+
+// Validate checks that the rule can be applied.
+func (r *FaultRule) Validate() error {
+	for name, p := range map[string]float64{"errorPercent": r.ErrorPercent, "resetPercent": r.ResetPercent} {
+		if p < 0 || p > 100 {
+			return fmt.Errorf("%s must be between 0 and 100", name)
+		}
+	}
+	if !validFaultStatus(r.ErrorStatus) {
+		return fmt.Errorf("errorStatus %d is not an HTTP status", r.ErrorStatus)
+	}
+	if l := r.Latency; l != nil {
+		switch l.Distribution {
+		case "fixed", "uniform", "normal", "exponential":
+		default:
+			return fmt.Errorf("unknown latency distribution %q", l.Distribution)
+		}
+		if l.Distribution == "uniform" && l.MaxMs < l.MinMs {
+			return errors.New("uniform latency needs minMs <= maxMs")
+		}
+	}
+	if d := r.SlowDrip; d != nil && (d.ChunkBytes <= 0 || d.IntervalMs < 0) {
+		return errors.New("slowDrip needs chunkBytes > 0 and intervalMs >= 0")
+	}
+	for i, o := range r.Outages {
+		if !validFaultStatus(o.Status) {
+			return fmt.Errorf("outage %d: status %d is not an HTTP status", i, o.Status)
+		}
+		switch {
+		case o.Cron != "":
+			if _, err := ParseCron(o.Cron); err != nil {
+				return fmt.Errorf("outage %d: %w", i, err)
+			}
+			if d, err := time.ParseDuration(o.Duration); err != nil || d <= 0 {
+				return fmt.Errorf("outage %d: cron outages need a positive duration", i)
+			}
+		case o.Start == nil || o.End == nil || !o.End.After(*o.Start):
+			return fmt.Errorf("outage %d: needs start before end, or cron and duration", i)
+		}
+	}
+	return nil
+}
+
+// validFaultStatus accepts 0, which means the 503 default, and codes that
+// WriteHeader can send.
+func validFaultStatus(status int) bool {
+	return status == 0 || (status >= 100 && status <= 599)
+}
+
+// active reports whether the window covers now.
+func (o OutageWindow) active(now time.Time) bool {
+	if o.Cron == "" {
+		return !now.Before(*o.Start) && now.Before(*o.End)
+	}
+	spec, err := ParseCron(o.Cron)
+	if err != nil {
+		return false
+	}
+	d, _ := time.ParseDuration(o.Duration)
+	// The window is open if an activation happened within the last d.
+	last := spec.Next(now.Add(-d))
+	return !last.IsZero() && !last.After(now)
+}
+
+// FaultInjector applies fault rules per endpoint. Rules come from the
+// registry and can be replaced at runtime; all randomness is drawn from one
+// seeded source so a run with the same seed and request order reproduces.
+type FaultInjector struct {
+	clock Clock
+
+	mu        sync.Mutex
+	rand      *rand.Rand
+	seed      int64
+	defaults  map[string]*FaultRule
+	overrides map[string]*FaultRule
+}
+
+// NewFaultInjector creates an injector with the registry's rules.
+func NewFaultInjector(seed int64, clock Clock, endpoints []EndpointDef) (*FaultInjector, error) {
+	if clock == nil {
+		clock = SystemClock()
+	}
+	f := &FaultInjector{
+		clock:     clock,
+		rand:      rand.New(rand.NewSource(seed)),
+		seed:      seed,
+		defaults:  map[string]*FaultRule{},
+		overrides: map[string]*FaultRule{},
+	}
+	for _, ep := range endpoints {
+		if ep.Faults == nil {
+			continue
+		}
+		if err := ep.Faults.Validate(); err != nil {
+			return nil, fmt.Errorf("endpoint %s faults: %w", ep.ID, err)
+		}
+		f.defaults[ep.ID] = ep.Faults
+	}
+	return f, nil
+}
+
+// Rule returns the rule in effect for endpointID, or nil.
+func (f *FaultInjector) Rule(endpointID string) *FaultRule {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r, ok := f.overrides[endpointID]; ok {
+		return r
+	}
+	return f.defaults[endpointID]
+}
+
+// SetRule replaces the rule of endpointID until ClearRule is called. An
+// empty rule disables faults for the endpoint.
+func (f *FaultInjector) SetRule(endpointID string, r *FaultRule) error {
+	if err := r.Validate(); err != nil {
+		return &StepError{Status: http.StatusBadRequest, Msg: err.Error()}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.overrides[endpointID] = r
+	return nil
+}
+
+// ClearRule drops the runtime rule of endpointID, restoring the registry's.
+func (f *FaultInjector) ClearRule(endpointID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.overrides, endpointID)
+}
+
+// Reseed restarts the random sequence.
+func (f *FaultInjector) Reseed(seed int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.seed = seed
+	f.rand = rand.New(rand.NewSource(seed))
+}
+
+// faultPlan is what happens to one request, decided up front so a single
+// lock acquisition consumes the random numbers in a fixed order.
+type faultPlan struct {
+	outageStatus int
+	delay        time.Duration
+	reset        bool
+	errorStatus  int
+	drip         *SlowDripSpec
+}
+
+func (f *FaultInjector) plan(endpointID string) faultPlan {
+	rule := f.Rule(endpointID)
+	if rule == nil {
+		return faultPlan{}
+	}
+	now := f.clock.Now()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var p faultPlan
+	for _, o := range rule.Outages {
+		if o.active(now) {
+			p.outageStatus = o.Status
+			if p.outageStatus == 0 {
+				p.outageStatus = http.StatusServiceUnavailable
+			}
+			return p
+		}
+	}
+	if rule.Latency != nil {
+		p.delay = sampleLatency(f.rand, rule.Latency)
+	}
+	p.reset = rule.ResetPercent > 0 && f.rand.Float64()*100 < rule.ResetPercent
+	if rule.ErrorPercent > 0 && f.rand.Float64()*100 < rule.ErrorPercent {
+		p.errorStatus = rule.ErrorStatus
+		if p.errorStatus == 0 {
+			p.errorStatus = http.StatusServiceUnavailable
+		}
+	}
+	p.drip = rule.SlowDrip
+	return p
+}
+
+func sampleLatency(r *rand.Rand, l *LatencySpec) time.Duration {
+	var ms float64
+	switch l.Distribution {
+	case "fixed":
+		ms = l.Ms
+	case "uniform":
+		ms = l.MinMs + r.Float64()*(l.MaxMs-l.MinMs)
+	case "normal":
+		ms = l.MeanMs + r.NormFloat64()*l.StddevMs
+	case "exponential":
+		ms = r.ExpFloat64() * l.MeanMs
+	}
+	if l.MaxMs > 0 && l.Distribution != "uniform" {
+		ms = math.Min(ms, l.MaxMs)
+	}
+	if ms < 0 {
+		ms = 0
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// Middleware injects the faults of endpointID in this order: outage,
+// latency, connection reset, error response, slow-drip body.
+func (f *FaultInjector) Middleware(endpointID string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := f.plan(endpointID)
+		if p.outageStatus != 0 {
+			c.AbortWithStatusJSON(p.outageStatus, map[string]string{"error": "service unavailable (injected outage)"})
+			return
+		}
+		if p.delay > 0 {
+			select {
+			case <-c.Request.Context().Done():
+				c.Abort()
+				return
+			case <-f.clock.After(p.delay):
+			}
+		}
+		if p.reset {
+			resetConnection(c)
+			return
+		}
+		if p.errorStatus != 0 {
+			c.AbortWithStatusJSON(p.errorStatus, map[string]string{"error": "injected failure"})
+			return
+		}
+		if p.drip != nil {
+			c.Writer = &dripWriter{ResponseWriter: c.Writer, spec: *p.drip, clock: f.clock}
+		}
+		c.Next()
+	}
+}
+
+// resetConnection closes the client connection with SO_LINGER 0 so the
+// client sees a TCP reset rather than a clean close. HTTP/2 connections
+// cannot be hijacked, so servers injecting faults must serve HTTP/1.1.
+func resetConnection(c *gin.Context) {
+	c.Abort()
+	conn, _, err := c.Writer.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tc, ok := conn.(*tls.Conn); ok {
+		conn = tc.NetConn()
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		_ = tcp.SetLinger(0)
+	}
+	_ = conn.Close()
+}
+
+type dripWriter struct {
+	gin.ResponseWriter
+	spec  SlowDripSpec
+	clock Clock
+}
+
+func (w *dripWriter) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		n := min(w.spec.ChunkBytes, len(b))
+		m, err := w.ResponseWriter.Write(b[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		w.ResponseWriter.Flush()
+		b = b[n:]
+		if len(b) > 0 && w.spec.IntervalMs > 0 {
+			<-w.clock.After(time.Duration(w.spec.IntervalMs) * time.Millisecond)
+		}
+	}
+	return written, nil
+}
+
+func (w *dripWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// RegisterFaultAdmin mounts the runtime fault rule API on an admin group.
+func RegisterFaultAdmin(g *gin.RouterGroup, f *FaultInjector, endpoints []EndpointDef) {
+	g.GET("/faults", func(c *gin.Context) {
+		rules := map[string]*FaultRule{}
+		for _, ep := range endpoints {
+			if r := f.Rule(ep.ID); r != nil {
+				rules[ep.ID] = r
+			}
+		}
+		f.mu.Lock()
+		seed := f.seed
+		f.mu.Unlock()
+		c.JSON(http.StatusOK, map[string]any{"seed": seed, "rules": rules})
+	})
+	known := map[string]bool{}
+	for _, ep := range endpoints {
+		known[ep.ID] = true
+	}
+	g.PUT("/faults/:endpoint", func(c *gin.Context) {
+		if !known[c.Param("endpoint")] {
+			c.JSON(http.StatusNotFound, map[string]string{"error": "unknown endpoint: " + c.Param("endpoint")})
+			return
+		}
+		var rule FaultRule
+		if err := c.ShouldBindJSON(&rule); err != nil {
+			c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		err := f.SetRule(c.Param("endpoint"), &rule)
+		respondState(c, map[string]any{"endpoint": c.Param("endpoint"), "rule": rule}, err)
+	})
+	g.DELETE("/faults/:endpoint", func(c *gin.Context) {
+		f.ClearRule(c.Param("endpoint"))
+		c.JSON(http.StatusOK, map[string]any{"endpoint": c.Param("endpoint"), "rule": f.Rule(c.Param("endpoint"))})
+	})
+	g.POST("/faults/seed", func(c *gin.Context) {
+		var body struct {
+			Seed int64 `json:"seed"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		f.Reseed(body.Seed)
+		c.JSON(http.StatusOK, map[string]any{"seed": body.Seed})
+	})
+}
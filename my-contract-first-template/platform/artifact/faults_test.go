@@ -0,0 +1,189 @@
+package artifact
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func faultRouter(f *FaultInjector) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/ping", f.Middleware("ping"), func(c *gin.Context) {
+		c.String(http.StatusOK, "pong pong pong")
+	})
+	return r
+}
+
+func getStatus(r http.Handler) int {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ping", nil))
+	return w.Code
+}
+
+func TestFaultRuleValidate(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	valid := []FaultRule{
+		{},
+		{ErrorPercent: 100, ErrorStatus: 429, Outages: []OutageWindow{{Start: &start, End: &end, Status: 502}}},
+		{ErrorPercent: 100, Latency: &LatencySpec{Distribution: "uniform", MinMs: 10, MaxMs: 20}},
+		{Outages: []OutageWindow{{Start: &start, End: &end}, {Cron: "*/5 * * * *", Duration: "1m"}}},
+	}
+	for _, r := range valid {
+		require.NoError(t, r.Validate())
+	}
+	invalid := []FaultRule{
+		{ErrorPercent: 101},
+		{ResetPercent: -1},
+		{ErrorStatus: 42},
+		{ErrorStatus: 1000},
+		{Outages: []OutageWindow{{Start: &start, End: &end, Status: 600}}},
+		{Latency: &LatencySpec{Distribution: "pareto"}},
+		{Latency: &LatencySpec{Distribution: "uniform", MinMs: 20, MaxMs: 10}},
+		{SlowDrip: &SlowDripSpec{ChunkBytes: 0}},
+		{Outages: []OutageWindow{{Start: &end, End: &start}}},
+		{Outages: []OutageWindow{{Cron: "*/5 * * * *"}}},
+	}
+	for _, r := range invalid {
+		require.Error(t, r.Validate(), "%+v", r)
+	}
+
+	_, err := NewFaultInjector(1, nil, []EndpointDef{{ID: "x", Faults: &FaultRule{ErrorPercent: 200}}})
+	require.ErrorContains(t, err, "endpoint x faults")
+}
+
+func TestFaultOutageWindows(t *testing.T) {
+	clock := NewManualClock(time.Date(2026, 1, 1, 0, 3, 0, 0, time.UTC))
+	start := time.Date(2026, 1, 1, 1, 0, 0, 0, time.UTC)
+	end := start.Add(30 * time.Minute)
+	f, err := NewFaultInjector(1, clock, []EndpointDef{{ID: "ping", Faults: &FaultRule{Outages: []OutageWindow{
+		{Start: &start, End: &end, Status: http.StatusBadGateway},
+		{Cron: "*/10 * * * *", Duration: "2m"},
+	}}}})
+	require.NoError(t, err)
+	r := faultRouter(f)
+
+	require.Equal(t, http.StatusOK, getStatus(r)) // 00:03, cron window 00:00-00:02 closed
+	clock.Set(time.Date(2026, 1, 1, 0, 11, 0, 0, time.UTC))
+	require.Equal(t, http.StatusServiceUnavailable, getStatus(r))
+	clock.Set(time.Date(2026, 1, 1, 1, 15, 0, 0, time.UTC))
+	require.Equal(t, http.StatusBadGateway, getStatus(r))
+	clock.Set(end.Add(5 * time.Minute))
+	require.Equal(t, http.StatusOK, getStatus(r))
+}
+
+func TestFaultErrorsAreSeeded(t *testing.T) {
+	endpoints := []EndpointDef{{ID: "ping", Faults: &FaultRule{ErrorPercent: 50, ErrorStatus: http.StatusInternalServerError}}}
+	sequence := func(seed int64) []int {
+		f, err := NewFaultInjector(seed, nil, endpoints)
+		require.NoError(t, err)
+		r := faultRouter(f)
+		out := make([]int, 40)
+		for i := range out {
+			out[i] = getStatus(r)
+		}
+		return out
+	}
+	first := sequence(7)
+	require.Equal(t, first, sequence(7))
+	require.Contains(t, first, http.StatusOK)
+	require.Contains(t, first, http.StatusInternalServerError)
+
+	f, err := NewFaultInjector(7, nil, endpoints)
+	require.NoError(t, err)
+	r := faultRouter(f)
+	getStatus(r)
+	f.Reseed(7)
+	require.Equal(t, first[0], getStatus(r))
+}
+
+func TestFaultLatencySampling(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	require.Equal(t, 25*time.Millisecond, sampleLatency(rng, &LatencySpec{Distribution: "fixed", Ms: 25}))
+	for i := 0; i < 100; i++ {
+		d := sampleLatency(rng, &LatencySpec{Distribution: "uniform", MinMs: 10, MaxMs: 20})
+		require.GreaterOrEqual(t, d, 10*time.Millisecond)
+		require.LessOrEqual(t, d, 20*time.Millisecond)
+		d = sampleLatency(rng, &LatencySpec{Distribution: "normal", MeanMs: 50, StddevMs: 100, MaxMs: 80})
+		require.GreaterOrEqual(t, d, time.Duration(0))
+		require.LessOrEqual(t, d, 80*time.Millisecond)
+	}
+
+	clock := NewManualClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	f, err := NewFaultInjector(1, clock, []EndpointDef{{ID: "ping", Faults: &FaultRule{
+		Latency: &LatencySpec{Distribution: "fixed", Ms: 500},
+	}}})
+	require.NoError(t, err)
+	done := make(chan int)
+	go func() { done <- getStatus(faultRouter(f)) }()
+	require.Eventually(t, func() bool { return clock.Waiters() == 1 }, time.Second, time.Millisecond)
+	clock.Advance(499 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("request finished before the injected latency elapsed")
+	default:
+	}
+	clock.Advance(time.Millisecond)
+	require.Equal(t, http.StatusOK, <-done)
+}
+
+func TestFaultSlowDripAndReset(t *testing.T) {
+	f, err := NewFaultInjector(1, nil, []EndpointDef{{ID: "ping", Faults: &FaultRule{
+		SlowDrip: &SlowDripSpec{ChunkBytes: 4, IntervalMs: 1},
+	}}})
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	faultRouter(f).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ping", nil))
+	require.Equal(t, "pong pong pong", w.Body.String())
+	require.True(t, w.Flushed)
+
+	require.NoError(t, f.SetRule("ping", &FaultRule{ResetPercent: 100}))
+	srv := httptest.NewServer(faultRouter(f))
+	defer srv.Close()
+	_, err = http.Get(srv.URL + "/ping")
+	require.Error(t, err)
+}
+
+func TestFaultResetOverTLS(t *testing.T) {
+	f, err := NewFaultInjector(1, nil, []EndpointDef{{ID: "ping", Faults: &FaultRule{ResetPercent: 100}}})
+	require.NoError(t, err)
+	srv := httptest.NewTLSServer(faultRouter(f))
+	defer srv.Close()
+	_, err = srv.Client().Get(srv.URL + "/ping")
+	require.ErrorIs(t, err, syscall.ECONNRESET, "the client sees a reset, not a clean close")
+}
+
+func TestFaultAdminAPI(t *testing.T) {
+	endpoints := []EndpointDef{{ID: "ping"}}
+	f, err := NewFaultInjector(1, nil, endpoints)
+	require.NoError(t, err)
+	r := faultRouter(f)
+	RegisterFaultAdmin(r.Group("/_admin"), f, endpoints)
+
+	call := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+	require.Equal(t, http.StatusNotFound, call(http.MethodPut, "/_admin/faults/nope", `{}`).Code)
+	require.Equal(t, http.StatusBadRequest, call(http.MethodPut, "/_admin/faults/ping", `{"errorPercent": 150}`).Code)
+
+	require.Equal(t, http.StatusOK, call(http.MethodPut, "/_admin/faults/ping", `{"errorPercent": 100, "errorStatus": 429}`).Code)
+	require.Equal(t, http.StatusTooManyRequests, getStatus(r))
+	w := call(http.MethodGet, "/_admin/faults", "")
+	require.JSONEq(t, `{"seed": 1, "rules": {"ping": {"errorPercent": 100, "errorStatus": 429}}}`, w.Body.String())
+
+	require.Equal(t, http.StatusOK, call(http.MethodDelete, "/_admin/faults/ping", "").Code)
+	require.Equal(t, http.StatusOK, getStatus(r))
+	require.JSONEq(t, `{"seed": 99}`, call(http.MethodPost, "/_admin/faults/seed", `{"seed": 99}`).Body.String())
+}
@@ -0,0 +1,420 @@
+package artifact
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FlowTestSuffix marks declarative flow test files inside repo/flows.
+const FlowTestSuffix = ".test.yaml"
+
+// FlowTestFile is the content of a *.test.yaml file.
+type FlowTestFile struct {
+	// Flow defaults to the test file name with .test.yaml replaced by .flow.yaml.
+	Flow  string         `yaml:"flow"`
+	Cases []FlowTestCase `yaml:"cases"`
+
+	path string
+}
+
+// FlowTestCase runs one request against a starting dataset state.
+type FlowTestCase struct {
+	Name    string           `yaml:"name"`
+	Request FlowTestRequest  `yaml:"request"`
+	State   map[string][]any `yaml:"state"`
+	Expect  FlowTestExpect   `yaml:"expect"`
+	// Now freezes the clock of the case. Ids always come from a generator
+	// seeded with Seed (default 1), so generated values can be asserted.
+	Now  *time.Time `yaml:"now"`
+	Seed *int64     `yaml:"seed"`
+}
+
+// FlowTestRequest is the request a case sends to its flow.
+type FlowTestRequest struct {
+	Method  string            `yaml:"method"`
+	Path    string            `yaml:"path"`
+	Params  map[string]string `yaml:"params"`
+	Query   map[string]any    `yaml:"query"`
+	Headers map[string]any    `yaml:"headers"`
+	Body    any               `yaml:"body"`
+}
+
+// FlowTestExpect lists the assertions of a case. Body is matched partially:
+// objects may carry extra fields, arrays must match element by element.
+// JSONPath maps expressions such as "$.items[0].id" to expected values.
+type FlowTestExpect struct {
+	Status   int               `yaml:"status"`
+	Headers  map[string]string `yaml:"headers"`
+	Body     any               `yaml:"body"`
+	JSONPath map[string]any    `yaml:"jsonPath"`
+}
+
+// FlowTestResult is the outcome of one case.
+type FlowTestResult struct {
+	File     string        `json:"file"`
+	Flow     string        `json:"flow"`
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+	Failures []string      `json:"failures,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// Passed reports whether the case met all of its expectations.
+func (r FlowTestResult) Passed() bool { return len(r.Failures) == 0 && r.Error == "" }
+
+// LoadFlowTests reads every *.test.yaml file under repo/flows.
+func LoadFlowTests(repoPath string) ([]*FlowTestFile, error) {
+	matches, err := filepath.Glob(filepath.Join(repoPath, "flows", "*"+FlowTestSuffix))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	files := make([]*FlowTestFile, 0, len(matches))
+	for _, m := range matches {
+		b, err := os.ReadFile(m)
+		if err != nil {
+			return nil, fmt.Errorf("read test %s: %w", m, err)
+		}
+		var f FlowTestFile
+		if err := yaml.Unmarshal(b, &f); err != nil {
+			return nil, fmt.Errorf("parse test %s: %w", filepath.Base(m), err)
+		}
+		f.path = filepath.Base(m)
+		if f.Flow == "" {
+			f.Flow = strings.TrimSuffix(f.path, FlowTestSuffix) + ".flow.yaml"
+		}
+		files = append(files, &f)
+	}
+	return files, nil
+}
+
+// RunFlowTests runs every case through Executor.Run, each against its own
+// in-memory state so cases never touch .runtime or each other.
+func RunFlowTests(ctx context.Context, repoPath string, files []*FlowTestFile) []FlowTestResult {
+	quiet := slog.New(slog.NewTextHandler(io.Discard, nil))
+	results := []FlowTestResult{}
+	for _, f := range files {
+		for i, tc := range f.Cases {
+			name := tc.Name
+			if name == "" {
+				name = fmt.Sprintf("case %d", i+1)
+			}
+			start := time.Now()
+			exec := NewExecutor(repoPath, append(tc.sources(), WithStore(NewMemoryStore(tc.State)), WithLogger(quiet))...)
+			res, err := exec.Run(ctx, f.Flow, tc.Request.toExecRequest())
+			result := FlowTestResult{File: f.path, Flow: f.Flow, Name: name}
+			if err != nil && ctx.Err() != nil {
+				result.Error = err.Error()
+			} else {
+				if err != nil {
+					res = ResponseForError(err)
+				}
+				result.Failures = tc.Expect.check(res)
+			}
+			result.Duration = time.Since(start)
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// sources returns the clock and id options of the case.
+func (tc FlowTestCase) sources() []ExecutorOption {
+	clock := SystemClock()
+	if tc.Now != nil {
+		clock = NewManualClock(*tc.Now)
+	}
+	seed := int64(1)
+	if tc.Seed != nil {
+		seed = *tc.Seed
+	}
+	return []ExecutorOption{WithClock(clock), WithIDs(NewSeededIDGenerator(clock, seed))}
+}
+
+func (r FlowTestRequest) toExecRequest() *ExecRequest {
+	method := r.Method
+	if method == "" {
+		method = "GET"
+	}
+	req := &ExecRequest{
+		RequestID: "test",
+		Method:    strings.ToUpper(method),
+		Path:      r.Path,
+		Params:    map[string]string{},
+		Query:     map[string][]string{},
+		Headers:   map[string][]string{},
+		Body:      map[string]any{},
+		Dataset:   map[string]any{},
+	}
+	for k, v := range r.Params {
+		req.Params[k] = v
+	}
+	for k, v := range r.Query {
+		req.Query[k] = normalizeStrings(v)
+	}
+	for k, v := range r.Headers {
+		req.Headers[k] = normalizeStrings(v)
+	}
+	if r.Body != nil {
+		req.Body = deepCopy(r.Body)
+	}
+	return req
+}
+
+func (x FlowTestExpect) check(res *ExecResponse) []string {
+	failures := []string{}
+	if x.Status != 0 && res.Status != x.Status {
+		failures = append(failures, fmt.Sprintf("status: expected %d, got %d", x.Status, res.Status))
+	}
+	for k, want := range x.Headers {
+		if got := headerValue(res.Headers, k); got != want {
+			failures = append(failures, fmt.Sprintf("header %s: expected %q, got %q", k, want, got))
+		}
+	}
+	body := deepCopy(res.Body)
+	if x.Body != nil {
+		failures = append(failures, matchPartial("body", deepCopy(x.Body), body)...)
+	}
+	paths := make([]string, 0, len(x.JSONPath))
+	for p := range x.JSONPath {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		got, ok, err := lookupJSONPath(body, p)
+		switch {
+		case err != nil:
+			failures = append(failures, fmt.Sprintf("%s: %v", p, err))
+		case !ok:
+			failures = append(failures, fmt.Sprintf("%s: not found", p))
+		default:
+			failures = append(failures, matchPartial(p, deepCopy(x.JSONPath[p]), got)...)
+		}
+	}
+	return failures
+}
+
+func headerValue(h map[string]string, key string) string {
+	for k, v := range h {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+// matchPartial reports where got does not contain want.
+func matchPartial(path string, want, got any) []string {
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected object, got %s", path, describe(got))}
+		}
+		keys := make([]string, 0, len(w))
+		for k := range w {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := []string{}
+		for _, k := range keys {
+			gv, present := g[k]
+			if !present {
+				out = append(out, fmt.Sprintf("%s.%s: missing", path, k))
+				continue
+			}
+			out = append(out, matchPartial(path+"."+k, w[k], gv)...)
+		}
+		return out
+	case []any:
+		g, ok := got.([]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected array, got %s", path, describe(got))}
+		}
+		if len(g) != len(w) {
+			return []string{fmt.Sprintf("%s: expected %d items, got %d", path, len(w), len(g))}
+		}
+		out := []string{}
+		for i := range w {
+			out = append(out, matchPartial(fmt.Sprintf("%s[%d]", path, i), w[i], g[i])...)
+		}
+		return out
+	default:
+		if !reflect.DeepEqual(want, got) {
+			return []string{fmt.Sprintf("%s: expected %s, got %s", path, describe(want), describe(got))}
+		}
+		return nil
+	}
+}
+
+func describe(v any) string {
+	if v == nil {
+		return "null"
+	}
+	if s, ok := v.(string); ok {
+		return strconv.Quote(s)
+	}
+	return toString(v)
+}
+
+// lookupJSONPath evaluates a dotted JSONPath subset: "$", ".field", "[n]"
+// and "['field']".
+func lookupJSONPath(root any, expr string) (any, bool, error) {
+	expr = strings.TrimSpace(expr)
+	if !strings.HasPrefix(expr, "$") {
+		return nil, false, fmt.Errorf("json path must start with $")
+	}
+	cur := root
+	rest := expr[1:]
+	for rest != "" {
+		switch {
+		case rest[0] == '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			key := rest[:end]
+			rest = rest[end:]
+			m, ok := cur.(map[string]any)
+			if !ok {
+				return nil, false, nil
+			}
+			if cur, ok = m[key]; !ok {
+				return nil, false, nil
+			}
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, false, fmt.Errorf("unterminated [ in %s", expr)
+			}
+			token := rest[1:end]
+			rest = rest[end+1:]
+			if quoted := strings.Trim(token, `'"`); quoted != token {
+				m, ok := cur.(map[string]any)
+				if !ok {
+					return nil, false, nil
+				}
+				if cur, ok = m[quoted]; !ok {
+					return nil, false, nil
+				}
+				continue
+			}
+			idx, err := strconv.Atoi(token)
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid index %q in %s", token, expr)
+			}
+			arr, ok := cur.([]any)
+			if !ok {
+				return nil, false, nil
+			}
+			if idx < 0 {
+				idx += len(arr)
+			}
+			if idx < 0 || idx >= len(arr) {
+				return nil, false, nil
+			}
+			cur = arr[idx]
+		default:
+			return nil, false, fmt.Errorf("unexpected %q in %s", rest[0], expr)
+		}
+	}
+	return cur, true, nil
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// WriteJUnit renders results as a JUnit XML report, one suite per test file.
+func WriteJUnit(w io.Writer, results []FlowTestResult) error {
+	report := junitTestSuites{}
+	index := map[string]int{}
+	var total time.Duration
+	for _, r := range results {
+		i, ok := index[r.File]
+		if !ok {
+			i = len(report.Suites)
+			index[r.File] = i
+			report.Suites = append(report.Suites, junitTestSuite{Name: r.File})
+		}
+		suite := &report.Suites[i]
+		tc := junitTestCase{Name: r.Name, ClassName: r.Flow, Time: seconds(r.Duration)}
+		switch {
+		case r.Error != "":
+			tc.Error = &junitMessage{Message: r.Error, Body: r.Error}
+			suite.Errors++
+		case len(r.Failures) > 0:
+			tc.Failure = &junitMessage{Message: r.Failures[0], Body: strings.Join(r.Failures, "\n")}
+			suite.Failures++
+		}
+		suite.Tests++
+		suite.Cases = append(suite.Cases, tc)
+		total += r.Duration
+	}
+	for i := range report.Suites {
+		var d time.Duration
+		for _, c := range results {
+			if c.File == report.Suites[i].Name {
+				d += c.Duration
+			}
+		}
+		report.Suites[i].Time = seconds(d)
+		report.Tests += report.Suites[i].Tests
+		report.Failures += report.Suites[i].Failures
+		report.Errors += report.Suites[i].Errors
+	}
+	report.Time = seconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func seconds(d time.Duration) string { return strconv.FormatFloat(d.Seconds(), 'f', 3, 64) }
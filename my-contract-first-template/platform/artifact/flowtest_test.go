@@ -0,0 +1,104 @@
+package artifact
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupJSONPath(t *testing.T) {
+	doc := map[string]any{
+		"items": []any{map[string]any{"id": "a"}, map[string]any{"id": "b"}},
+		"meta":  map[string]any{"odd key": 1.0},
+	}
+
+	got, ok, err := lookupJSONPath(doc, "$.items[1].id")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "b", got)
+
+	got, ok, _ = lookupJSONPath(doc, "$.items[-1].id")
+	require.True(t, ok)
+	require.Equal(t, "b", got)
+
+	got, ok, _ = lookupJSONPath(doc, "$.meta['odd key']")
+	require.True(t, ok)
+	require.Equal(t, 1.0, got)
+
+	_, ok, _ = lookupJSONPath(doc, "$.items[5]")
+	require.False(t, ok)
+
+	_, _, err = lookupJSONPath(doc, "items")
+	require.Error(t, err)
+}
+
+func TestMatchPartial(t *testing.T) {
+	got := map[string]any{"id": "u_1", "tags": []any{"a", "b"}, "extra": true}
+
+	require.Empty(t, matchPartial("body", map[string]any{"id": "u_1"}, got))
+	require.Equal(t, []string{`body.id: expected "u_2", got "u_1"`},
+		matchPartial("body", map[string]any{"id": "u_2"}, got))
+	require.Equal(t, []string{"body.tags: expected 1 items, got 2"},
+		matchPartial("body", map[string]any{"tags": []any{"a"}}, got))
+	require.Equal(t, []string{"body.missing: missing"},
+		matchPartial("body", map[string]any{"missing": nil}, got))
+}
+
+func TestRunFlowTestsIsolatesStateAndReportsJUnit(t *testing.T) {
+	repo := t.TempDir()
+	writeTestFlow(t, repo, "items.flow.yaml", `
+version: 1
+steps:
+  - op: insertRecord
+    args:
+      dataset: items
+      record: "$request.body"
+  - op: loadDataset
+    args:
+      dataset: items
+    out: all
+  - op: respond
+    args:
+      status: 201
+      bodyFrom: "$ctx.all"
+`)
+	writeTestFlow(t, repo, "items"+FlowTestSuffix, `
+cases:
+  - name: starts from given state
+    request: { method: POST, body: { id: b } }
+    state:
+      items: [{ id: a }]
+    expect:
+      status: 201
+      jsonPath:
+        $[0].id: a
+        $[1].id: b
+  - name: does not see the previous case
+    request: { method: POST, body: { id: c } }
+    expect:
+      status: 200
+      body: [{ id: c }, { id: x }]
+`)
+
+	files, err := LoadFlowTests(repo)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, "items.flow.yaml", files[0].Flow)
+
+	results := RunFlowTests(context.Background(), repo, files)
+	require.Len(t, results, 2)
+	require.True(t, results[0].Passed(), results[0].Failures)
+	require.Equal(t, []string{"status: expected 200, got 201", "body: expected 2 items, got 1"}, results[1].Failures)
+
+	_, err = os.Stat(filepath.Join(repo, ".runtime"))
+	require.True(t, os.IsNotExist(err), "tests must not write runtime state")
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteJUnit(&buf, results))
+	require.Contains(t, buf.String(), `<testsuite name="items.test.yaml" tests="2" failures="1" errors="0"`)
+	require.Contains(t, buf.String(), `<failure message="status: expected 200, got 201">`)
+}
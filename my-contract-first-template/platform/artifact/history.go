@@ -0,0 +1,260 @@
+package artifact
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DatasetDef opts a dataset into record history and soft delete and
+// describes its records.
+type DatasetDef struct {
+	// History appends every insert, update, delete and restore to the
+	// dataset <name>.history.
+	History bool `json:"history,omitempty"`
+	// SoftDelete makes deleteRecord set DeletedAtField instead of dropping
+	// the record; loadDataset then leaves such records out.
+	SoftDelete bool `json:"softDelete,omitempty"`
+	// Schema is the JSON Schema the bulk ops check every record against.
+	Schema map[string]any `json:"schema,omitempty"`
+}
+
+// HistorySuffix names the dataset holding the change log of another.
+const HistorySuffix = ".history"
+
+// DeletedAtField marks soft-deleted records.
+const DeletedAtField = "deletedAt"
+
+// History operations.
+const (
+	HistoryInsert  = "insert"
+	HistoryUpdate  = "update"
+	HistoryDelete  = "delete"
+	HistoryRestore = "restore"
+)
+
+// HistoryEntry is one change of a record. Seq numbers the entries of a
+// dataset from 1; Before is empty for inserts and After for hard deletes.
+type HistoryEntry struct {
+	Seq       int            `json:"seq"`
+	Time      time.Time      `json:"time"`
+	Op        string         `json:"op"`
+	RecordID  string         `json:"recordId"`
+	RequestID string         `json:"requestId,omitempty"`
+	Before    map[string]any `json:"before,omitempty"`
+	After     map[string]any `json:"after,omitempty"`
+}
+
+// WithDatasets sets the dataset options instead of reading the datasets block
+// of the repo's api/index.json.
+func WithDatasets(defs map[string]DatasetDef) ExecutorOption {
+	return func(e *Executor) { e.datasets = defs }
+}
+
+// saveChange saves data, the records of dataset after one change, and logs
+// the change when the dataset keeps history.
+func (e *Executor) saveChange(clock Clock, rt map[string]any, dataset string, data []any, op, id string, before, after map[string]any) error {
+	return e.saveChanges(clock, rt, dataset, data, []historyChange{{op: op, id: id, before: before, after: after}})
+}
+
+// historyChange is one change waiting to be logged by saveChanges.
+type historyChange struct {
+	op            string
+	id            string
+	before, after map[string]any
+}
+
+// saveChanges saves data and logs changes in one history write. The history
+// is written first and put back when saving data fails, so the records
+// never change without their entries.
+func (e *Executor) saveChanges(clock Clock, rt map[string]any, dataset string, data []any, changes []historyChange) error {
+	if !e.datasets[dataset].History || len(changes) == 0 {
+		return e.writeState(dataset, data)
+	}
+	previous, existed, err := e.store.Load(dataset + HistorySuffix)
+	if err != nil {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+	log := append([]any{}, previous...)
+	now := clock.Now().UTC()
+	requestID := str(getByPath(rt, []string{"request", "id"}))
+	for _, c := range changes {
+		log = append(log, deepCopy(HistoryEntry{
+			Seq:       len(log) + 1,
+			Time:      now,
+			Op:        c.op,
+			RecordID:  c.id,
+			RequestID: requestID,
+			Before:    deepCopyMap(c.before),
+			After:     deepCopyMap(c.after),
+		}))
+	}
+	if err := e.writeState(dataset+HistorySuffix, log); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if err := e.writeState(dataset, data); err != nil {
+		rollback := e.writeState(dataset+HistorySuffix, previous)
+		if !existed {
+			rollback = e.store.Delete(dataset + HistorySuffix)
+		}
+		if rollback != nil {
+			return fmt.Errorf("%w (history keeps the unsaved change: %v)", err, rollback)
+		}
+		return err
+	}
+	return nil
+}
+
+func deepCopyMap(m map[string]any) map[string]any {
+	if m == nil {
+		return nil
+	}
+	out, _ := toMap(deepCopy(m))
+	return out
+}
+
+// recordHistory returns the logged changes of one record, oldest first.
+func (e *Executor) recordHistory(dataset, id string) ([]HistoryEntry, error) {
+	if !e.datasets[dataset].History {
+		return nil, &StepError{Status: 404, Msg: fmt.Sprintf("dataset %s does not keep history", dataset)}
+	}
+	b, err := json.Marshal(e.readState(dataset + HistorySuffix))
+	if err != nil {
+		return nil, err
+	}
+	var all []HistoryEntry
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, fmt.Errorf("history of %s: %w", dataset, err)
+	}
+	out := []HistoryEntry{}
+	for _, h := range all {
+		if h.RecordID == id {
+			out = append(out, h)
+		}
+	}
+	return out, nil
+}
+
+// restoreRecord puts a record back into the state it had after history entry
+// seq, re-inserting it when it has been deleted since.
+func (e *Executor) restoreRecord(clock Clock, rt map[string]any, dataset, id string, seq int) (map[string]any, error) {
+	defer e.lockDataset(dataset)()
+	entries, err := e.recordHistory(dataset, id)
+	if err != nil {
+		return nil, err
+	}
+	var target map[string]any
+	found := false
+	for _, h := range entries {
+		if h.Seq == seq {
+			target, found = h.After, true
+		}
+	}
+	switch {
+	case !found:
+		return nil, &StepError{Status: 404, Msg: fmt.Sprintf("record %s has no history entry %d", id, seq)}
+	case target == nil:
+		return nil, &StepError{Status: 409, Msg: fmt.Sprintf("history entry %d deleted record %s; restore an earlier entry", seq, id)}
+	}
+
+	data := e.readState(dataset)
+	// The live record is restored in place. Without one, a soft-deleted
+	// record with the id is brought back instead of adding a second.
+	var before map[string]any
+	index := -1
+	for i, it := range data {
+		m, ok := toMap(it)
+		if !ok || toString(m["id"]) != id {
+			continue
+		}
+		live := !e.softDeleted(dataset, m)
+		if index < 0 || live {
+			before, index = m, i
+		}
+		if live {
+			break
+		}
+	}
+	change := ChangeUpdate
+	if index < 0 || e.softDeleted(dataset, before) {
+		change = ChangeInsert
+	}
+	restored := deepCopyMap(target)
+	if index >= 0 {
+		data[index] = restored
+	} else {
+		data = append(data, restored)
+	}
+	if err := e.saveChange(clock, rt, dataset, data, HistoryRestore, id, before, restored); err != nil {
+		return nil, fmt.Errorf("failed to restore record: %w", err)
+	}
+	e.changes.Publish(ChangeEvent{Type: change, Dataset: dataset, RecordID: id, Record: deepCopy(restored), Time: clock.Now().UTC()})
+	return restored, nil
+}
+
+func (e *Executor) opHistory(args map[string]any, rt map[string]any) (any, error) {
+	dataset := str(args["dataset"])
+	id := toString(getExpr(rt, args["id"], ""))
+	if dataset == "" || id == "" {
+		return nil, errors.New("history requires dataset and id")
+	}
+	entries, err := e.recordHistory(dataset, id)
+	if err != nil {
+		return nil, err
+	}
+	return deepCopy(entries), nil
+}
+
+func (e *Executor) opRestoreRecord(clock Clock, args map[string]any, rt map[string]any) (any, error) {
+	dataset := str(args["dataset"])
+	id := toString(getExpr(rt, args["id"], ""))
+	seq := toInt(getExpr(rt, args["seq"], 0))
+	if dataset == "" || id == "" || seq <= 0 {
+		return nil, errors.New("restoreRecord requires dataset, id and seq")
+	}
+	return e.restoreRecord(clock, rt, dataset, id, seq)
+}
+
+// RecordHistory lists the logged changes of one record.
+func (e *Executor) RecordHistory(dataset, id string) ([]HistoryEntry, error) {
+	if err := validStateName("dataset", dataset); err != nil {
+		return nil, err
+	}
+	e.stateMu.RLock()
+	defer e.stateMu.RUnlock()
+	return e.recordHistory(dataset, id)
+}
+
+// RestoreRecord restores a record to its state after history entry seq.
+func (e *Executor) RestoreRecord(dataset, id string, seq int) (map[string]any, error) {
+	if err := validStateName("dataset", dataset); err != nil {
+		return nil, err
+	}
+	e.stateMu.Lock()
+	defer e.stateMu.Unlock()
+	return e.restoreRecord(e.clock, map[string]any{}, dataset, id, seq)
+}
+
+// registerHistoryAdmin mounts the record history endpoints of
+// RegisterStateAdmin.
+func registerHistoryAdmin(g *gin.RouterGroup, e *Executor) {
+	g.GET("/datasets/:dataset/records/:id/history", func(c *gin.Context) {
+		entries, err := e.RecordHistory(c.Param("dataset"), c.Param("id"))
+		respondState(c, map[string]any{"history": entries}, err)
+	})
+	g.POST("/datasets/:dataset/records/:id/restore", func(c *gin.Context) {
+		var body struct {
+			Seq int `json:"seq"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil || body.Seq <= 0 {
+			c.JSON(http.StatusBadRequest, map[string]string{"error": `body must be {"seq": <history entry>}`})
+			return
+		}
+		record, err := e.RestoreRecord(c.Param("dataset"), c.Param("id"), body.Seq)
+		respondState(c, map[string]any{"record": record, "restoredFrom": body.Seq}, err)
+	})
+}
@@ -0,0 +1,254 @@
+package artifact
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+// historyFlows call each record and history op on the users dataset.
+var historyFlows = map[string]string{
+	"insert.flow.yaml": `
+steps:
+  - op: insertRecord
+    args: { dataset: users, record: "$request.body" }
+    out: saved
+  - op: respond
+    args: { status: 201, bodyFrom: "$ctx.saved" }
+`,
+	"update.flow.yaml": `
+steps:
+  - op: updateRecord
+    args: { dataset: users, id: "$request.params.id", patch: "$request.body" }
+    out: saved
+  - op: respond
+    args: { status: 200, bodyFrom: "$ctx.saved" }
+`,
+	"delete.flow.yaml": `
+steps:
+  - op: deleteRecord
+    args: { dataset: users, id: "$request.params.id" }
+  - op: respond
+    args: { status: 204 }
+`,
+	"list.flow.yaml": `
+steps:
+  - op: loadDataset
+    args: { dataset: users }
+    out: users
+  - op: respond
+    args: { status: 200, bodyFrom: "$ctx.users" }
+`,
+	"history.flow.yaml": `
+steps:
+  - op: history
+    args: { dataset: users, id: "$request.params.id" }
+    out: history
+  - op: respond
+    args: { status: 200, bodyFrom: "$ctx.history" }
+`,
+	"restore.flow.yaml": `
+steps:
+  - op: restoreRecord
+    args: { dataset: users, id: "$request.params.id", seq: "$request.body.seq" }
+    out: record
+  - op: respond
+    args: { status: 200, bodyFrom: "$ctx.record" }
+`,
+}
+
+// usersExecutor runs flows against users u1 and u2 in a memory store, with
+// the clock stopped at 2026-04-01 09:00 UTC.
+func usersExecutor(t *testing.T, users DatasetDef, flows map[string]string) (*Executor, *MemoryStore) {
+	t.Helper()
+	repo := t.TempDir()
+	for name, content := range flows {
+		writeTestFlow(t, repo, name, content)
+	}
+	store := NewMemoryStore(map[string][]any{"users": {
+		map[string]any{"id": "u1", "name": "Ann", "role": "admin"},
+		map[string]any{"id": "u2", "name": "Bob"},
+	}})
+	clock := NewManualClock(time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC))
+	exec := NewExecutor(repo, WithStore(store), WithClock(clock), WithDatasets(map[string]DatasetDef{"users": users}))
+	return exec, store
+}
+
+func historyExecutor(t *testing.T) (*Executor, *MemoryStore) {
+	t.Helper()
+	return usersExecutor(t, DatasetDef{History: true, SoftDelete: true}, historyFlows)
+}
+
+func runHistoryFlow(t *testing.T, exec *Executor, flow, id string, body any) (*ExecResponse, error) {
+	t.Helper()
+	return exec.Run(context.Background(), flow, &ExecRequest{
+		RequestID: "req-" + strings.TrimSuffix(flow, ".flow.yaml"),
+		Method:    "POST",
+		Params:    map[string]string{"id": id},
+		Body:      body,
+	})
+}
+
+func TestHistoryAndSoftDelete(t *testing.T) {
+	exec, store := historyExecutor(t)
+
+	_, err := runHistoryFlow(t, exec, "update.flow.yaml", "u1", map[string]any{"role": "viewer"})
+	require.NoError(t, err)
+	_, err = runHistoryFlow(t, exec, "delete.flow.yaml", "u1", nil)
+	require.NoError(t, err)
+
+	res, err := runHistoryFlow(t, exec, "list.flow.yaml", "", nil)
+	require.NoError(t, err)
+	require.Len(t, res.Body, 1, "soft-deleted records are not loaded")
+	saved, _, _ := store.Load("users")
+	require.Len(t, saved, 2)
+	require.Equal(t, "2026-04-01T09:00:00Z", saved[0].(map[string]any)[DeletedAtField])
+
+	_, err = runHistoryFlow(t, exec, "update.flow.yaml", "u1", map[string]any{"role": "admin"})
+	var stepErr *StepError
+	require.ErrorAs(t, err, &stepErr)
+	require.Equal(t, 404, stepErr.Status, "deleted records cannot be updated")
+
+	res, err = runHistoryFlow(t, exec, "history.flow.yaml", "u1", nil)
+	require.NoError(t, err)
+	entries := res.Body.([]any)
+	require.Len(t, entries, 2)
+	first := entries[0].(map[string]any)
+	require.Equal(t, float64(1), first["seq"])
+	require.Equal(t, HistoryUpdate, first["op"])
+	require.Equal(t, "req-update", first["requestId"])
+	require.Equal(t, "admin", first["before"].(map[string]any)["role"])
+	require.Equal(t, "viewer", first["after"].(map[string]any)["role"])
+	require.Equal(t, HistoryDelete, entries[1].(map[string]any)["op"])
+
+	res, err = runHistoryFlow(t, exec, "restore.flow.yaml", "u1", map[string]any{"seq": 1})
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"id": "u1", "name": "Ann", "role": "viewer"}, res.Body)
+	res, err = runHistoryFlow(t, exec, "list.flow.yaml", "", nil)
+	require.NoError(t, err)
+	require.Len(t, res.Body, 2, "restoring undeletes the record")
+
+	_, err = runHistoryFlow(t, exec, "restore.flow.yaml", "u1", map[string]any{"seq": 9})
+	require.ErrorAs(t, err, &stepErr)
+	require.Equal(t, 404, stepErr.Status)
+
+	history, err := exec.RecordHistory("users", "u1")
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	require.Equal(t, HistoryRestore, history[2].Op)
+}
+
+func TestHardDeleteHistoryAndAdmin(t *testing.T) {
+	exec, _ := historyExecutor(t)
+	exec.datasets = map[string]DatasetDef{"users": {History: true}}
+
+	_, err := runHistoryFlow(t, exec, "delete.flow.yaml", "u2", nil)
+	require.NoError(t, err)
+	history, err := exec.RecordHistory("users", "u2")
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	require.Nil(t, history[0].After)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	RegisterStateAdmin(r.Group("/_admin"), exec)
+	post := func(url, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, url, strings.NewReader(body)))
+		return w
+	}
+	require.Equal(t, http.StatusConflict, post("/_admin/datasets/users/records/u2/restore", `{"seq": 1}`).Code)
+	require.Equal(t, http.StatusBadRequest, post("/_admin/datasets/users/records/u2/restore", `{}`).Code)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/_admin/datasets/users/records/u2/history", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"op":"delete"`)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/_admin/datasets/orders/records/o1/history", nil))
+	require.Equal(t, http.StatusNotFound, w.Code, "datasets without history")
+}
+
+func TestFailedSaveLeavesRecordsAndHistoryAlone(t *testing.T) {
+	exec, store := historyExecutor(t)
+	failing := &failingSaveStore{MemoryStore: store, dataset: "users" + HistorySuffix}
+	exec.store = failing
+
+	_, err := runHistoryFlow(t, exec, "update.flow.yaml", "u1", map[string]any{"role": "viewer"})
+	require.ErrorContains(t, err, "failed to write history")
+	saved, _, _ := store.Load("users")
+	require.Equal(t, "admin", saved[0].(map[string]any)["role"], "the record is not changed without its history")
+
+	failing.dataset = ""
+	_, err = runHistoryFlow(t, exec, "update.flow.yaml", "u1", map[string]any{"role": "viewer"})
+	require.NoError(t, err)
+
+	failing.dataset = "users"
+	_, err = runHistoryFlow(t, exec, "delete.flow.yaml", "u2", nil)
+	require.ErrorContains(t, err, "failed to delete record: disk full")
+	history, _, _ := store.Load("users" + HistorySuffix)
+	require.Len(t, history, 1, "the entry of the unsaved delete is taken back")
+}
+
+func TestRestoreTargetsTheLiveRecord(t *testing.T) {
+	exec, store := historyExecutor(t)
+
+	_, err := runHistoryFlow(t, exec, "update.flow.yaml", "u1", map[string]any{"role": "viewer"})
+	require.NoError(t, err)
+	_, err = runHistoryFlow(t, exec, "delete.flow.yaml", "u1", nil)
+	require.NoError(t, err)
+	_, err = runHistoryFlow(t, exec, "insert.flow.yaml", "", map[string]any{"id": "u1", "name": "Ann 2"})
+	require.NoError(t, err)
+
+	res, err := runHistoryFlow(t, exec, "restore.flow.yaml", "u1", map[string]any{"seq": 1})
+	require.NoError(t, err)
+	require.Equal(t, "Ann", res.Body.(map[string]any)["name"])
+
+	saved, _, _ := store.Load("users")
+	require.Len(t, saved, 3)
+	require.NotNil(t, saved[0].(map[string]any)[DeletedAtField], "the deleted copy stays deleted")
+	require.Equal(t, map[string]any{"id": "u1", "name": "Ann", "role": "viewer"}, saved[2], "the live copy is restored")
+	res, err = runHistoryFlow(t, exec, "list.flow.yaml", "", nil)
+	require.NoError(t, err)
+	require.Len(t, res.Body, 2, "one live u1 and u2")
+}
+
+func TestConcurrentWritesKeepHistorySeqsUnique(t *testing.T) {
+	exec, store := historyExecutor(t)
+	const writers = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := runHistoryFlow(t, exec, "insert.flow.yaml", "", map[string]any{"id": fmt.Sprintf("n%d", i)})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	saved, _, _ := store.Load("users")
+	require.Len(t, saved, writers+2, "no insert is lost")
+	history, _, _ := store.Load("users" + HistorySuffix)
+	require.Len(t, history, writers)
+	seen := map[float64]bool{}
+	for _, h := range history {
+		seq := h.(map[string]any)["seq"].(float64)
+		require.False(t, seen[seq], "seq %v is used twice", seq)
+		seen[seq] = true
+	}
+}
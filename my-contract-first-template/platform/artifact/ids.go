@@ -0,0 +1,194 @@
+package artifact
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+	mrand "math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ID strategies of the assignId op.
+const (
+	// IDTimestamp is prefix + Unix nanoseconds, bumped so ids never repeat.
+	IDTimestamp = "timestamp"
+	IDUUID4     = "uuid4"
+	IDUUID7     = "uuid7"
+	IDULID      = "ulid"
+	// IDSequence continues the numeric suffix of the ids already in a dataset.
+	IDSequence = "sequence"
+)
+
+// IDGenerator hands out record ids. It is safe for concurrent use; with a
+// seeded random source and a frozen clock it yields the same ids every run.
+type IDGenerator struct {
+	mu       sync.Mutex
+	clock    Clock
+	random   io.Reader
+	lastNano int64
+	lastMs   int64
+	lastRand [10]byte
+	seqs     map[string]int64
+}
+
+// NewIDGenerator reads time from clock and random bits from random, or from
+// crypto/rand when random is nil.
+func NewIDGenerator(clock Clock, random io.Reader) *IDGenerator {
+	if clock == nil {
+		clock = SystemClock()
+	}
+	if random == nil {
+		random = rand.Reader
+	}
+	return &IDGenerator{clock: clock, random: random, seqs: map[string]int64{}}
+}
+
+// NewSeededIDGenerator is NewIDGenerator with a deterministic random source.
+func NewSeededIDGenerator(clock Clock, seed int64) *IDGenerator {
+	return NewIDGenerator(clock, mrand.New(mrand.NewSource(seed)))
+}
+
+// New returns an id of one of the time or random based strategies.
+func (g *IDGenerator) New(strategy string) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch strategy {
+	case "", IDTimestamp:
+		n := g.clock.Now().UnixNano()
+		if n <= g.lastNano {
+			n = g.lastNano + 1
+		}
+		g.lastNano = n
+		return strconv.FormatInt(n, 10), nil
+	case IDUUID4:
+		var b [16]byte
+		if _, err := io.ReadFull(g.random, b[:]); err != nil {
+			return "", err
+		}
+		return formatUUID(b, 4), nil
+	case IDUUID7:
+		var b [16]byte
+		putMillis(b[:6], g.clock.Now().UnixMilli())
+		if _, err := io.ReadFull(g.random, b[6:]); err != nil {
+			return "", err
+		}
+		return formatUUID(b, 7), nil
+	case IDULID:
+		return g.ulid()
+	}
+	return "", fmt.Errorf("unknown id strategy %q", strategy)
+}
+
+// ResetSequences forgets the ids handed out for datasets, or for every
+// dataset when none are given, so their sequences continue from the
+// records alone.
+func (g *IDGenerator) ResetSequences(datasets ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(datasets) == 0 {
+		g.seqs = map[string]int64{}
+		return
+	}
+	for _, ds := range datasets {
+		delete(g.seqs, ds)
+	}
+}
+
+// Sequence returns one more than the largest numeric suffix after prefix
+// among the field values of records, or among the ids it handed out before
+// for dataset, whichever is larger.
+func (g *IDGenerator) Sequence(dataset, prefix, field string, records []any) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	last := g.seqs[dataset]
+	for _, r := range records {
+		m, ok := r.(map[string]any)
+		if !ok {
+			continue
+		}
+		id := str(m[field])
+		if !strings.HasPrefix(id, prefix) {
+			continue
+		}
+		if n, err := strconv.ParseInt(id[len(prefix):], 10, 64); err == nil && n > last {
+			last = n
+		}
+	}
+	last++
+	g.seqs[dataset] = last
+	return prefix + strconv.FormatInt(last, 10)
+}
+
+func formatUUID(b [16]byte, version byte) string {
+	b[6] = b[6]&0x0f | version<<4
+	b[8] = b[8]&0x3f | 0x80
+	s := hex.EncodeToString(b[:])
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
+
+func putMillis(dst []byte, ms int64) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(ms))
+	copy(dst, buf[2:])
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ulid returns a ULID. Ids minted within the same millisecond increment the
+// random part, so they still sort in creation order.
+func (g *IDGenerator) ulid() (string, error) {
+	ms := g.clock.Now().UnixMilli()
+	if ms <= g.lastMs {
+		ms = g.lastMs
+		for i := len(g.lastRand) - 1; i >= 0; i-- {
+			g.lastRand[i]++
+			if g.lastRand[i] != 0 {
+				break
+			}
+		}
+	} else if _, err := io.ReadFull(g.random, g.lastRand[:]); err != nil {
+		return "", err
+	}
+	g.lastMs = ms
+	var b [16]byte
+	putMillis(b[:6], ms)
+	copy(b[6:], g.lastRand[:])
+	n := new(big.Int).SetBytes(b[:])
+	out := make([]byte, 26)
+	rem, base := new(big.Int), big.NewInt(32)
+	for i := len(out) - 1; i >= 0; i-- {
+		n.DivMod(n, base, rem)
+		out[i] = crockford[rem.Int64()]
+	}
+	return string(out), nil
+}
+
+type frozenRunKey struct{}
+
+type frozenRun struct {
+	now  time.Time
+	seed int64
+}
+
+// WithFrozenRun makes Executor.Run read time from a clock stopped at now and
+// draw ids from a generator seeded with seed, so the run can be repeated
+// with identical results. Recorder uses it for every captured request.
+func WithFrozenRun(ctx context.Context, now time.Time, seed int64) context.Context {
+	return context.WithValue(ctx, frozenRunKey{}, frozenRun{now: now, seed: seed})
+}
+
+// runSources returns the clock and id generator of one run.
+func (e *Executor) runSources(ctx context.Context) (Clock, *IDGenerator) {
+	if f, ok := ctx.Value(frozenRunKey{}).(frozenRun); ok {
+		clock := NewManualClock(f.now)
+		return clock, NewSeededIDGenerator(clock, f.seed)
+	}
+	return e.clock, e.ids
+}
@@ -0,0 +1,185 @@
+package artifact
+
+import (
+	"context"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIDGeneratorStrategies(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	g := NewSeededIDGenerator(NewManualClock(now), 7)
+
+	formats := map[string]*regexp.Regexp{
+		IDTimestamp: regexp.MustCompile(`^\d+$`),
+		IDUUID4:     regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`),
+		IDUUID7:     regexp.MustCompile(`^019ca945-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`),
+		IDULID:      regexp.MustCompile(`^01KJMMA2G0[0-9A-HJKMNP-TV-Z]{16}$`),
+	}
+	for strategy, re := range formats {
+		id, err := g.New(strategy)
+		require.NoError(t, err)
+		require.Regexp(t, re, id, strategy)
+	}
+	_, err := g.New("snowflake")
+	require.Error(t, err)
+
+	// A frozen clock still yields unique, ordered timestamps and ULIDs.
+	a, _ := g.New(IDTimestamp)
+	b, _ := g.New(IDTimestamp)
+	require.Less(t, a, b)
+	u1, _ := g.New(IDULID)
+	u2, _ := g.New(IDULID)
+	require.Less(t, u1, u2)
+}
+
+func TestIDGeneratorIsReproducible(t *testing.T) {
+	run := func() []string {
+		g := NewSeededIDGenerator(NewManualClock(time.Unix(1700000000, 0)), 42)
+		var out []string
+		for _, strategy := range []string{IDTimestamp, IDUUID4, IDUUID7, IDULID, IDULID} {
+			id, err := g.New(strategy)
+			require.NoError(t, err)
+			out = append(out, id)
+		}
+		return out
+	}
+	require.Equal(t, run(), run())
+}
+
+func TestIDGeneratorSequenceAndConcurrency(t *testing.T) {
+	g := NewIDGenerator(nil, nil)
+	records := []any{map[string]any{"id": "u_7"}, map[string]any{"id": "u_12"}, map[string]any{"id": "x_99"}}
+	require.Equal(t, "u_13", g.Sequence("users", "u_", "id", records))
+	require.Equal(t, "u_14", g.Sequence("users", "u_", "id", records), "ids handed out but not yet stored still count")
+	require.Equal(t, "1", g.Sequence("orders", "", "id", nil))
+
+	var (
+		mu   sync.Mutex
+		seen = map[string]bool{}
+		wg   sync.WaitGroup
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, strategy := range []string{IDTimestamp, IDULID, IDUUID7} {
+				id, err := g.New(strategy)
+				require.NoError(t, err)
+				mu.Lock()
+				require.False(t, seen[id], "duplicate id %s", id)
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestFrozenRunAssignsReproducibleIDs(t *testing.T) {
+	repo := t.TempDir()
+	writeTestFlow(t, repo, "ids.flow.yaml", `
+steps:
+  - op: assignId
+    args: { strategy: uuid4, prefix: "u_" }
+    out: uuid
+  - op: assignId
+    args: { strategy: sequence, dataset: users, prefix: "n" }
+    out: seq
+  - op: now
+    out: now
+  - op: respond
+    args:
+      status: 200
+      bodyFrom: "$ctx"
+`)
+	exec := NewExecutor(repo, WithStore(NewMemoryStore(map[string][]any{"users": {map[string]any{"id": "n4"}}})))
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	run := func() map[string]any {
+		res, err := exec.Run(WithFrozenRun(context.Background(), now, 9), "ids.flow.yaml", &ExecRequest{Method: "GET"})
+		require.NoError(t, err)
+		return res.Body.(map[string]any)
+	}
+	body := run()
+	require.Equal(t, body, run())
+	require.Equal(t, "n5", body["seq"])
+	require.Equal(t, "2026-03-01T12:00:00Z", body["now"])
+	require.Regexp(t, `^u_[0-9a-f-]{36}$`, body["uuid"])
+}
+
+func TestSequenceContinuesFromSeedRecords(t *testing.T) {
+	repo := t.TempDir()
+	writeTestSeed(t, repo, "users", `[{"id": "u_1", "name": "Alice"}, {"id": "u_7", "name": "Bob"}]`)
+	writeTestFlow(t, repo, "next.flow.yaml", `
+steps:
+  - op: assignId
+    args: { strategy: sequence, dataset: users, prefix: "u_" }
+    out: id
+  - op: respond
+    args: { status: 200, bodyFrom: "$ctx.id" }
+`)
+	writeTestFlow(t, repo, "bulk.flow.yaml", `
+steps:
+  - op: bulkInsert
+    args: { dataset: users, records: "$request.body", assignId: { strategy: sequence, prefix: "u_" } }
+    out: result
+  - op: respond
+    args: { status: 200, bodyFrom: "$ctx.result" }
+`)
+	store := NewMemoryStore(nil)
+	exec := NewExecutor(repo, WithStore(store))
+
+	res, err := exec.Run(context.Background(), "next.flow.yaml", &ExecRequest{Method: "POST"})
+	require.NoError(t, err)
+	require.Equal(t, "u_8", res.Body)
+
+	res, err = exec.Run(context.Background(), "bulk.flow.yaml", &ExecRequest{Method: "POST", Body: []any{
+		map[string]any{"name": "Cid"},
+		map[string]any{"id": "u_1", "name": "Dup"},
+	}})
+	require.NoError(t, err)
+	results := res.Body.(map[string]any)["results"].([]any)
+	require.Equal(t, "u_9", results[0].(map[string]any)["id"])
+	require.Equal(t, float64(409), results[1].(map[string]any)["status"], "seeded ids are taken")
+	saved, _, _ := store.Load("users")
+	require.Len(t, saved, 3, "the seed is kept alongside the inserted record")
+}
+
+func TestSequencesRestartAfterStateReset(t *testing.T) {
+	repo := t.TempDir()
+	writeTestSeed(t, repo, "users", `[{"id": "u_1", "name": "Alice"}]`)
+	writeTestFlow(t, repo, "create.flow.yaml", `
+steps:
+  - op: assignId
+    args: { strategy: sequence, dataset: users, prefix: "u_" }
+    out: id
+  - op: insertRecord
+    args: { dataset: users, record: { id: "$ctx.id" } }
+  - op: respond
+    args: { status: 200, bodyFrom: "$ctx.id" }
+`)
+	clock := NewManualClock(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	exec := NewExecutor(repo, WithStore(NewMemoryStore(nil)), WithClock(clock), WithIDs(NewSeededIDGenerator(clock, 1)))
+	create := func() any {
+		res, err := exec.Run(context.Background(), "create.flow.yaml", &ExecRequest{Method: "POST"})
+		require.NoError(t, err)
+		return res.Body
+	}
+
+	_, err := exec.SaveSnapshot("empty")
+	require.NoError(t, err)
+	require.Equal(t, "u_2", create())
+	require.Equal(t, "u_3", create())
+
+	_, err = exec.ResetDatasets()
+	require.NoError(t, err)
+	require.Equal(t, "u_2", create(), "a reset dataset continues from its seed")
+
+	_, err = exec.RestoreSnapshot("empty")
+	require.NoError(t, err)
+	require.Equal(t, "u_2", create(), "a restored dataset continues from the snapshot")
+}
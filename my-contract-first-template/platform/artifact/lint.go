@@ -0,0 +1,281 @@
+package artifact
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Lint severities.
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+// LintIssue is one problem found in a contract repo. File is relative to the
+// repo root.
+type LintIssue struct {
+	Severity string `json:"severity"`
+	File     string `json:"file"`
+	Where    string `json:"where,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (i LintIssue) String() string {
+	loc := i.File
+	if i.Where != "" {
+		loc += " " + i.Where
+	}
+	return fmt.Sprintf("%s: %s: %s", i.Severity, loc, i.Message)
+}
+
+// flowOps are the step ops Executor.run understands.
+var flowOps = map[string]bool{
+	"loadDataset": true, "filterAndPaginate": true, "findById": true, "validateBody": true,
+	"checkUnique": true, "assignId": true, "insertRecord": true, "updateRecord": true,
+	"deleteRecord": true, "now": true, "set": true, "emit": true, "log": true, "respond": true,
+	"history": true, "restoreRecord": true, "bulkInsert": true, "bulkUpdate": true, "bulkDelete": true,
+}
+
+// LintRepo checks a contract repo without running it: the registry, every
+// flow it references, schedules, fault rules, seeds, migrations and flow
+// tests. Issues are sorted by file.
+func LintRepo(repoPath string) []LintIssue {
+	l := &linter{repo: repoPath, issues: []LintIssue{}}
+	l.registry()
+	l.seeds()
+	if _, err := LoadMigrations(repoPath); err != nil {
+		l.add(LintError, "migrations", "", err.Error())
+	}
+	if _, err := LoadFlowTests(repoPath); err != nil {
+		l.add(LintError, "flows", "", err.Error())
+	}
+	sort.SliceStable(l.issues, func(i, j int) bool { return l.issues[i].File < l.issues[j].File })
+	return l.issues
+}
+
+type linter struct {
+	repo   string
+	issues []LintIssue
+	flows  map[string]bool
+}
+
+func (l *linter) add(severity, file, where, msg string) {
+	l.issues = append(l.issues, LintIssue{Severity: severity, File: file, Where: where, Message: msg})
+}
+
+func (l *linter) registry() {
+	const file = "api/index.json"
+	reg, err := LoadRegistry(filepath.Join(l.repo, file))
+	if err != nil {
+		l.add(LintError, file, "", err.Error())
+		return
+	}
+	l.flows = map[string]bool{}
+	versions := map[string]bool{}
+	for i, v := range reg.Versions {
+		switch {
+		case v.Name == "":
+			l.add(LintError, file, fmt.Sprintf("versions[%d]", i), "name is required")
+		case versions[v.Name]:
+			l.add(LintError, file, "version "+v.Name, "version is declared twice")
+		}
+		versions[v.Name] = true
+	}
+	if reg.DefaultVersion != "" && !versions[reg.DefaultVersion] {
+		l.add(LintError, file, "defaultVersion", fmt.Sprintf("version %s is not declared in versions", reg.DefaultVersion))
+	}
+	if reg.Policy != nil {
+		l.policy(file, "", *reg.Policy)
+	}
+	for _, name := range sortedKeys(reg.Datasets) {
+		if err := validStateName("dataset", name); err != nil {
+			l.add(LintError, file, "datasets", err.Error())
+		} else if strings.HasSuffix(name, HistorySuffix) {
+			l.add(LintError, file, "datasets", fmt.Sprintf("dataset %s: %s names are reserved for history", name, HistorySuffix))
+		}
+		if schema := reg.Datasets[name].Schema; schema != nil {
+			if _, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(schema)); err != nil {
+				l.add(LintError, file, "datasets", fmt.Sprintf("dataset %s schema: %v", name, err))
+			}
+		}
+	}
+	ids := map[string]bool{}
+	for _, ep := range reg.Endpoints {
+		ids[ep.ID] = true
+	}
+	seen := map[string]bool{}
+	routes := map[string]string{}
+	for i, ep := range reg.Endpoints {
+		where := fmt.Sprintf("endpoints[%d]", i)
+		if ep.ID != "" {
+			where = "endpoint " + ep.ID
+		}
+		if ep.ID == "" {
+			l.add(LintError, file, where, "id is required")
+		} else if seen[ep.ID] {
+			l.add(LintError, file, where, "id is used twice")
+		}
+		seen[ep.ID] = true
+		l.lifecycle(file, where, ep, versions, ids)
+		if ep.Policy != nil {
+			l.policy(file, where, *ep.Policy)
+		}
+		if ep.Faults != nil {
+			if err := ep.Faults.Validate(); err != nil {
+				l.add(LintError, file, where, "faults: "+err.Error())
+			}
+		}
+
+		switch ep.Type {
+		case "":
+			if ep.Method == "" || ep.Path == "" {
+				l.add(LintError, file, where, "method and path are required")
+			}
+			route := strings.ToUpper(ep.Method) + " " + ep.Path
+			if ep.Version != "" {
+				route += " (version " + ep.Version + ")"
+			}
+			if other, ok := routes[route]; ok {
+				l.add(LintError, file, where, fmt.Sprintf("%s is already served by %s", route, other))
+			}
+			routes[route] = ep.ID
+			l.flow(file, where, ep.Flow, true)
+		case EndpointStream:
+			if ep.Path == "" {
+				l.add(LintError, file, where, "path is required")
+			}
+		case EndpointEvent:
+			if ep.Topic == "" {
+				l.add(LintError, file, where, "topic is required")
+			}
+			l.flow(file, where, ep.Flow, false)
+		default:
+			l.add(LintError, file, where, fmt.Sprintf("unknown type %q", ep.Type))
+		}
+	}
+	if _, err := NewScheduler(NewExecutor(l.repo), reg.Schedules, SchedulerOptions{}); err != nil {
+		l.add(LintError, file, "schedules", err.Error())
+	}
+	for _, sd := range reg.Schedules {
+		l.flow(file, "schedule "+sd.ID, sd.Flow, false)
+	}
+	l.unreferencedFlows()
+}
+
+// lifecycle checks the version and retirement metadata of an endpoint.
+func (l *linter) lifecycle(file, where string, ep EndpointDef, versions, ids map[string]bool) {
+	if ep.Version != "" && !versions[ep.Version] {
+		l.add(LintError, file, where, fmt.Sprintf("version %s is not declared in versions", ep.Version))
+	}
+	if ep.Sunset != nil {
+		if ep.Deprecated != nil && ep.Sunset.Before(*ep.Deprecated) {
+			l.add(LintWarning, file, where, "sunset is before the deprecation date")
+		}
+		if time.Now().After(*ep.Sunset) {
+			l.add(LintWarning, file, where, "sunset has passed; remove the endpoint or move the date")
+		}
+	}
+	if r := ep.Replacement; r != "" && r == ep.ID {
+		l.add(LintError, file, where, "replacement points at the endpoint itself")
+	} else if r != "" && !strings.Contains(r, "/") && !ids[r] {
+		l.add(LintWarning, file, where, fmt.Sprintf("replacement %s is neither an endpoint id nor a URL", r))
+	}
+}
+
+// policy checks a CORS and security header policy.
+func (l *linter) policy(file, where string, p HTTPPolicy) {
+	if err := p.Validate(); err != nil {
+		l.add(LintError, file, where, "policy: "+err.Error())
+	}
+}
+
+// flow checks a flow referenced from the registry, once per file. Flows
+// behind HTTP endpoints are expected to respond explicitly.
+func (l *linter) flow(file, where, name string, http bool) {
+	if name == "" {
+		l.add(LintError, file, where, "flow is required")
+		return
+	}
+	if l.flows[name] {
+		return
+	}
+	l.flows[name] = true
+	flow, err := LoadFlow(l.repo, name)
+	if err != nil {
+		l.add(LintError, file, where, err.Error())
+		return
+	}
+	flowFile := filepath.ToSlash(filepath.Join("flows", name))
+	ids := map[string]bool{}
+	responds := false
+	for i, step := range flow.Steps {
+		stepWhere := fmt.Sprintf("steps[%d]", i)
+		if step.ID != "" {
+			stepWhere = "step " + step.ID
+			if ids[step.ID] {
+				l.add(LintError, flowFile, stepWhere, "step id is used twice")
+			}
+			ids[step.ID] = true
+		}
+		if !flowOps[step.Op] {
+			l.add(LintError, flowFile, stepWhere, fmt.Sprintf("unknown op %q", step.Op))
+		}
+		if step.OnConflict != nil && step.OnConflict.Op != "respond" {
+			l.add(LintError, flowFile, stepWhere, "onConflict only supports the respond op")
+		}
+		if step.Op == "respond" {
+			responds = true
+		}
+		if step.Op == "assignId" {
+			switch strategy := str(step.Args["strategy"]); strategy {
+			case "", IDTimestamp, IDUUID4, IDUUID7, IDULID:
+			case IDSequence:
+				if str(step.Args["dataset"]) == "" {
+					l.add(LintError, flowFile, stepWhere, "assignId with strategy sequence requires dataset")
+				}
+			default:
+				l.add(LintError, flowFile, stepWhere, fmt.Sprintf("unknown id strategy %q", strategy))
+			}
+		}
+		if mode := str(step.Args["mode"]); (step.Op == "updateRecord" || step.Op == "bulkUpdate") && !strings.HasPrefix(mode, "$") {
+			if _, err := patchMode(mode); err != nil {
+				l.add(LintError, flowFile, stepWhere, err.Error())
+			}
+		}
+	}
+	if http && !responds {
+		l.add(LintWarning, flowFile, "", "flow has no respond step and always answers 204")
+	}
+}
+
+func (l *linter) unreferencedFlows() {
+	matches, _ := filepath.Glob(filepath.Join(l.repo, "flows", "*.flow.*"))
+	for _, m := range matches {
+		if name := filepath.Base(m); !l.flows[name] {
+			l.add(LintWarning, "flows/"+name, "", "flow is not referenced by any endpoint or schedule")
+		}
+	}
+}
+
+func (l *linter) seeds() {
+	matches, _ := filepath.Glob(filepath.Join(l.repo, "data", "seed.*.json"))
+	for _, m := range matches {
+		file := "data/" + filepath.Base(m)
+		b, err := os.ReadFile(m)
+		if err != nil {
+			l.add(LintError, file, "", err.Error())
+			continue
+		}
+		var records []any
+		if err := json.Unmarshal(b, &records); err != nil {
+			l.add(LintError, file, "", "seed must be a JSON array: "+err.Error())
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package artifact
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintRepoReportsProblems(t *testing.T) {
+	repo := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(repo, "api"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "api", "index.json"), []byte(`{
+  "endpoints": [
+    { "id": "a", "method": "GET", "path": "/a", "flow": "a.flow.yaml" },
+    { "id": "a", "method": "GET", "path": "/a", "flow": "missing.flow.yaml" },
+    { "id": "b", "method": "POST", "path": "/b", "flow": "b.flow.yaml", "faults": { "errorPercent": 120 } },
+    { "id": "c", "type": "event", "flow": "a.flow.yaml" },
+    { "id": "d", "type": "webhook" },
+    { "id": "e", "method": "GET", "path": "/a", "flow": "a.flow.yaml", "version": "v1",
+      "deprecated": "2030-01-01T00:00:00Z", "sunset": "2029-01-01T00:00:00Z", "replacement": "a2" },
+    { "id": "f", "method": "GET", "path": "/f", "flow": "a.flow.yaml", "version": "v9",
+      "policy": { "cors": { "allowOrigins": ["*"], "allowCredentials": true } } }
+  ],
+  "policy": { "security": { "referrerPolicy": "nope" } },
+  "datasets": { "users": { "history": true }, "users.history": { "softDelete": true }, "orders": { "schema": { "type": "record" } } },
+  "versions": [{ "name": "v1" }],
+  "schedules": [{ "id": "s", "cron": "61 * * * *", "flow": "a.flow.yaml" }]
+}`), 0o644))
+	writeTestFlow(t, repo, "a.flow.yaml", `
+steps:
+  - id: x
+    op: respond
+    args: { status: 200 }
+`)
+	writeTestFlow(t, repo, "b.flow.yaml", `
+steps:
+  - id: x
+    op: loadDatset
+  - id: x
+    op: set
+    onConflict: { op: log }
+  - op: assignId
+    args: { strategy: snowflake }
+  - op: updateRecord
+    args: { dataset: users, id: u1, mode: diff }
+`)
+	writeTestFlow(t, repo, "orphan.flow.yaml", `
+steps:
+  - op: respond
+`)
+	require.NoError(t, os.MkdirAll(filepath.Join(repo, "data"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "data", "seed.users.json"), []byte(`{"not": "a list"}`), 0o644))
+
+	// Messages that embed OS or decoder errors are matched by prefix.
+	want := []string{
+		"error: api/index.json endpoint a: id is used twice",
+		"error: api/index.json endpoint a: GET /a is already served by a",
+		"error: api/index.json endpoint a: read flow missing.flow.yaml: ",
+		"error: api/index.json endpoint b: faults: errorPercent must be between 0 and 100",
+		"error: api/index.json endpoint c: topic is required",
+		`error: api/index.json endpoint d: unknown type "webhook"`,
+		"warning: api/index.json endpoint e: sunset is before the deprecation date",
+		"warning: api/index.json endpoint e: replacement a2 is neither an endpoint id nor a URL",
+		"error: api/index.json endpoint f: version v9 is not declared in versions",
+		`error: api/index.json endpoint f: policy: cors: allowCredentials needs explicit origins, not "*"`,
+		`error: api/index.json: policy: security: unknown referrerPolicy "nope"`,
+		"error: api/index.json datasets: dataset orders schema: ",
+		"error: api/index.json datasets: dataset users.history: .history names are reserved for history",
+		`error: api/index.json schedules: schedule s: cron "61 * * * *": "61" out of range 0-59`,
+		`error: flows/b.flow.yaml step x: unknown op "loadDatset"`,
+		"error: flows/b.flow.yaml step x: step id is used twice",
+		"error: flows/b.flow.yaml step x: onConflict only supports the respond op",
+		`error: flows/b.flow.yaml steps[2]: unknown id strategy "snowflake"`,
+		`error: flows/b.flow.yaml steps[3]: unknown updateRecord mode "diff"`,
+		"warning: flows/b.flow.yaml: flow has no respond step and always answers 204",
+		"warning: flows/orphan.flow.yaml: flow is not referenced by any endpoint or schedule",
+		"error: data/seed.users.json: seed must be a JSON array: ",
+	}
+	issues := LintRepo(repo)
+	require.Len(t, issues, len(want))
+	for _, w := range want {
+		found := false
+		for _, i := range issues {
+			found = found || strings.HasPrefix(i.String(), w)
+		}
+		require.True(t, found, "missing issue %q in %v", w, issues)
+	}
+}
+
+func TestLintRepoAcceptsTemplateRepo(t *testing.T) {
+	require.Empty(t, LintRepo(filepath.Join("..", "..", "repo")))
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -36,3 +38,86 @@ func LoadFlow(repoPath, flowFile string) (*Flow, error) {
 	}
 	return nil, fmt.Errorf("parse flow failed: %s", flowFile)
 }
+
+// flowCache keeps parsed flows per executor and reparses a file only when its
+// modification time or size changes, so edits are still picked up live. Every
+// load hands out a copy: ops such as set put literal arg maps into the run's
+// context, where later steps write into them.
+type flowCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedFlow
+}
+
+type cachedFlow struct {
+	modTime time.Time
+	size    int64
+	flow    *Flow
+}
+
+func newFlowCache() *flowCache {
+	return &flowCache{entries: map[string]cachedFlow{}}
+}
+
+func (c *flowCache) load(repoPath, flowFile string) (*Flow, error) {
+	info, err := os.Stat(filepath.Join(repoPath, "flows", flowFile))
+	if err != nil {
+		return nil, fmt.Errorf("read flow %s: %w", flowFile, err)
+	}
+	c.mu.Lock()
+	cached, ok := c.entries[flowFile]
+	c.mu.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cloneFlow(cached.flow), nil
+	}
+
+	f, err := LoadFlow(repoPath, flowFile)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.entries[flowFile] = cachedFlow{modTime: info.ModTime(), size: info.Size(), flow: f}
+	c.mu.Unlock()
+	return cloneFlow(f), nil
+}
+
+// cloneFlow copies f down to the values of its step args.
+func cloneFlow(f *Flow) *Flow {
+	out := *f
+	out.Steps = make([]FlowStep, len(f.Steps))
+	for i, step := range f.Steps {
+		step.Args, _ = cloneValue(step.Args).(map[string]any)
+		if step.OnConflict != nil {
+			action := *step.OnConflict
+			action.Args, _ = cloneValue(action.Args).(map[string]any)
+			step.OnConflict = &action
+		}
+		out.Steps[i] = step
+	}
+	return &out
+}
+
+// cloneValue deep-copies the maps and slices of a decoded value and keeps
+// its scalars as they are, unlike the JSON round trip of deepCopy.
+func cloneValue(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		if t == nil {
+			return t
+		}
+		out := make(map[string]any, len(t))
+		for k, e := range t {
+			out[k] = cloneValue(e)
+		}
+		return out
+	case []any:
+		if t == nil {
+			return t
+		}
+		out := make([]any, len(t))
+		for i, e := range t {
+			out[i] = cloneValue(e)
+		}
+		return out
+	}
+	return v
+}
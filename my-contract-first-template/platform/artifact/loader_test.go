@@ -0,0 +1,71 @@
+package artifact
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlowCacheReloadsChangedFiles(t *testing.T) {
+	repo := t.TempDir()
+	writeTestFlow(t, repo, "a.flow.yaml", `
+steps:
+  - op: respond
+    args: { status: 200 }
+`)
+	cache := newFlowCache()
+	first, err := cache.load(repo, "a.flow.yaml")
+	require.NoError(t, err)
+	again, err := cache.load(repo, "a.flow.yaml")
+	require.NoError(t, err)
+	require.Equal(t, first, again)
+
+	writeTestFlow(t, repo, "a.flow.yaml", `
+name: changed
+steps:
+  - op: respond
+    args: { status: 201 }
+`)
+	changed, err := cache.load(repo, "a.flow.yaml")
+	require.NoError(t, err)
+	require.Equal(t, 201, changed.Steps[0].Args["status"])
+
+	require.NoError(t, os.Remove(filepath.Join(repo, "flows", "a.flow.yaml")))
+	_, err = cache.load(repo, "a.flow.yaml")
+	require.Error(t, err)
+}
+
+func TestCachedFlowArgsAreNotSharedBetweenRuns(t *testing.T) {
+	repo := t.TempDir()
+	writeTestFlow(t, repo, "set.flow.yaml", `
+steps:
+  - op: set
+    args: { path: "$ctx.obj", value: { a: 1 } }
+  - op: set
+    args: { path: "$ctx.obj.b", value: "$request.params.id" }
+  - op: respond
+    args: { status: 200, bodyFrom: "$ctx.obj" }
+`)
+	exec := NewExecutor(repo, WithStore(NewMemoryStore(nil)))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			res, err := exec.Run(context.Background(), "set.flow.yaml", &ExecRequest{Method: "GET", Params: map[string]string{"id": id}})
+			require.NoError(t, err)
+			require.Equal(t, map[string]any{"a": float64(1), "b": id}, res.Body)
+		}(fmt.Sprint(i))
+	}
+	wg.Wait()
+
+	flow, err := exec.flows.load(repo, "set.flow.yaml")
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"a": 1}, flow.Steps[0].Args["value"], "runs never write into the cached flow")
+}
@@ -0,0 +1,225 @@
+package artifact
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the correlation ID of a request in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+const requestIDKey = "artifact.requestId"
+
+const redactedValue = "[REDACTED]"
+
+// NewLogger returns a JSON slog logger writing to w at the named level.
+func NewLogger(w io.Writer, level string) (*slog.Logger, error) {
+	lvl, err := ParseLogLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: lvl})), nil
+}
+
+// ParseLogLevel maps debug, info, warn and error to slog levels. An empty
+// string means info.
+func ParseLogLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("unknown log level: %s", level)
+	}
+}
+
+// Redactor masks sensitive header values and body fields before they reach
+// the logs. Names are matched case-insensitively.
+type Redactor struct {
+	headers map[string]bool
+	fields  map[string]bool
+}
+
+// NewRedactor creates a Redactor for the given header and body field names.
+func NewRedactor(headers, fields []string) *Redactor {
+	r := &Redactor{headers: map[string]bool{}, fields: map[string]bool{}}
+	for _, h := range headers {
+		if h = strings.TrimSpace(h); h != "" {
+			r.headers[strings.ToLower(h)] = true
+		}
+	}
+	for _, f := range fields {
+		if f = strings.TrimSpace(f); f != "" {
+			r.fields[strings.ToLower(f)] = true
+		}
+	}
+	return r
+}
+
+// DefaultRedactor masks credentials commonly found in mock traffic.
+func DefaultRedactor() *Redactor {
+	return NewRedactor(
+		[]string{"Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "X-Artifact-Signature"},
+		[]string{"password", "token", "secret"},
+	)
+}
+
+// Headers returns a copy of h with redacted values masked.
+func (r *Redactor) Headers(h map[string][]string) map[string]any {
+	out := make(map[string]any, len(h))
+	for k, v := range h {
+		if r != nil && r.headers[strings.ToLower(k)] {
+			out[k] = redactedValue
+			continue
+		}
+		if len(v) == 1 {
+			out[k] = v[0]
+		} else {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// Value returns a deep copy of v with redacted object fields masked at any
+// depth.
+func (r *Redactor) Value(v any) any {
+	return r.mask(v, false)
+}
+
+// Trace is Value for debug traces: resolved step args can hold
+// $request.headers, so keys named after redacted headers are masked too.
+func (r *Redactor) Trace(v any) any {
+	return r.mask(v, true)
+}
+
+func (r *Redactor) mask(v any, headers bool) any {
+	switch t := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, item := range t {
+			if r != nil && (r.fields[strings.ToLower(k)] || headers && r.headers[strings.ToLower(k)]) {
+				out[k] = redactedValue
+				continue
+			}
+			out[k] = r.mask(item, headers)
+		}
+		return out
+	case []any:
+		out := make([]any, len(t))
+		for i, item := range t {
+			out[i] = r.mask(item, headers)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// request returns a copy of req fit for writing to disk: redacted headers,
+// query parameters and body fields are masked.
+func (r *Redactor) request(req *ExecRequest) *ExecRequest {
+	var out ExecRequest
+	if b, err := json.Marshal(req); err == nil {
+		_ = json.Unmarshal(b, &out)
+	}
+	for k := range out.Headers {
+		if r != nil && r.headers[strings.ToLower(k)] {
+			out.Headers[k] = []string{redactedValue}
+		}
+	}
+	for k := range out.Query {
+		if r != nil && r.fields[strings.ToLower(k)] {
+			out.Query[k] = []string{redactedValue}
+		}
+	}
+	out.Body = r.Value(out.Body)
+	return &out
+}
+
+// RequestID assigns every request an ID, reusing a caller-supplied
+// X-Request-ID, and echoes it on the response.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := strings.TrimSpace(c.GetHeader(RequestIDHeader))
+		if id == "" || len(id) > 128 {
+			id = newRequestID()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// RequestIDFrom returns the ID assigned by the RequestID middleware.
+func RequestIDFrom(c *gin.Context) string { return c.GetString(requestIDKey) }
+
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Sprintf("req_%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// AccessLog writes one structured line per request.
+func AccessLog(logger *slog.Logger, redactor *Redactor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		} else if status >= 400 {
+			level = slog.LevelWarn
+		}
+		logger.LogAttrs(c.Request.Context(), level, "request",
+			slog.String("request_id", RequestIDFrom(c)),
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Any("headers", redactor.Headers(c.Request.Header)),
+		)
+	}
+}
+
+func opLog(ctx context.Context, logger *slog.Logger, redactor *Redactor, args map[string]any, rt map[string]any) error {
+	msg := str(getExpr(rt, args["message"], ""))
+	if msg == "" {
+		return errors.New("log requires message")
+	}
+	level, err := ParseLogLevel(str(args["level"]))
+	if err != nil {
+		return err
+	}
+
+	attrs := []any{}
+	if fields, ok := args["fields"].(map[string]any); ok {
+		resolved, _ := redactor.Value(resolveExpr(rt, fields)).(map[string]any)
+		for k, v := range resolved {
+			attrs = append(attrs, slog.Any(k, v))
+		}
+	}
+	logger.Log(ctx, level, msg, slog.Group("fields", attrs...))
+	return nil
+}
@@ -0,0 +1,92 @@
+package artifact
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestIDReusesOrGenerates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestID())
+	r.GET("/", func(c *gin.Context) {
+		req, err := NewExecRequestFromGin(c, RequestOptions{})
+		require.NoError(t, err)
+		c.String(200, req.RequestID)
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	r.ServeHTTP(w, req)
+	require.Equal(t, "abc-123", w.Body.String())
+	require.Equal(t, "abc-123", w.Header().Get(RequestIDHeader))
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	require.Len(t, w.Body.String(), 32)
+	require.Equal(t, w.Body.String(), w.Header().Get(RequestIDHeader))
+}
+
+func TestRedactorMasksHeadersAndNestedFields(t *testing.T) {
+	r := NewRedactor([]string{"authorization"}, []string{"Password"})
+
+	headers := r.Headers(map[string][]string{"Authorization": {"Bearer x"}, "Accept": {"a", "b"}})
+	require.Equal(t, redactedValue, headers["Authorization"])
+	require.Equal(t, []string{"a", "b"}, headers["Accept"])
+
+	body := r.Value(map[string]any{
+		"user":  map[string]any{"password": "hunter2", "name": "bob"},
+		"items": []any{map[string]any{"PASSWORD": "x"}},
+	}).(map[string]any)
+	require.Equal(t, redactedValue, body["user"].(map[string]any)["password"])
+	require.Equal(t, "bob", body["user"].(map[string]any)["name"])
+	require.Equal(t, redactedValue, body["items"].([]any)[0].(map[string]any)["PASSWORD"])
+}
+
+func TestLogOpEmitsStructuredEventWithRequestID(t *testing.T) {
+	repo := t.TempDir()
+	writeTestFlow(t, repo, "audit.flow.yaml", `
+version: 1
+steps:
+  - id: audit
+    op: log
+    args:
+      level: warn
+      message: user signed up
+      fields:
+        email: "$request.body.email"
+        password: "$request.body.password"
+  - op: respond
+    args:
+      status: 204
+`)
+
+	var buf bytes.Buffer
+	logger, err := NewLogger(&buf, "info")
+	require.NoError(t, err)
+
+	_, err = NewExecutor(repo, WithLogger(logger)).Run(context.Background(), "audit.flow.yaml", &ExecRequest{
+		RequestID: "req-1",
+		Body:      map[string]any{"email": "a@example.com", "password": "hunter2"},
+	})
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 1, "debug step logs must be filtered at info level")
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	require.Equal(t, "WARN", entry["level"])
+	require.Equal(t, "user signed up", entry["msg"])
+	require.Equal(t, "req-1", entry["request_id"])
+	require.Equal(t, "audit", entry["step"])
+	require.Equal(t, map[string]any{"email": "a@example.com", "password": redactedValue}, entry["fields"])
+}
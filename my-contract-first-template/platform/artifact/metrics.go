@@ -0,0 +1,82 @@
+package artifact
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds the Prometheus collectors of the gateway. A nil *Metrics is
+// valid and records nothing.
+type Metrics struct {
+	requests    *prometheus.CounterVec
+	latency     *prometheus.HistogramVec
+	stepErrors  *prometheus.CounterVec
+	datasetRows *prometheus.GaugeVec
+}
+
+// NewMetrics creates the gateway collectors and registers them with reg.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "artifact_requests_total",
+			Help: "Flow requests handled, by endpoint ID and response status.",
+		}, []string{"endpoint", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "artifact_request_duration_seconds",
+			Help:    "Flow request latency, by endpoint ID and response status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"endpoint", "status"}),
+		stepErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "artifact_step_errors_total",
+			Help: "Flow steps that failed, by op.",
+		}, []string{"op"}),
+		datasetRows: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "artifact_dataset_records",
+			Help: "Records in a dataset as last loaded or written.",
+		}, []string{"dataset"}),
+	}
+	reg.MustRegister(m.requests, m.latency, m.stepErrors, m.datasetRows)
+	return m
+}
+
+// Middleware records request count and latency for the endpoint it wraps.
+func (m *Metrics) Middleware(endpointID string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		m.ObserveRequest(endpointID, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// ObserveRequest records one handled request.
+func (m *Metrics) ObserveRequest(endpointID string, status int, d time.Duration) {
+	if m == nil {
+		return
+	}
+	code := strconv.Itoa(status)
+	m.requests.WithLabelValues(endpointID, code).Inc()
+	m.latency.WithLabelValues(endpointID, code).Observe(d.Seconds())
+}
+
+func (m *Metrics) stepError(op string) {
+	if m == nil {
+		return
+	}
+	m.stepErrors.WithLabelValues(op).Inc()
+}
+
+func (m *Metrics) datasetSize(dataset string, n int) {
+	if m == nil {
+		return
+	}
+	m.datasetRows.WithLabelValues(dataset).Set(float64(n))
+}
+
+func (m *Metrics) datasetValue(dataset string, v any) {
+	if arr, ok := toSlice(v); ok {
+		m.datasetSize(dataset, len(arr))
+	}
+}
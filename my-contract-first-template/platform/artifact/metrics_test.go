@@ -0,0 +1,34 @@
+package artifact
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutorReportsStepErrorsAndDatasetSize(t *testing.T) {
+	repo := t.TempDir()
+	writeTestFlow(t, repo, "insert.flow.yaml", `
+version: 1
+steps:
+  - op: insertRecord
+    args:
+      dataset: widgets
+      record: "$request.body"
+  - op: insertRecord
+    args:
+      dataset: widgets
+`)
+
+	m := NewMetrics(prometheus.NewRegistry())
+	_, err := NewExecutor(repo, WithMetrics(m)).Run(context.Background(), "insert.flow.yaml", &ExecRequest{
+		Body: map[string]any{"id": "w1"},
+	})
+	require.Error(t, err)
+
+	require.Equal(t, float64(1), testutil.ToFloat64(m.datasetRows.WithLabelValues("widgets")))
+	require.Equal(t, float64(1), testutil.ToFloat64(m.stepErrors.WithLabelValues("insertRecord")))
+}
@@ -0,0 +1,52 @@
+package artifact
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TokenAuth guards a route group with a static bearer token. An empty token
+// disables the check so local setups keep working without configuration.
+func TokenAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.Next()
+			return
+		}
+		got := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// TraceHandlers exposes a TraceBuffer as list and lookup endpoints.
+func TraceHandlers(buf *TraceBuffer) (list gin.HandlerFunc, get gin.HandlerFunc) {
+	list = func(c *gin.Context) {
+		c.JSON(http.StatusOK, map[string]any{"traces": buf.List()})
+	}
+	get = func(c *gin.Context) {
+		t, ok := buf.Get(c.Param("id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, map[string]string{"error": "trace not found"})
+			return
+		}
+		c.JSON(http.StatusOK, t)
+	}
+	return list, get
+}
+
+// DebugRequested reports whether the request asked for a step trace.
+func DebugRequested(c *gin.Context) bool {
+	switch strings.ToLower(strings.TrimSpace(c.GetHeader(DebugHeader))) {
+	case "1", "true", "yes", "on":
+		return true
+	default:
+		return false
+	}
+}
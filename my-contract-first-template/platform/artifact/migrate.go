@@ -0,0 +1,416 @@
+package artifact
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Migrations live in repo/migrations/<dataset>/<NNN>-<name>.yaml and upgrade
+// the saved records of a dataset one version at a time, the way
+// db/migrations does for Postgres. Seeds are expected to already have the
+// latest shape; only state persisted under an older shape is rewritten.
+
+var migrationFilePattern = regexp.MustCompile(`^(\d+)-([A-Za-z0-9._-]+)\.ya?ml$`)
+
+// Migration is one versioned transform of a dataset's records.
+type Migration struct {
+	Dataset     string          `yaml:"-" json:"dataset"`
+	Version     int             `yaml:"-" json:"version"`
+	Name        string          `yaml:"-" json:"name"`
+	Description string          `yaml:"description" json:"description,omitempty"`
+	Steps       []TransformStep `yaml:"steps" json:"steps"`
+}
+
+// TransformStep rewrites each record. Supported ops are rename (from, to),
+// set (field, value), default (field, value), remove (field), split (field,
+// into, separator), join (fields, into, separator) and convert (field, to).
+// Values may reference the record as "$record.<field>".
+type TransformStep struct {
+	Op   string         `yaml:"op" json:"op"`
+	Args map[string]any `yaml:"args" json:"args"`
+	When string         `yaml:"when,omitempty" json:"when,omitempty"`
+}
+
+// MigrationState is the applied version of each dataset, stored in
+// .runtime/migrations.json.
+type MigrationState map[string]*DatasetVersion
+
+// DatasetVersion records the migrations applied to one dataset.
+type DatasetVersion struct {
+	Version   int                `json:"version"`
+	UpdatedAt time.Time          `json:"updatedAt"`
+	Applied   []AppliedMigration `json:"applied,omitempty"`
+}
+
+// AppliedMigration is one entry of a dataset's migration history.
+type AppliedMigration struct {
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"appliedAt"`
+}
+
+// MigrationResult describes what Migrate did, or would do, to one dataset.
+type MigrationResult struct {
+	Dataset string         `json:"dataset"`
+	From    int            `json:"from"`
+	To      int            `json:"to"`
+	Applied []string       `json:"applied"`
+	Records int            `json:"records"`
+	Changes []RecordChange `json:"changes,omitempty"`
+}
+
+// RecordChange shows how one record is rewritten by a dry run.
+type RecordChange struct {
+	Index       int          `json:"index"`
+	ID          string       `json:"id,omitempty"`
+	Differences []Difference `json:"differences"`
+}
+
+// LoadMigrations reads repo/migrations, grouped by dataset in version order.
+func LoadMigrations(repoPath string) (map[string][]*Migration, error) {
+	root := filepath.Join(repoPath, "migrations")
+	dirs, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return map[string][]*Migration{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	out := map[string][]*Migration{}
+	for _, d := range dirs {
+		if !d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(root, d.Name()))
+		if err != nil {
+			return nil, err
+		}
+		seen := map[int]string{}
+		for _, f := range files {
+			m := migrationFilePattern.FindStringSubmatch(f.Name())
+			if f.IsDir() || m == nil {
+				continue
+			}
+			version, _ := strconv.Atoi(m[1])
+			if prev, dup := seen[version]; dup {
+				return nil, fmt.Errorf("migrations/%s: version %d defined by %s and %s", d.Name(), version, prev, f.Name())
+			}
+			seen[version] = f.Name()
+
+			b, err := os.ReadFile(filepath.Join(root, d.Name(), f.Name()))
+			if err != nil {
+				return nil, err
+			}
+			mig := &Migration{}
+			if err := yaml.Unmarshal(b, mig); err != nil {
+				return nil, fmt.Errorf("parse migrations/%s/%s: %w", d.Name(), f.Name(), err)
+			}
+			mig.Dataset = d.Name()
+			mig.Version = version
+			mig.Name = strings.TrimSuffix(strings.TrimSuffix(f.Name(), ".yaml"), ".yml")
+			for i, st := range mig.Steps {
+				if _, ok := transformOps[st.Op]; !ok {
+					return nil, fmt.Errorf("migrations/%s/%s: step %d: unknown op %q", d.Name(), f.Name(), i+1, st.Op)
+				}
+			}
+			out[d.Name()] = append(out[d.Name()], mig)
+		}
+		sort.Slice(out[d.Name()], func(i, j int) bool { return out[d.Name()][i].Version < out[d.Name()][j].Version })
+	}
+	return out, nil
+}
+
+func (e *Executor) migrationStatePath() string {
+	return filepath.Join(e.repoPath, ".runtime", "migrations.json")
+}
+
+// MigrationStatus returns the recorded version of every migrated dataset.
+func (e *Executor) MigrationStatus() (MigrationState, error) {
+	state := MigrationState{}
+	b, err := os.ReadFile(e.migrationStatePath())
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &state); err != nil {
+		return nil, fmt.Errorf("parse migration state: %w", err)
+	}
+	return state, nil
+}
+
+// Migrate applies pending migrations in version order. Saved state without a
+// recorded version is treated as version 1, the shape of the v1 seeds. With
+// dryRun nothing is written and each result lists the record changes.
+func (e *Executor) Migrate(dryRun bool) ([]MigrationResult, error) {
+	migrations, err := LoadMigrations(e.repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	e.stateMu.Lock()
+	defer e.stateMu.Unlock()
+
+	state, err := e.MigrationStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	// Every migration runs in memory first, so a failing one leaves all
+	// datasets untouched. Each migrated dataset is then saved and recorded
+	// before the next, so a failed write never leaves a migrated dataset
+	// without its version.
+	type pending struct {
+		dataset  string
+		migrated []any
+		applied  []AppliedMigration
+	}
+	results := []MigrationResult{}
+	writes := []pending{}
+	changed := false
+	for _, ds := range sortedKeys(migrations) {
+		list := migrations[ds]
+		latest := list[len(list)-1].Version
+		records, saved, err := e.store.Load(ds)
+		if err != nil {
+			return nil, fmt.Errorf("load %s: %w", ds, err)
+		}
+
+		current := 1
+		if v, ok := state[ds]; ok {
+			current = v.Version
+		} else if !saved {
+			// Nothing persisted yet: the dataset starts from a current seed.
+			current = latest
+		}
+		if current >= latest {
+			if _, ok := state[ds]; !ok && !dryRun {
+				state[ds] = &DatasetVersion{Version: latest, UpdatedAt: time.Now().UTC()}
+				changed = true
+			}
+			continue
+		}
+
+		result := MigrationResult{Dataset: ds, From: current, To: latest, Applied: []string{}, Records: len(records)}
+		migrated := copyRecords(records)
+		var applied []AppliedMigration
+		for _, mig := range list {
+			if mig.Version <= current {
+				continue
+			}
+			if migrated, err = mig.apply(migrated); err != nil {
+				return nil, err
+			}
+			result.Applied = append(result.Applied, mig.Name)
+			applied = append(applied, AppliedMigration{Version: mig.Version, Name: mig.Name})
+		}
+
+		if dryRun {
+			result.Changes = recordChanges(records, migrated)
+		} else {
+			writes = append(writes, pending{dataset: ds, migrated: migrated, applied: applied})
+		}
+		results = append(results, result)
+	}
+	if dryRun {
+		return results, nil
+	}
+
+	for _, w := range writes {
+		if err := e.writeState(w.dataset, w.migrated); err != nil {
+			if changed {
+				// Keep what earlier datasets recorded; the save error wins.
+				_ = writeJSONPretty(e.migrationStatePath(), state)
+			}
+			return nil, fmt.Errorf("save %s: %w", w.dataset, err)
+		}
+		entry := state[w.dataset]
+		if entry == nil {
+			entry = &DatasetVersion{}
+			state[w.dataset] = entry
+		}
+		now := time.Now().UTC()
+		for _, a := range w.applied {
+			a.AppliedAt = now
+			entry.Applied = append(entry.Applied, a)
+			entry.Version = a.Version
+		}
+		entry.UpdatedAt = now
+		if err := writeJSONPretty(e.migrationStatePath(), state); err != nil {
+			return nil, fmt.Errorf("save migration state: %w", err)
+		}
+		changed = false
+	}
+	if changed {
+		if err := writeJSONPretty(e.migrationStatePath(), state); err != nil {
+			return nil, fmt.Errorf("save migration state: %w", err)
+		}
+	}
+	return results, nil
+}
+
+func recordChanges(before, after []any) []RecordChange {
+	changes := []RecordChange{}
+	for i := range before {
+		var next any
+		if i < len(after) {
+			next = after[i]
+		}
+		diffs := diffValues("", deepCopy(before[i]), deepCopy(next))
+		if len(diffs) == 0 {
+			continue
+		}
+		id := ""
+		if m, ok := toMap(before[i]); ok {
+			id = toString(m["id"])
+		}
+		changes = append(changes, RecordChange{Index: i, ID: id, Differences: diffs})
+	}
+	return changes
+}
+
+func (m *Migration) apply(records []any) ([]any, error) {
+	for i, it := range records {
+		rec, ok := toMap(it)
+		if !ok {
+			continue
+		}
+		for j, st := range m.Steps {
+			scope := map[string]any{"record": rec}
+			if st.When != "" {
+				match, err := evalCondition(st.When, scope)
+				if err != nil {
+					return nil, fmt.Errorf("%s/%s step %d: %w", m.Dataset, m.Name, j+1, err)
+				}
+				if !match {
+					continue
+				}
+			}
+			if err := transformOps[st.Op](rec, st.Args, scope); err != nil {
+				return nil, fmt.Errorf("%s/%s step %d (%s) on record %d: %w", m.Dataset, m.Name, j+1, st.Op, i, err)
+			}
+		}
+		records[i] = rec
+	}
+	return records, nil
+}
+
+type transformFunc func(rec map[string]any, args map[string]any, scope map[string]any) error
+
+var transformOps = map[string]transformFunc{
+	"rename": func(rec, args, _ map[string]any) error {
+		from, to := str(args["from"]), str(args["to"])
+		if from == "" || to == "" {
+			return errors.New("rename requires from and to")
+		}
+		if v, ok := rec[from]; ok {
+			rec[to] = v
+			delete(rec, from)
+		}
+		return nil
+	},
+	"set": func(rec, args, scope map[string]any) error {
+		field := str(args["field"])
+		if field == "" {
+			return errors.New("set requires field")
+		}
+		rec[field] = deepCopy(getExpr(scope, args["value"], nil))
+		return nil
+	},
+	"default": func(rec, args, scope map[string]any) error {
+		field := str(args["field"])
+		if field == "" {
+			return errors.New("default requires field")
+		}
+		if rec[field] == nil {
+			rec[field] = deepCopy(getExpr(scope, args["value"], nil))
+		}
+		return nil
+	},
+	"remove": func(rec, args, _ map[string]any) error {
+		for _, f := range toStringSlice(args["field"]) {
+			delete(rec, f)
+		}
+		return nil
+	},
+	"split": func(rec, args, _ map[string]any) error {
+		field := str(args["field"])
+		into := toStringSlice(args["into"])
+		if field == "" || len(into) == 0 {
+			return errors.New("split requires field and into")
+		}
+		sep := str(args["separator"])
+		if sep == "" {
+			sep = " "
+		}
+		v, ok := rec[field].(string)
+		if !ok {
+			return nil
+		}
+		parts := strings.SplitN(v, sep, len(into))
+		for i, name := range into {
+			if i < len(parts) {
+				rec[name] = parts[i]
+			} else {
+				rec[name] = ""
+			}
+		}
+		return nil
+	},
+	"join": func(rec, args, _ map[string]any) error {
+		fields := toStringSlice(args["fields"])
+		into := str(args["into"])
+		if len(fields) == 0 || into == "" {
+			return errors.New("join requires fields and into")
+		}
+		sep, ok := args["separator"].(string)
+		if !ok {
+			sep = " "
+		}
+		parts := make([]string, 0, len(fields))
+		for _, f := range fields {
+			if s := toString(rec[f]); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		rec[into] = strings.Join(parts, sep)
+		return nil
+	},
+	"convert": func(rec, args, _ map[string]any) error {
+		field := str(args["field"])
+		v, ok := rec[field]
+		if field == "" || !ok || v == nil {
+			return nil
+		}
+		switch str(args["to"]) {
+		case "string":
+			rec[field] = toString(v)
+		case "number":
+			f, err := strconv.ParseFloat(toString(v), 64)
+			if err != nil {
+				return fmt.Errorf("convert %s to number: %w", field, err)
+			}
+			rec[field] = f
+		case "boolean":
+			b, err := strconv.ParseBool(toString(v))
+			if err != nil {
+				return fmt.Errorf("convert %s to boolean: %w", field, err)
+			}
+			rec[field] = b
+		default:
+			return fmt.Errorf("convert to must be string, number or boolean")
+		}
+		return nil
+	},
+}
@@ -0,0 +1,143 @@
+package artifact
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestMigration(t *testing.T, repo, dataset, name, content string) {
+	t.Helper()
+	dir := filepath.Join(repo, "migrations", dataset)
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+}
+
+func TestMigrateSavedState(t *testing.T) {
+	repo := t.TempDir()
+	writeTestMigration(t, repo, "users", "002-split-name.yaml", `
+description: Split name into firstName and lastName
+steps:
+  - op: split
+    args: { field: name, into: [firstName, lastName] }
+  - op: remove
+    args: { field: name }
+`)
+	writeTestMigration(t, repo, "users", "003-status.yaml", `
+steps:
+  - op: default
+    args: { field: status, value: active }
+  - op: set
+    when: "$record.admin == true"
+    args: { field: role, value: "$record.status" }
+`)
+	exec := NewExecutor(repo)
+	require.NoError(t, exec.ImportDataset("users", []any{
+		map[string]any{"id": "u1", "name": "Ada Lovelace", "admin": true},
+		map[string]any{"id": "u2", "name": "Grace", "status": "invited"},
+	}))
+
+	plan, err := exec.Migrate(true)
+	require.NoError(t, err)
+	require.Len(t, plan, 1)
+	require.Equal(t, 1, plan[0].From)
+	require.Equal(t, 3, plan[0].To)
+	require.Equal(t, []string{"002-split-name", "003-status"}, plan[0].Applied)
+	require.Len(t, plan[0].Changes, 2)
+	records, err := exec.ExportDataset("users")
+	require.NoError(t, err)
+	require.Equal(t, "Ada Lovelace", records[0].(map[string]any)["name"], "dry run must not write")
+
+	_, err = exec.Migrate(false)
+	require.NoError(t, err)
+	records, err = exec.ExportDataset("users")
+	require.NoError(t, err)
+	require.Equal(t, []any{
+		map[string]any{"id": "u1", "firstName": "Ada", "lastName": "Lovelace", "admin": true, "status": "active", "role": "active"},
+		map[string]any{"id": "u2", "firstName": "Grace", "lastName": "", "status": "invited"},
+	}, records)
+
+	status, err := exec.MigrationStatus()
+	require.NoError(t, err)
+	require.Equal(t, 3, status["users"].Version)
+	require.Len(t, status["users"].Applied, 2)
+
+	again, err := exec.Migrate(false)
+	require.NoError(t, err)
+	require.Empty(t, again)
+}
+
+func TestMigrateMarksUnsavedDatasetsCurrent(t *testing.T) {
+	repo := t.TempDir()
+	writeTestMigration(t, repo, "users", "002-rename.yaml", `
+steps:
+  - op: rename
+    args: { from: name, to: fullName }
+`)
+	exec := NewExecutor(repo)
+	results, err := exec.Migrate(false)
+	require.NoError(t, err)
+	require.Empty(t, results)
+
+	status, err := exec.MigrationStatus()
+	require.NoError(t, err)
+	require.Equal(t, 2, status["users"].Version)
+
+	require.NoError(t, exec.ImportDataset("users", []any{map[string]any{"id": "u1", "name": "kept"}}))
+	results, err = exec.Migrate(false)
+	require.NoError(t, err)
+	require.Empty(t, results, "state written after startup already has the current shape")
+}
+
+func TestMigrateRecordsEachDatasetAsItIsWritten(t *testing.T) {
+	repo := t.TempDir()
+	for _, ds := range []string{"tags", "users"} {
+		writeTestMigration(t, repo, ds, "002-rename.yaml", `
+steps:
+  - op: rename
+    args: { from: name, to: label }
+`)
+	}
+	store := &failingSaveStore{MemoryStore: NewMemoryStore(map[string][]any{
+		"tags":  {map[string]any{"id": "t1", "name": "red"}},
+		"users": {map[string]any{"id": "u1", "name": "Ann"}},
+	}), dataset: "users"}
+	exec := NewExecutor(repo, WithStore(store))
+
+	_, err := exec.Migrate(false)
+	require.ErrorContains(t, err, "save users: disk full")
+	status, err := exec.MigrationStatus()
+	require.NoError(t, err)
+	require.Equal(t, 2, status["tags"].Version, "the written dataset keeps its version")
+	require.NotContains(t, status, "users")
+
+	store.dataset = ""
+	results, err := exec.Migrate(false)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, "users", results[0].Dataset)
+	tags, _, _ := store.Load("tags")
+	require.Equal(t, []any{map[string]any{"id": "t1", "label": "red"}}, tags)
+}
+
+func TestLoadMigrationsRejectsUnknownOps(t *testing.T) {
+	repo := t.TempDir()
+	writeTestMigration(t, repo, "users", "002-bad.yaml", `
+steps:
+  - op: explode
+`)
+	_, err := LoadMigrations(repo)
+	require.ErrorContains(t, err, `unknown op "explode"`)
+}
+
+func TestLoadDatasetPrefersNewestSeed(t *testing.T) {
+	repo := t.TempDir()
+	writeTestSeed(t, repo, "users", `[{"id":"v1"}]`)
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "data", "seed.users.v2.json"), []byte(`[{"id":"v2"}]`), 0o644))
+
+	records, err := NewExecutor(repo).ExportDataset("users")
+	require.NoError(t, err)
+	require.Equal(t, []any{map[string]any{"id": "v2"}}, records)
+}
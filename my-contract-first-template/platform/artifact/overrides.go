@@ -0,0 +1,240 @@
+package artifact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OverrideHeader lets trusted callers rewrite the ExecRequest of a call.
+const OverrideHeader = "X-Artifact-Request"
+
+// OverrideSignatureHeader carries "t=<unix seconds>,sha256=<hex>" computed
+// by SignOverride.
+const OverrideSignatureHeader = "X-Artifact-Signature"
+
+// DefaultOverrideMaxAge is how far a signature's timestamp may be from the
+// gateway's clock before the override is rejected as a replay.
+const DefaultOverrideMaxAge = 5 * time.Minute
+
+// ErrOverrideRejected is returned when an X-Artifact-Request header is not
+// permitted by the active OverridePolicy.
+var ErrOverrideRejected = errors.New("X-Artifact-Request override rejected")
+
+var overrideFields = []string{"method", "path", "params", "query", "headers", "body", "dataset"}
+
+// OverridePolicy controls the X-Artifact-Request header. The zero value, like
+// a nil policy, rejects every override.
+type OverridePolicy struct {
+	// DevMode accepts unsigned overrides. Never enable it on an exposed gateway.
+	DevMode bool
+	// HMACKey accepts overrides signed with SignOverride under this key.
+	HMACKey []byte
+	// AllowedFields limits which top-level fields may be overridden. Nil allows
+	// all of them.
+	AllowedFields []string
+	// AllowedKeys further limits the keys of object fields such as headers or
+	// body. A field without an entry accepts any key.
+	AllowedKeys map[string][]string
+	// Logger receives one audit event per applied override.
+	Logger *slog.Logger
+	// MaxAge bounds the age of signed overrides; zero means
+	// DefaultOverrideMaxAge.
+	MaxAge time.Duration
+	// Clock is checked against signature timestamps; nil means the system
+	// clock.
+	Clock Clock
+}
+
+// SignOverride returns the signature header value for an override payload sent
+// with the given method and URL path at time at.
+func SignOverride(key []byte, method, path, payload string, at time.Time) string {
+	ts := strconv.FormatInt(at.Unix(), 10)
+	return "t=" + ts + ",sha256=" + overrideMAC(key, method, path, ts, payload)
+}
+
+func overrideMAC(key []byte, method, path, ts, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strings.ToUpper(method) + "\n" + path + "\n" + ts + "\n" + payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifySignature checks a SignOverride header, rejecting it when its
+// timestamp is outside the policy's MaxAge.
+func (p *OverridePolicy) verifySignature(method, path, raw, signature string) error {
+	var ts, sum string
+	for _, part := range strings.Split(signature, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "sha256":
+			sum = v
+		}
+	}
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sum == "" {
+		return fmt.Errorf("%w: malformed signature", ErrOverrideRejected)
+	}
+	if !hmac.Equal([]byte(sum), []byte(overrideMAC(p.HMACKey, method, path, ts, raw))) {
+		return fmt.Errorf("%w: invalid signature", ErrOverrideRejected)
+	}
+	clock, maxAge := p.Clock, p.MaxAge
+	if clock == nil {
+		clock = SystemClock()
+	}
+	if maxAge <= 0 {
+		maxAge = DefaultOverrideMaxAge
+	}
+	if age := clock.Now().Sub(time.Unix(sec, 0)); age > maxAge || age < -maxAge {
+		return fmt.Errorf("%w: signature timestamp is outside the %s window", ErrOverrideRejected, maxAge)
+	}
+	return nil
+}
+
+// ParseOverrideAllowlist parses a spec such as "query,headers:X-User|X-Tenant"
+// into allowed fields and per-field allowed keys.
+func ParseOverrideAllowlist(spec string) ([]string, map[string][]string, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil, nil
+	}
+	fields := []string{}
+	keys := map[string][]string{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		field, keyList, hasKeys := strings.Cut(part, ":")
+		if !isOverrideField(field) {
+			return nil, nil, fmt.Errorf("unknown override field: %s", field)
+		}
+		fields = append(fields, field)
+		if hasKeys {
+			keys[field] = strings.Split(keyList, "|")
+		}
+	}
+	return fields, keys, nil
+}
+
+// authorize verifies that the raw override may be applied to a request with
+// the given method and path, returning the field names it touches.
+func (p *OverridePolicy) authorize(method, path, raw, signature string) (string, error) {
+	if p == nil {
+		return "", fmt.Errorf("%w: overrides are disabled", ErrOverrideRejected)
+	}
+	mode := ""
+	switch {
+	case signature != "" && len(p.HMACKey) > 0:
+		if err := p.verifySignature(method, path, raw, signature); err != nil {
+			return "", err
+		}
+		mode = "signed"
+	case p.DevMode:
+		mode = "dev"
+	case len(p.HMACKey) > 0:
+		return "", fmt.Errorf("%w: missing %s", ErrOverrideRejected, OverrideSignatureHeader)
+	default:
+		return "", fmt.Errorf("%w: overrides are disabled", ErrOverrideRejected)
+	}
+
+	var payload map[string]any
+	if err := json.Unmarshal([]byte(raw), &payload); err != nil {
+		return "", fmt.Errorf("invalid X-Artifact-Request header: %w", err)
+	}
+	for field, v := range payload {
+		if !isOverrideField(field) {
+			continue
+		}
+		if p.AllowedFields != nil && !containsFold(p.AllowedFields, field) {
+			return "", fmt.Errorf("%w: field %q is not allowed", ErrOverrideRejected, field)
+		}
+		allowed, restricted := p.AllowedKeys[field]
+		obj, isObj := v.(map[string]any)
+		if !restricted || !isObj {
+			continue
+		}
+		for k := range obj {
+			if !containsFold(allowed, k) {
+				return "", fmt.Errorf("%w: %s key %q is not allowed", ErrOverrideRejected, field, k)
+			}
+		}
+	}
+	return mode, nil
+}
+
+// audit records which parts of the request an override rewrote. Values are
+// left out on purpose; they may carry credentials.
+func (p *OverridePolicy) audit(req *ExecRequest, mode, raw, remoteAddr string) {
+	if p.Logger == nil {
+		return
+	}
+	var payload map[string]any
+	_ = json.Unmarshal([]byte(raw), &payload)
+	touched := map[string]any{}
+	for _, field := range overrideFields {
+		v, ok := payload[field]
+		if !ok {
+			continue
+		}
+		if obj, isObj := v.(map[string]any); isObj {
+			keys := make([]string, 0, len(obj))
+			for k := range obj {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			touched[field] = keys
+			continue
+		}
+		touched[field] = true
+	}
+	p.Logger.Warn("request override applied",
+		"request_id", req.RequestID,
+		"mode", mode,
+		"remote_addr", remoteAddr,
+		"fields", touched,
+	)
+}
+
+func applyOverridePolicy(r *http.Request, req *ExecRequest, p *OverridePolicy) error {
+	raw := r.Header.Get(OverrideHeader)
+	if raw == "" {
+		return nil
+	}
+	mode, err := p.authorize(r.Method, r.URL.Path, raw, r.Header.Get(OverrideSignatureHeader))
+	if err != nil {
+		return err
+	}
+	if err := applyRequestOverrides(req, raw); err != nil {
+		return err
+	}
+	p.audit(req, mode, raw, r.RemoteAddr)
+	return nil
+}
+
+func isOverrideField(field string) bool {
+	for _, f := range overrideFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, it := range list {
+		if strings.EqualFold(strings.TrimSpace(it), s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package artifact
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func overrideContext(t *testing.T, payload, signature string) *gin.Context {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	req := httptest.NewRequest("GET", "/v1/users", nil)
+	req.Header.Set(OverrideHeader, payload)
+	if signature != "" {
+		req.Header.Set(OverrideSignatureHeader, signature)
+	}
+	ctx.Request = req
+	return ctx
+}
+
+func TestOverridesRejectedByDefault(t *testing.T) {
+	ctx := overrideContext(t, `{"method":"DELETE"}`, "")
+	_, err := NewExecRequestFromGin(ctx, RequestOptions{})
+	require.ErrorIs(t, err, ErrOverrideRejected)
+
+	_, err = NewExecRequestFromGin(ctx, RequestOptions{Overrides: &OverridePolicy{}})
+	require.ErrorIs(t, err, ErrOverrideRejected)
+}
+
+func TestOverridesAcceptedInDevModeAndAudited(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewLogger(&buf, "info")
+	require.NoError(t, err)
+
+	ctx := overrideContext(t, `{"method":"DELETE","headers":{"X-User":"admin"}}`, "")
+	req, err := NewExecRequestFromGin(ctx, RequestOptions{Overrides: &OverridePolicy{DevMode: true, Logger: logger}})
+	require.NoError(t, err)
+	require.Equal(t, "DELETE", req.Method)
+	require.Contains(t, buf.String(), `"msg":"request override applied"`)
+	require.Contains(t, buf.String(), `"headers":["X-User"]`)
+	require.NotContains(t, buf.String(), "admin")
+}
+
+func TestOverridesRequireValidSignature(t *testing.T) {
+	key := []byte("s3cret")
+	payload := `{"query":{"page":"2"}}`
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	policy := &OverridePolicy{HMACKey: key, Clock: NewManualClock(now)}
+	try := func(signature string) (*ExecRequest, error) {
+		return NewExecRequestFromGin(overrideContext(t, payload, signature), RequestOptions{Overrides: policy})
+	}
+
+	_, err := try("")
+	require.ErrorIs(t, err, ErrOverrideRejected)
+	_, err = try(SignOverride(key, "GET", "/v1/other", payload, now))
+	require.ErrorIs(t, err, ErrOverrideRejected)
+	_, err = try(SignOverride(key, "GET", "/v1/users", payload, now.Add(-DefaultOverrideMaxAge-time.Second)))
+	require.ErrorContains(t, err, "outside the 5m0s window", "old signatures cannot be replayed")
+	_, err = try(strings.Replace(SignOverride(key, "GET", "/v1/users", payload, now), "t=", "t=1", 1))
+	require.ErrorContains(t, err, "invalid signature", "the timestamp is signed")
+	_, err = try("sha256=abc")
+	require.ErrorContains(t, err, "malformed signature")
+
+	req, err := try(SignOverride(key, "GET", "/v1/users", payload, now.Add(-time.Minute)))
+	require.NoError(t, err)
+	require.Equal(t, []string{"2"}, req.Query["page"])
+}
+
+func TestOverrideAllowlist(t *testing.T) {
+	fields, keys, err := ParseOverrideAllowlist("query, headers:X-User|X-Tenant")
+	require.NoError(t, err)
+	policy := &OverridePolicy{DevMode: true, AllowedFields: fields, AllowedKeys: keys}
+
+	_, err = NewExecRequestFromGin(overrideContext(t, `{"headers":{"x-tenant":"t1"},"query":{"a":"b"}}`, ""), RequestOptions{Overrides: policy})
+	require.NoError(t, err)
+
+	_, err = NewExecRequestFromGin(overrideContext(t, `{"dataset":{"users":[]}}`, ""), RequestOptions{Overrides: policy})
+	require.ErrorIs(t, err, ErrOverrideRejected)
+
+	_, err = NewExecRequestFromGin(overrideContext(t, `{"headers":{"Authorization":"x"}}`, ""), RequestOptions{Overrides: policy})
+	require.ErrorIs(t, err, ErrOverrideRejected)
+
+	_, _, err = ParseOverrideAllowlist("cookies")
+	require.Error(t, err)
+}
@@ -0,0 +1,297 @@
+package artifact
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// updateRecord modes. The media types of RFC 7396 and RFC 6902 are accepted
+// as aliases so a mode can be taken from the request's Content-Type.
+const (
+	PatchShallow   = "shallow"
+	PatchMerge     = "merge-patch"
+	PatchJSONPatch = "json-patch"
+)
+
+var patchModeAliases = map[string]string{
+	"":                             PatchShallow,
+	"application/json":             PatchShallow,
+	"application/merge-patch+json": PatchMerge,
+	"application/json-patch+json":  PatchJSONPatch,
+}
+
+// patchMode normalises the mode arg of updateRecord.
+func patchMode(mode string) (string, error) {
+	mode = strings.ToLower(strings.TrimSpace(strings.Split(mode, ";")[0]))
+	if alias, ok := patchModeAliases[mode]; ok {
+		return alias, nil
+	}
+	switch mode {
+	case PatchShallow, PatchMerge, PatchJSONPatch:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown updateRecord mode %q", mode)
+}
+
+// applyPatch returns record changed by patch according to mode. record is
+// not modified.
+func applyPatch(mode string, record map[string]any, patch any) (map[string]any, error) {
+	out := deepCopyMap(record)
+	switch mode {
+	case PatchShallow:
+		patchMap, ok := toMap(patch)
+		if !ok {
+			return nil, errors.New("patch must be an object")
+		}
+		for k, v := range patchMap {
+			out[k] = v
+		}
+		return out, nil
+	case PatchMerge:
+		if _, ok := toMap(patch); !ok {
+			return nil, &StepError{Status: 400, Msg: "merge patch must be an object"}
+		}
+		m, _ := toMap(mergePatch(out, deepCopy(patch)))
+		return m, nil
+	}
+	ops, ok := toSlice(patch)
+	if !ok {
+		return nil, &StepError{Status: 400, Msg: "json patch must be an array of operations"}
+	}
+	doc, err := jsonPatch(out, ops)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := toMap(doc)
+	if !ok {
+		return nil, &StepError{Status: 422, Msg: "json patch must leave the record an object"}
+	}
+	return m, nil
+}
+
+// mergePatch applies an RFC 7396 merge patch: objects merge recursively,
+// null removes a member and anything else replaces the target.
+func mergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
+
+// jsonPatch applies RFC 6902 operations in order. A failed test is a 409;
+// malformed operations and missing paths are 422.
+func jsonPatch(doc any, ops []any) (any, error) {
+	for i, raw := range ops {
+		op, ok := toMap(raw)
+		if !ok {
+			return nil, patchError(i, "operation must be an object")
+		}
+		path, err := parsePointer(op["path"])
+		if err != nil {
+			return nil, patchError(i, err.Error())
+		}
+		value, hasValue := op["value"]
+		name := str(op["op"])
+		switch name {
+		case "add", "replace", "test":
+			if !hasValue {
+				return nil, patchError(i, name+" requires value")
+			}
+		}
+		switch name {
+		case "add":
+			doc, err = pointerAdd(doc, path, deepCopy(value))
+		case "remove":
+			doc, _, err = pointerRemove(doc, path)
+		case "replace":
+			if doc, _, err = pointerRemove(doc, path); err == nil {
+				doc, err = pointerAdd(doc, path, deepCopy(value))
+			}
+		case "move", "copy":
+			var from []string
+			if from, err = parsePointer(op["from"]); err != nil {
+				break
+			}
+			if name == "move" && len(from) < len(path) && reflect.DeepEqual(from, path[:len(from)]) {
+				err = fmt.Errorf("cannot move %s into itself", op["from"])
+				break
+			}
+			var v any
+			if name == "move" {
+				doc, v, err = pointerRemove(doc, from)
+			} else if v, err = pointerGet(doc, from); err == nil {
+				v = deepCopy(v)
+			}
+			if err == nil {
+				doc, err = pointerAdd(doc, path, v)
+			}
+		case "test":
+			got, getErr := pointerGet(doc, path)
+			if getErr != nil || !reflect.DeepEqual(deepCopy(got), deepCopy(value)) {
+				return nil, &StepError{Status: 409, Msg: fmt.Sprintf("json patch operation %d: test failed at %s", i, str(op["path"]))}
+			}
+		default:
+			return nil, patchError(i, fmt.Sprintf("unknown op %q", name))
+		}
+		if err != nil {
+			return nil, patchError(i, err.Error())
+		}
+	}
+	return doc, nil
+}
+
+func patchError(i int, msg string) error {
+	return &StepError{Status: 422, Msg: fmt.Sprintf("json patch operation %d: %s", i, msg)}
+}
+
+// parsePointer splits an RFC 6901 JSON pointer into unescaped tokens.
+func parsePointer(v any) ([]string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("path must be a JSON pointer string")
+	}
+	if s == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(s, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", s)
+	}
+	tokens := strings.Split(s[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func pointerGet(doc any, path []string) (any, error) {
+	cur := doc
+	for _, tok := range path {
+		switch c := cur.(type) {
+		case map[string]any:
+			v, ok := c[tok]
+			if !ok {
+				return nil, fmt.Errorf("path member %q does not exist", tok)
+			}
+			cur = v
+		case []any:
+			i, err := arrayIndex(tok, len(c)-1)
+			if err != nil {
+				return nil, err
+			}
+			cur = c[i]
+		default:
+			return nil, fmt.Errorf("cannot descend into %q", tok)
+		}
+	}
+	return cur, nil
+}
+
+// pointerAdd sets the value at path, inserting into arrays, and returns the
+// possibly replaced document.
+func pointerAdd(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := pointerGet(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+	switch p := parent.(type) {
+	case map[string]any:
+		p[last] = value
+		return doc, nil
+	case []any:
+		i := len(p)
+		if last != "-" {
+			if i, err = arrayIndex(last, len(p)); err != nil {
+				return nil, err
+			}
+		}
+		grown := append(p[:i:i], append([]any{value}, p[i:]...)...)
+		return replaceAt(doc, path[:len(path)-1], grown)
+	}
+	return nil, fmt.Errorf("cannot add to %q", strings.Join(path, "/"))
+}
+
+// pointerRemove deletes the value at path and returns the document and the
+// removed value.
+func pointerRemove(doc any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("cannot remove the whole record")
+	}
+	parent, err := pointerGet(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	last := path[len(path)-1]
+	switch p := parent.(type) {
+	case map[string]any:
+		v, ok := p[last]
+		if !ok {
+			return nil, nil, fmt.Errorf("path member %q does not exist", last)
+		}
+		delete(p, last)
+		return doc, v, nil
+	case []any:
+		i, err := arrayIndex(last, len(p)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		v := p[i]
+		shrunk := append(append([]any{}, p[:i]...), p[i+1:]...)
+		doc, err = replaceAt(doc, path[:len(path)-1], shrunk)
+		return doc, v, err
+	}
+	return nil, nil, fmt.Errorf("cannot remove from %q", strings.Join(path, "/"))
+}
+
+// replaceAt swaps the array at path for a resized copy.
+func replaceAt(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := pointerGet(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+	switch p := parent.(type) {
+	case map[string]any:
+		p[last] = value
+	case []any:
+		i, err := arrayIndex(last, len(p)-1)
+		if err != nil {
+			return nil, err
+		}
+		p[i] = value
+	}
+	return doc, nil
+}
+
+// arrayIndex parses an array token no larger than maxIndex.
+func arrayIndex(tok string, maxIndex int) (int, error) {
+	if tok == "" || (len(tok) > 1 && tok[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", tok)
+	}
+	i, err := strconv.Atoi(tok)
+	if err != nil || i < 0 || i > maxIndex {
+		return 0, fmt.Errorf("array index %q is out of range", tok)
+	}
+	return i, nil
+}
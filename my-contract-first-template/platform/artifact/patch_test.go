@@ -0,0 +1,129 @@
+package artifact
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergePatch(t *testing.T) {
+	record := map[string]any{
+		"id":      "u1",
+		"name":    "Ann",
+		"tags":    []any{"a", "b"},
+		"profile": map[string]any{"city": "Taipei", "zip": "100"},
+	}
+	got, err := applyPatch(PatchMerge, record, map[string]any{
+		"name":    nil,
+		"tags":    []any{"c"},
+		"profile": map[string]any{"zip": nil, "phone": "02"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{
+		"id":      "u1",
+		"tags":    []any{"c"},
+		"profile": map[string]any{"city": "Taipei", "phone": "02"},
+	}, got)
+	require.Equal(t, "Ann", record["name"], "the stored record is not modified")
+
+	got, err = applyPatch(PatchShallow, record, map[string]any{"profile": map[string]any{"zip": "106"}})
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"zip": "106"}, got["profile"], "shallow replaces nested objects")
+}
+
+func TestJSONPatch(t *testing.T) {
+	record := map[string]any{
+		"id":   "u1",
+		"tags": []any{"a", "b"},
+		"a/b":  map[string]any{"~x": 1.0},
+		"meta": map[string]any{"rev": 3.0},
+	}
+	got, err := applyPatch(PatchJSONPatch, record, []any{
+		map[string]any{"op": "test", "path": "/meta/rev", "value": 3},
+		map[string]any{"op": "add", "path": "/tags/1", "value": "x"},
+		map[string]any{"op": "add", "path": "/tags/-", "value": "z"},
+		map[string]any{"op": "remove", "path": "/tags/0"},
+		map[string]any{"op": "replace", "path": "/a~1b/~0x", "value": 2},
+		map[string]any{"op": "copy", "from": "/tags", "path": "/labels"},
+		map[string]any{"op": "move", "from": "/meta/rev", "path": "/rev"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{
+		"id":     "u1",
+		"tags":   []any{"x", "b", "z"},
+		"labels": []any{"x", "b", "z"},
+		"a/b":    map[string]any{"~x": 2.0},
+		"meta":   map[string]any{},
+		"rev":    3.0,
+	}, got)
+	require.Equal(t, []any{"a", "b"}, record["tags"], "the stored record is not modified")
+
+	cases := map[string]struct {
+		ops    []any
+		status int
+	}{
+		"failed test":    {[]any{map[string]any{"op": "test", "path": "/meta/rev", "value": 4}}, 409},
+		"missing member": {[]any{map[string]any{"op": "test", "path": "/nope", "value": nil}}, 409},
+		"remove missing": {[]any{map[string]any{"op": "remove", "path": "/nope"}}, 422},
+		"bad index":      {[]any{map[string]any{"op": "add", "path": "/tags/5", "value": 1}}, 422},
+		"unknown op":     {[]any{map[string]any{"op": "merge", "path": "/id"}}, 422},
+		"no value":       {[]any{map[string]any{"op": "replace", "path": "/id"}}, 422},
+		"move into self": {[]any{map[string]any{"op": "move", "from": "/meta", "path": "/meta/x"}}, 422},
+	}
+	for name, tc := range cases {
+		_, err := applyPatch(PatchJSONPatch, record, tc.ops)
+		var stepErr *StepError
+		require.ErrorAs(t, err, &stepErr, name)
+		require.Equal(t, tc.status, stepErr.Status, name)
+	}
+}
+
+func TestUpdateRecordModes(t *testing.T) {
+	repo := t.TempDir()
+	writeTestFlow(t, repo, "patch.flow.yaml", `
+steps:
+  - op: updateRecord
+    args: { dataset: users, id: "$request.params.id", patch: "$request.body", mode: "$request.headers.Content-Type" }
+    out: saved
+  - op: respond
+    args: { status: 200, bodyFrom: "$ctx.saved" }
+`)
+	store := NewMemoryStore(map[string][]any{"users": {
+		map[string]any{"id": "u1", "name": "Ann", "profile": map[string]any{"city": "Taipei", "zip": "100"}},
+	}})
+	exec := NewExecutor(repo, WithStore(store))
+	patch := func(contentType string, body any) (*ExecResponse, error) {
+		return exec.Run(context.Background(), "patch.flow.yaml", &ExecRequest{
+			Method:  "PATCH",
+			Params:  map[string]string{"id": "u1"},
+			Headers: map[string][]string{"Content-Type": {contentType}},
+			Body:    body,
+		})
+	}
+
+	res, err := patch("application/merge-patch+json", map[string]any{"profile": map[string]any{"zip": nil}})
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"city": "Taipei"}, res.Body.(map[string]any)["profile"])
+
+	_, err = patch("application/json-patch+json", []any{map[string]any{"op": "test", "path": "/name", "value": "Bob"}})
+	var stepErr *StepError
+	require.ErrorAs(t, err, &stepErr)
+	require.Equal(t, 409, stepErr.Status)
+
+	_, err = patch("application/json-patch+json", []any{map[string]any{"op": "replace", "path": "/id", "value": "u2"}})
+	require.ErrorAs(t, err, &stepErr)
+	require.Equal(t, 422, stepErr.Status, "the id cannot be patched")
+
+	res, err = patch("application/json-patch+json; charset=utf-8", []any{
+		map[string]any{"op": "test", "path": "/name", "value": "Ann"},
+		map[string]any{"op": "replace", "path": "/name", "value": "Anna"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Anna", res.Body.(map[string]any)["name"])
+	saved, _, _ := store.Load("users")
+	require.Equal(t, "Anna", saved[0].(map[string]any)["name"])
+
+	_, err = patch("text/csv", map[string]any{})
+	require.ErrorContains(t, err, `unknown updateRecord mode "text/csv"`)
+}
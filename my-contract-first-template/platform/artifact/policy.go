@@ -0,0 +1,256 @@
+package artifact
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPPolicy sets the CORS and security headers of an endpoint. The
+// registry's policy applies to every endpoint; an endpoint's own CORS or
+// Security block replaces the registry's block as a whole.
+type HTTPPolicy struct {
+	CORS     *CORSPolicy      `json:"cors,omitempty"`
+	Security *SecurityHeaders `json:"security,omitempty"`
+}
+
+// CORSPolicy allows browsers on AllowOrigins to call an endpoint. Origins
+// are exact ("http://localhost:4200"), "*", or contain one wildcard
+// ("https://*.example.com"). AllowMethods defaults to the methods served on
+// the path and AllowHeaders to the headers a preflight asks for. MaxAge is
+// how long browsers may cache a preflight, in seconds.
+type CORSPolicy struct {
+	AllowOrigins     []string `json:"allowOrigins"`
+	AllowMethods     []string `json:"allowMethods,omitempty"`
+	AllowHeaders     []string `json:"allowHeaders,omitempty"`
+	ExposeHeaders    []string `json:"exposeHeaders,omitempty"`
+	AllowCredentials bool     `json:"allowCredentials,omitempty"`
+	MaxAge           int      `json:"maxAge,omitempty"`
+}
+
+// SecurityHeaders are added to every response of an endpoint.
+type SecurityHeaders struct {
+	ContentSecurityPolicy string      `json:"contentSecurityPolicy,omitempty"`
+	HSTS                  *HSTSPolicy `json:"hsts,omitempty"`
+	// NoSniff sends X-Content-Type-Options: nosniff.
+	NoSniff        bool   `json:"noSniff,omitempty"`
+	ReferrerPolicy string `json:"referrerPolicy,omitempty"`
+}
+
+// HSTSPolicy is sent as Strict-Transport-Security.
+type HSTSPolicy struct {
+	MaxAge            int  `json:"maxAge"`
+	IncludeSubDomains bool `json:"includeSubDomains,omitempty"`
+	Preload           bool `json:"preload,omitempty"`
+}
+
+var referrerPolicies = map[string]bool{
+	"no-referrer": true, "no-referrer-when-downgrade": true, "origin": true,
+	"origin-when-cross-origin": true, "same-origin": true, "strict-origin": true,
+	"strict-origin-when-cross-origin": true, "unsafe-url": true,
+}
+
+// Validate checks that the policy can be applied.
+func (p HTTPPolicy) Validate() error {
+	if c := p.CORS; c != nil {
+		if len(c.AllowOrigins) == 0 {
+			return errors.New("cors: allowOrigins is required")
+		}
+		for _, o := range c.AllowOrigins {
+			if o == "" || strings.Count(o, "*") > 1 || o != "*" && strings.Contains(o, "*") && !strings.Contains(o, "://") {
+				return fmt.Errorf("cors: invalid origin %q", o)
+			}
+		}
+		// Browsers refuse "*" with credentials; echoing every origin
+		// instead would let any site make credentialed calls.
+		if c.AllowCredentials && containsFold(c.AllowOrigins, "*") {
+			return errors.New(`cors: allowCredentials needs explicit origins, not "*"`)
+		}
+		if c.MaxAge < 0 {
+			return errors.New("cors: maxAge must not be negative")
+		}
+	}
+	if s := p.Security; s != nil {
+		if s.HSTS != nil && s.HSTS.MaxAge < 0 {
+			return errors.New("security: hsts maxAge must not be negative")
+		}
+		if s.ReferrerPolicy != "" && !referrerPolicies[s.ReferrerPolicy] {
+			return fmt.Errorf("security: unknown referrerPolicy %q", s.ReferrerPolicy)
+		}
+	}
+	return nil
+}
+
+// EndpointPolicy returns the policy that applies to ep.
+func (r *Registry) EndpointPolicy(ep EndpointDef) HTTPPolicy {
+	var p HTTPPolicy
+	if r.Policy != nil {
+		p = *r.Policy
+	}
+	if ep.Policy != nil {
+		if ep.Policy.CORS != nil {
+			p.CORS = ep.Policy.CORS
+		}
+		if ep.Policy.Security != nil {
+			p.Security = ep.Policy.Security
+		}
+	}
+	return p
+}
+
+// Middleware sets the security headers and, for allowed origins, the CORS
+// headers of a response. It never rejects a request: browsers enforce CORS
+// themselves when the headers are missing.
+func (p HTTPPolicy) Middleware() gin.HandlerFunc {
+	security := p.securityHeaders()
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+		for k, v := range security {
+			h.Set(k, v)
+		}
+		if p.CORS == nil {
+			return
+		}
+		h.Add("Vary", "Origin")
+		origin := c.GetHeader("Origin")
+		if origin == "" || !p.CORS.allows(origin) {
+			return
+		}
+		p.CORS.setOrigin(h, origin)
+		if len(p.CORS.ExposeHeaders) > 0 {
+			h.Set("Access-Control-Expose-Headers", strings.Join(p.CORS.ExposeHeaders, ", "))
+		}
+	}
+}
+
+func (p HTTPPolicy) securityHeaders() map[string]string {
+	s := p.Security
+	if s == nil {
+		return nil
+	}
+	h := map[string]string{}
+	if s.ContentSecurityPolicy != "" {
+		h["Content-Security-Policy"] = s.ContentSecurityPolicy
+	}
+	if s.HSTS != nil {
+		v := "max-age=" + strconv.Itoa(s.HSTS.MaxAge)
+		if s.HSTS.IncludeSubDomains {
+			v += "; includeSubDomains"
+		}
+		if s.HSTS.Preload {
+			v += "; preload"
+		}
+		h["Strict-Transport-Security"] = v
+	}
+	if s.NoSniff {
+		h["X-Content-Type-Options"] = "nosniff"
+	}
+	if s.ReferrerPolicy != "" {
+		h["Referrer-Policy"] = s.ReferrerPolicy
+	}
+	return h
+}
+
+func (c *CORSPolicy) allows(origin string) bool {
+	for _, o := range c.AllowOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+		if prefix, suffix, ok := strings.Cut(o, "*"); ok &&
+			len(origin) > len(prefix)+len(suffix) &&
+			strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// setOrigin echoes the origin unless any origin is allowed without
+// credentials, which browsers only accept spelled "*".
+func (c *CORSPolicy) setOrigin(h http.Header, origin string) {
+	if !c.AllowCredentials && len(c.AllowOrigins) == 1 && c.AllowOrigins[0] == "*" {
+		h.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	h.Set("Access-Control-Allow-Origin", origin)
+	if c.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// Preflight answers OPTIONS requests for a path whose methods have the given
+// policies. A CORS preflight is allowed when the policy of the requested
+// method allows the origin, method and headers; other OPTIONS requests get
+// the Allow header.
+func Preflight(policies map[string]HTTPPolicy) gin.HandlerFunc {
+	methods := make([]string, 0, len(policies)+1)
+	for m := range policies {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+	allow := strings.Join(append(methods, http.MethodOptions), ", ")
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		method := strings.ToUpper(c.GetHeader("Access-Control-Request-Method"))
+		if origin == "" || method == "" {
+			c.Header("Allow", allow)
+			c.Status(http.StatusNoContent)
+			return
+		}
+		p, ok := policies[method]
+		h := c.Writer.Header()
+		for k, v := range p.securityHeaders() {
+			h.Set(k, v)
+		}
+		h.Add("Vary", "Origin")
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+		cors := p.CORS
+		switch {
+		case !ok || cors == nil || !cors.allows(origin):
+			c.JSON(http.StatusForbidden, map[string]string{"error": "origin " + origin + " may not call " + method + " " + c.Request.URL.Path})
+			return
+		case len(cors.AllowMethods) > 0 && !containsFold(cors.AllowMethods, method):
+			c.JSON(http.StatusForbidden, map[string]string{"error": "method " + method + " is not allowed"})
+			return
+		}
+		requested := splitHeaderList(c.GetHeader("Access-Control-Request-Headers"))
+		if len(cors.AllowHeaders) > 0 {
+			for _, name := range requested {
+				if !containsFold(cors.AllowHeaders, name) {
+					c.JSON(http.StatusForbidden, map[string]string{"error": "header " + name + " is not allowed"})
+					return
+				}
+			}
+			requested = cors.AllowHeaders
+		}
+		cors.setOrigin(h, origin)
+		if len(cors.AllowMethods) > 0 {
+			h.Set("Access-Control-Allow-Methods", strings.Join(cors.AllowMethods, ", "))
+		} else {
+			h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		}
+		if len(requested) > 0 {
+			h.Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+		}
+		if cors.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", strconv.Itoa(cors.MaxAge))
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func splitHeaderList(v string) []string {
+	var out []string
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
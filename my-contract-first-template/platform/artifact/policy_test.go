@@ -0,0 +1,100 @@
+package artifact
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointPolicyOverrides(t *testing.T) {
+	reg := &Registry{Policy: &HTTPPolicy{
+		CORS:     &CORSPolicy{AllowOrigins: []string{"http://localhost:4200"}},
+		Security: &SecurityHeaders{NoSniff: true},
+	}}
+	p := reg.EndpointPolicy(EndpointDef{ID: "a"})
+	require.Equal(t, []string{"http://localhost:4200"}, p.CORS.AllowOrigins)
+
+	p = reg.EndpointPolicy(EndpointDef{ID: "b", Policy: &HTTPPolicy{CORS: &CORSPolicy{AllowOrigins: []string{"*"}}}})
+	require.Equal(t, []string{"*"}, p.CORS.AllowOrigins)
+	require.True(t, p.Security.NoSniff, "blocks the endpoint leaves out are inherited")
+
+	require.Error(t, HTTPPolicy{CORS: &CORSPolicy{}}.Validate())
+	require.Error(t, HTTPPolicy{CORS: &CORSPolicy{AllowOrigins: []string{"*.example.com"}}}.Validate())
+	require.NoError(t, HTTPPolicy{CORS: &CORSPolicy{AllowOrigins: []string{"https://*.example.com"}}}.Validate())
+	require.NoError(t, HTTPPolicy{CORS: &CORSPolicy{AllowOrigins: []string{"https://*.example.com"}, AllowCredentials: true}}.Validate())
+	require.ErrorContains(t, HTTPPolicy{CORS: &CORSPolicy{AllowOrigins: []string{"https://app.example.com", "*"}, AllowCredentials: true}}.Validate(), "allowCredentials")
+}
+
+func TestPolicyMiddlewareAndPreflight(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	policy := HTTPPolicy{
+		CORS: &CORSPolicy{
+			AllowOrigins:     []string{"http://localhost:4200", "https://*.example.com"},
+			AllowHeaders:     []string{"Content-Type", "Authorization"},
+			ExposeHeaders:    []string{"X-Request-Id"},
+			AllowCredentials: true,
+			MaxAge:           600,
+		},
+		Security: &SecurityHeaders{
+			ContentSecurityPolicy: "default-src 'none'",
+			HSTS:                  &HSTSPolicy{MaxAge: 31536000, IncludeSubDomains: true},
+			NoSniff:               true,
+			ReferrerPolicy:        "no-referrer",
+		},
+	}
+	r := gin.New()
+	r.POST("/users", policy.Middleware(), func(c *gin.Context) { c.Status(http.StatusCreated) })
+	r.OPTIONS("/users", Preflight(map[string]HTTPPolicy{http.MethodPost: policy, http.MethodGet: {}}))
+	do := func(method, origin string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/users", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "https://app.example.com", nil)
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	require.Equal(t, "X-Request-Id", w.Header().Get("Access-Control-Expose-Headers"))
+	require.Equal(t, "default-src 'none'", w.Header().Get("Content-Security-Policy"))
+	require.Equal(t, "max-age=31536000; includeSubDomains", w.Header().Get("Strict-Transport-Security"))
+	require.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	require.Equal(t, "no-referrer", w.Header().Get("Referrer-Policy"))
+
+	w = do(http.MethodPost, "https://evil.test", nil)
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	w = do(http.MethodOptions, "http://localhost:4200", map[string]string{
+		"Access-Control-Request-Method":  "POST",
+		"Access-Control-Request-Headers": "content-type",
+	})
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Equal(t, "http://localhost:4200", w.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "GET, POST", w.Header().Get("Access-Control-Allow-Methods"))
+	require.Equal(t, "Content-Type, Authorization", w.Header().Get("Access-Control-Allow-Headers"))
+	require.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+
+	w = do(http.MethodOptions, "http://localhost:4200", map[string]string{
+		"Access-Control-Request-Method":  "POST",
+		"Access-Control-Request-Headers": "X-Secret",
+	})
+	require.Equal(t, http.StatusForbidden, w.Code)
+
+	w = do(http.MethodOptions, "http://localhost:4200", map[string]string{"Access-Control-Request-Method": "GET"})
+	require.Equal(t, http.StatusForbidden, w.Code, "GET has no CORS policy")
+
+	w = do(http.MethodOptions, "", nil)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Equal(t, "GET, POST, OPTIONS", w.Header().Get("Allow"))
+}
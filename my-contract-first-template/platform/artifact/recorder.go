@@ -0,0 +1,283 @@
+package artifact
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fixture is one recorded flow execution: the request, the dataset state
+// around it and the response it produced.
+type Fixture struct {
+	Endpoint   string           `json:"endpoint"`
+	Flow       string           `json:"flow"`
+	RecordedAt time.Time        `json:"recordedAt"`
+	Request    *ExecRequest     `json:"request"`
+	Before     map[string][]any `json:"before"`
+	After      map[string][]any `json:"after"`
+	Response   *ExecResponse    `json:"response"`
+	// Now and Seed freeze the clock and seed the ids of the run, see
+	// WithFrozenRun. Fixtures recorded before they existed replay unfrozen.
+	Now  *time.Time `json:"now,omitempty"`
+	Seed *int64     `json:"seed,omitempty"`
+}
+
+// Recorder captures live gateway traffic into fixture files. Recorded runs are
+// serialised so each fixture sees a consistent before and after state.
+type Recorder struct {
+	dir string
+	mu  sync.Mutex
+	seq int
+}
+
+// NewRecorder writes fixtures into dir.
+func NewRecorder(dir string) *Recorder { return &Recorder{dir: dir} }
+
+// Run executes the flow like Executor.Run and records the exchange.
+func (r *Recorder) Run(ctx context.Context, e *Executor, endpointID, flowFile string, req *ExecRequest) (*ExecResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reqCopy := e.redactor.request(req)
+	before, err := e.stateSnapshot()
+	if err != nil {
+		return nil, fmt.Errorf("snapshot state: %w", err)
+	}
+
+	now, seed := e.clock.Now().UTC(), rand.Int63()
+	res, runErr := e.Run(WithFrozenRun(ctx, now, seed), flowFile, req)
+
+	// The run has already changed state, so its response stands even when
+	// the fixture cannot be completed.
+	after, err := e.stateSnapshot()
+	if err != nil {
+		e.logger.Error("failed to snapshot state; fixture not written", "request_id", req.RequestID, "error", err.Error())
+		return res, runErr
+	}
+	recorded := res
+	if runErr != nil {
+		recorded = ResponseForError(runErr)
+	}
+
+	r.seq++
+	fx := Fixture{
+		Endpoint:   endpointID,
+		Flow:       flowFile,
+		RecordedAt: time.Now().UTC(),
+		Request:    reqCopy,
+		Before:     before,
+		After:      after,
+		Response:   &ExecResponse{Status: recorded.Status, Headers: recorded.Headers, Body: deepCopy(recorded.Body)},
+		Now:        &now,
+		Seed:       &seed,
+	}
+	name := fmt.Sprintf("%s-%s-%04d.json", sanitizeName(endpointID), fx.RecordedAt.Format("20060102T150405"), r.seq)
+	if err := writeJSONPretty(filepath.Join(r.dir, name), fx); err != nil {
+		e.logger.Error("failed to write fixture", "request_id", req.RequestID, "error", err.Error())
+	}
+	return res, runErr
+}
+
+func (e *Executor) stateSnapshot() (map[string][]any, error) {
+	names, err := e.store.Datasets()
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string][]any, len(names))
+	for _, n := range names {
+		data, _, err := e.store.Load(n)
+		if err != nil {
+			return nil, err
+		}
+		out[n] = data
+	}
+	return out, nil
+}
+
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func sanitizeName(s string) string {
+	s = unsafeNameChars.ReplaceAllString(s, "_")
+	if s == "" {
+		return "flow"
+	}
+	return s
+}
+
+// LoadFixtures reads every *.json fixture in dir, in name order.
+func LoadFixtures(dir string) (map[string]*Fixture, []string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(matches)
+	fixtures := make(map[string]*Fixture, len(matches))
+	names := make([]string, 0, len(matches))
+	for _, m := range matches {
+		b, err := os.ReadFile(m)
+		if err != nil {
+			return nil, nil, err
+		}
+		var fx Fixture
+		if err := json.Unmarshal(b, &fx); err != nil {
+			return nil, nil, fmt.Errorf("parse fixture %s: %w", filepath.Base(m), err)
+		}
+		name := filepath.Base(m)
+		fixtures[name] = &fx
+		names = append(names, name)
+	}
+	return fixtures, names, nil
+}
+
+// Difference is one mismatch between a recorded and a replayed value.
+type Difference struct {
+	Path     string `json:"path"`
+	Expected any    `json:"expected"`
+	Actual   any    `json:"actual"`
+}
+
+// ReplayResult is the outcome of replaying one fixture.
+type ReplayResult struct {
+	Fixture     string       `json:"fixture"`
+	Flow        string       `json:"flow"`
+	Differences []Difference `json:"differences"`
+	Error       string       `json:"error,omitempty"`
+}
+
+// ReplayOptions tunes fixture comparison.
+type ReplayOptions struct {
+	// Ignore lists paths excluded from the diff. "*" matches one key and
+	// "[*]" any index, e.g. "response.body.id" or "after.users[*].createdAt".
+	Ignore []string
+}
+
+// ReplayFixture re-runs fx against the current flows of repoPath, starting
+// from the recorded before-state, and diffs response and after-state.
+func ReplayFixture(ctx context.Context, repoPath, name string, fx *Fixture, opts ReplayOptions) ReplayResult {
+	result := ReplayResult{Fixture: name, Flow: fx.Flow, Differences: []Difference{}}
+	store := NewMemoryStore(fx.Before)
+	quiet := slog.New(slog.NewTextHandler(io.Discard, nil))
+	exec := NewExecutor(repoPath, WithStore(store), WithLogger(quiet))
+
+	req := fx.Request
+	if req == nil {
+		req = &ExecRequest{}
+	}
+	if fx.Now != nil && fx.Seed != nil {
+		ctx = WithFrozenRun(ctx, *fx.Now, *fx.Seed)
+	}
+	res, err := exec.Run(ctx, fx.Flow, req)
+	if err != nil {
+		if ctx.Err() != nil {
+			result.Error = err.Error()
+			return result
+		}
+		res = ResponseForError(err)
+	}
+	after, err := exec.stateSnapshot()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	recorded := map[string]any{"response": fx.Response, "after": fx.After}
+	replayed := map[string]any{"response": res, "after": after}
+	ignore := compileIgnore(opts.Ignore)
+	for _, d := range diffValues("", deepCopy(recorded), deepCopy(replayed)) {
+		if !ignore(d.Path) {
+			result.Differences = append(result.Differences, d)
+		}
+	}
+	return result
+}
+
+// diffValues lists every path where got differs from want.
+func diffValues(path string, want, got any) []Difference {
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			return []Difference{{Path: path, Expected: want, Actual: got}}
+		}
+		keys := map[string]bool{}
+		for k := range w {
+			keys[k] = true
+		}
+		for k := range g {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		out := []Difference{}
+		for _, k := range sorted {
+			out = append(out, diffValues(joinPath(path, k), w[k], g[k])...)
+		}
+		return out
+	case []any:
+		g, ok := got.([]any)
+		if !ok {
+			return []Difference{{Path: path, Expected: want, Actual: got}}
+		}
+		out := []Difference{}
+		for i := 0; i < len(w) || i < len(g); i++ {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(g):
+				out = append(out, Difference{Path: p, Expected: w[i]})
+			case i >= len(w):
+				out = append(out, Difference{Path: p, Actual: g[i]})
+			default:
+				out = append(out, diffValues(p, w[i], g[i])...)
+			}
+		}
+		return out
+	default:
+		if !reflect.DeepEqual(want, got) {
+			return []Difference{{Path: path, Expected: want, Actual: got}}
+		}
+		return nil
+	}
+}
+
+func joinPath(base, key string) string {
+	if base == "" {
+		return key
+	}
+	return base + "." + key
+}
+
+func compileIgnore(patterns []string) func(string) bool {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		expr := regexp.QuoteMeta(p)
+		expr = strings.ReplaceAll(expr, `\[\*\]`, `\[\d+\]`)
+		expr = strings.ReplaceAll(expr, `\*`, `[^.\[]+`)
+		res = append(res, regexp.MustCompile("^"+expr+`($|[.\[])`))
+	}
+	return func(path string) bool {
+		for _, re := range res {
+			if re.MatchString(path) {
+				return true
+			}
+		}
+		return false
+	}
+}
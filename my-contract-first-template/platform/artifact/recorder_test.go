@@ -0,0 +1,136 @@
+package artifact
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const recordFlow = `
+version: 1
+steps:
+  - op: insertRecord
+    args:
+      dataset: notes
+      record: "$request.body"
+    out: saved
+  - op: respond
+    args:
+      status: 201
+      bodyFrom: "$ctx.saved"
+`
+
+func TestRecordAndReplayFixture(t *testing.T) {
+	repo := t.TempDir()
+	fixtures := t.TempDir()
+	writeTestFlow(t, repo, "notes.flow.yaml", recordFlow)
+
+	exec := NewExecutor(repo, WithStore(NewMemoryStore(map[string][]any{"notes": {map[string]any{"id": "n0"}}})))
+	rec := NewRecorder(fixtures)
+	res, err := rec.Run(context.Background(), exec, "notes.create", "notes.flow.yaml", &ExecRequest{
+		Method: "POST",
+		Body:   map[string]any{"id": "n1", "text": "hi"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 201, res.Status)
+
+	loaded, names, err := LoadFixtures(fixtures)
+	require.NoError(t, err)
+	require.Len(t, names, 1)
+	fx := loaded[names[0]]
+	require.Equal(t, "notes.create", fx.Endpoint)
+	require.Len(t, fx.Before["notes"], 1)
+	require.Len(t, fx.After["notes"], 2)
+
+	result := ReplayFixture(context.Background(), repo, names[0], fx, ReplayOptions{})
+	require.Empty(t, result.Error)
+	require.Empty(t, result.Differences)
+
+	writeTestFlow(t, repo, "notes.flow.yaml", `
+version: 1
+steps:
+  - op: respond
+    args:
+      status: 200
+      body: { text: hi }
+`)
+	result = ReplayFixture(context.Background(), repo, names[0], fx, ReplayOptions{Ignore: []string{"after.notes[*]"}})
+	require.Equal(t, []Difference{
+		{Path: "response.body.id", Expected: "n1", Actual: nil},
+		{Path: "response.status", Expected: 201.0, Actual: 200.0},
+	}, result.Differences)
+}
+
+// flakyStore fails to list datasets once the first listing has succeeded.
+type flakyStore struct {
+	*MemoryStore
+	listed bool
+}
+
+func (s *flakyStore) Datasets() ([]string, error) {
+	if s.listed {
+		return nil, errors.New("disk gone")
+	}
+	s.listed = true
+	return s.MemoryStore.Datasets()
+}
+
+func TestRecordedFixturesAreRedacted(t *testing.T) {
+	repo := t.TempDir()
+	fixtures := t.TempDir()
+	writeTestFlow(t, repo, "notes.flow.yaml", recordFlow)
+
+	exec := NewExecutor(repo, WithStore(NewMemoryStore(nil)))
+	_, err := NewRecorder(fixtures).Run(context.Background(), exec, "notes.create", "notes.flow.yaml", &ExecRequest{
+		Method:  "POST",
+		Headers: map[string][]string{"Authorization": {"Bearer s3cr3t"}, "Cookie": {"sid=s3cr3t"}, "Accept": {"*/*"}},
+		Query:   map[string][]string{"token": {"s3cr3t"}},
+		Body:    map[string]any{"id": "n1", "password": "s3cr3t"},
+	})
+	require.NoError(t, err)
+
+	loaded, names, err := LoadFixtures(fixtures)
+	require.NoError(t, err)
+	require.Len(t, names, 1)
+	raw, err := os.ReadFile(filepath.Join(fixtures, names[0]))
+	require.NoError(t, err)
+	require.NotContains(t, string(raw), "Bearer s3cr3t")
+	require.NotContains(t, string(raw), "sid=s3cr3t")
+	recorded := loaded[names[0]].Request
+	require.Equal(t, []string{"*/*"}, recorded.Headers["Accept"])
+	require.Equal(t, []string{redactedValue}, recorded.Query["token"])
+	require.Equal(t, redactedValue, recorded.Body.(map[string]any)["password"])
+}
+
+func TestRecorderKeepsResponseWhenAfterSnapshotFails(t *testing.T) {
+	repo := t.TempDir()
+	fixtures := t.TempDir()
+	writeTestFlow(t, repo, "notes.flow.yaml", recordFlow)
+
+	store := &flakyStore{MemoryStore: NewMemoryStore(nil)}
+	exec := NewExecutor(repo, WithStore(store))
+	res, err := NewRecorder(fixtures).Run(context.Background(), exec, "notes.create", "notes.flow.yaml", &ExecRequest{
+		Method: "POST",
+		Body:   map[string]any{"id": "n1"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 201, res.Status)
+	saved, _, _ := store.Load("notes")
+	require.Len(t, saved, 1)
+	_, names, err := LoadFixtures(fixtures)
+	require.NoError(t, err)
+	require.Empty(t, names)
+}
+
+func TestCompileIgnore(t *testing.T) {
+	ignore := compileIgnore([]string{"response.body.id", "after.*[*].createdAt"})
+	require.True(t, ignore("response.body.id"))
+	require.True(t, ignore("response.body.id.nested"))
+	require.False(t, ignore("response.body.identity"))
+	require.True(t, ignore("after.users[3].createdAt"))
+	require.False(t, ignore("after.users[3].name"))
+}
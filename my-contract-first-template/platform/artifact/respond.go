@@ -0,0 +1,213 @@
+package artifact
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Response formats understood by respond's format argument.
+const (
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+	FormatXML    = "xml"
+	FormatText   = "text"
+	FormatBinary = "binary"
+)
+
+var formatContentTypes = map[string]string{
+	FormatJSON:   "application/json",
+	FormatNDJSON: "application/x-ndjson",
+	FormatCSV:    "text/csv; charset=utf-8",
+	FormatXML:    "application/xml; charset=utf-8",
+	FormatText:   "text/plain; charset=utf-8",
+	FormatBinary: "application/octet-stream",
+}
+
+// acceptFormats maps Accept media types to formats.
+var acceptFormats = map[string]string{
+	"application/json":         FormatJSON,
+	"application/x-ndjson":     FormatNDJSON,
+	"application/ndjson":       FormatNDJSON,
+	"text/csv":                 FormatCSV,
+	"application/xml":          FormatXML,
+	"text/xml":                 FormatXML,
+	"text/plain":               FormatText,
+	"application/octet-stream": FormatBinary,
+}
+
+var xmlNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9._-]*$`)
+
+// negotiateFormat picks the first of candidates preferred by accept, in
+// order of q-value. With no Accept header the first candidate is used.
+func negotiateFormat(accept string, candidates []string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return candidates[0], true
+	}
+	type ranged struct {
+		media string
+		q     float64
+	}
+	var ranges []ranged
+	for _, part := range strings.Split(accept, ",") {
+		media, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, ranged{media, q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	for _, r := range ranges {
+		for _, c := range candidates {
+			ct, _, _ := mime.ParseMediaType(formatContentTypes[c])
+			switch {
+			case r.media == "*/*", r.media == ct, acceptFormats[r.media] == c:
+				return c, true
+			case strings.HasSuffix(r.media, "/*") && strings.HasPrefix(ct, strings.TrimSuffix(r.media, "*")):
+				return c, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Render serialises the body in the response's format and returns the
+// matching Content-Type.
+func (r *ExecResponse) Render() (string, []byte, error) {
+	format := r.Format
+	if format == "" {
+		format = FormatJSON
+	}
+	contentType := r.ContentType
+	if contentType == "" {
+		contentType = formatContentTypes[format]
+	}
+	switch format {
+	case FormatJSON:
+		return contentType, r.BodyJSON(), nil
+	case FormatNDJSON:
+		items, ok := toSlice(r.Body)
+		if !ok {
+			items = []any{r.Body}
+		}
+		var buf bytes.Buffer
+		for _, it := range items {
+			b, err := json.Marshal(it)
+			if err != nil {
+				return "", nil, err
+			}
+			buf.Write(b)
+			buf.WriteByte('\n')
+		}
+		return contentType, buf.Bytes(), nil
+	case FormatCSV:
+		b, err := renderCSV(r.Body, r.Columns)
+		return contentType, b, err
+	case FormatXML:
+		root := r.XMLRoot
+		if root == "" {
+			root = "response"
+		}
+		var buf bytes.Buffer
+		buf.WriteString(xml.Header)
+		writeXML(&buf, root, r.Body)
+		return contentType, buf.Bytes(), nil
+	case FormatText:
+		return contentType, []byte(toString(r.Body)), nil
+	case FormatBinary:
+		b, err := base64.StdEncoding.DecodeString(str(r.Body))
+		if err != nil {
+			return "", nil, fmt.Errorf("binary body must be base64: %w", err)
+		}
+		return contentType, b, nil
+	default:
+		return "", nil, fmt.Errorf("unknown response format: %s", format)
+	}
+}
+
+// renderCSV writes an array of objects as CSV. Without columns the header
+// is the sorted union of the records' keys; columns may be dotted paths.
+func renderCSV(body any, columns []string) ([]byte, error) {
+	items, ok := toSlice(body)
+	if !ok {
+		return nil, fmt.Errorf("csv body must be an array")
+	}
+	if len(columns) == 0 {
+		seen := map[string]bool{}
+		for _, it := range items {
+			if m, ok := toMap(it); ok {
+				for k := range m {
+					seen[k] = true
+				}
+			}
+		}
+		columns = sortedKeys(seen)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(columns); err != nil {
+		return nil, err
+	}
+	for _, it := range items {
+		m, _ := toMap(it)
+		row := make([]string, len(columns))
+		for i, col := range columns {
+			row[i] = toString(getByPath(m, strings.Split(col, ".")))
+		}
+		if err := w.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// writeXML encodes JSON-like values: object keys become elements (keys that
+// are not valid XML names use <entry key="...">) and arrays repeat <item>.
+func writeXML(buf *bytes.Buffer, name string, v any) {
+	open, end := "<"+name+">", "</"+name+">"
+	if !xmlNamePattern.MatchString(name) {
+		var attr bytes.Buffer
+		_ = xml.EscapeText(&attr, []byte(name))
+		open, end = `<entry key="`+attr.String()+`">`, "</entry>"
+	}
+	switch t := v.(type) {
+	case nil:
+		buf.WriteString(strings.TrimSuffix(open, ">") + "/>")
+	case map[string]any:
+		buf.WriteString(open)
+		for _, k := range sortedKeys(t) {
+			writeXML(buf, k, t[k])
+		}
+		buf.WriteString(end)
+	case []any:
+		buf.WriteString(open)
+		for _, it := range t {
+			writeXML(buf, "item", it)
+		}
+		buf.WriteString(end)
+	default:
+		buf.WriteString(open)
+		_ = xml.EscapeText(buf, []byte(toString(t)))
+		buf.WriteString(end)
+	}
+}
@@ -0,0 +1,90 @@
+package artifact
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	cases := []struct {
+		accept     string
+		candidates []string
+		want       string
+		ok         bool
+	}{
+		{"", []string{FormatJSON, FormatCSV}, FormatJSON, true},
+		{"text/csv", []string{FormatJSON, FormatCSV}, FormatCSV, true},
+		{"application/json;q=0.5, text/csv", []string{FormatJSON, FormatCSV}, FormatCSV, true},
+		{"text/*", []string{FormatJSON, FormatXML, FormatText}, FormatText, true},
+		{"*/*", []string{FormatNDJSON}, FormatNDJSON, true},
+		{"application/ndjson", []string{FormatJSON, FormatNDJSON}, FormatNDJSON, true},
+		{"image/png", []string{FormatJSON}, "", false},
+		{"application/json;q=0", []string{FormatJSON}, "", false},
+	}
+	for _, tc := range cases {
+		got, ok := negotiateFormat(tc.accept, tc.candidates)
+		require.Equal(t, tc.ok, ok, tc.accept)
+		require.Equal(t, tc.want, got, tc.accept)
+	}
+}
+
+func TestRenderFormats(t *testing.T) {
+	rows := []any{
+		map[string]any{"id": "1", "name": "Ada, Countess", "meta": map[string]any{"team": "a"}},
+		map[string]any{"id": "2", "name": "Grace"},
+	}
+	cases := []struct {
+		res         ExecResponse
+		contentType string
+		body        string
+	}{
+		{ExecResponse{Body: map[string]any{"ok": true}}, "application/json", `{"ok":true}`},
+		{ExecResponse{Body: rows, Format: FormatNDJSON}, "application/x-ndjson",
+			`{"id":"1","meta":{"team":"a"},"name":"Ada, Countess"}` + "\n" + `{"id":"2","name":"Grace"}` + "\n"},
+		{ExecResponse{Body: rows, Format: FormatCSV, Columns: []string{"id", "name", "meta.team"}}, "text/csv; charset=utf-8",
+			"id,name,meta.team\n1,\"Ada, Countess\",a\n2,Grace,\n"},
+		{ExecResponse{Body: rows[1:], Format: FormatCSV}, "text/csv; charset=utf-8", "id,name\n2,Grace\n"},
+		{ExecResponse{Body: map[string]any{"users": rows[1:], "a b": "<x>", "none": nil}, Format: FormatXML, XMLRoot: "result"},
+			"application/xml; charset=utf-8",
+			`<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+				`<result><entry key="a b">&lt;x&gt;</entry><none/><users><item><id>2</id><name>Grace</name></item></users></result>`},
+		{ExecResponse{Body: "plain", Format: FormatText}, "text/plain; charset=utf-8", "plain"},
+		{ExecResponse{Body: "iVBORw==", Format: FormatBinary, ContentType: "image/png"}, "image/png", "\x89PNG"},
+	}
+	for _, tc := range cases {
+		contentType, body, err := tc.res.Render()
+		require.NoError(t, err, tc.res.Format)
+		require.Equal(t, tc.contentType, contentType, tc.res.Format)
+		require.Equal(t, tc.body, string(body), tc.res.Format)
+	}
+
+	_, _, err := (&ExecResponse{Body: map[string]any{}, Format: FormatCSV}).Render()
+	require.Error(t, err)
+}
+
+func TestRespondNegotiatesAccept(t *testing.T) {
+	repo := t.TempDir()
+	writeTestFlow(t, repo, "export.flow.yaml", `
+version: 1
+steps:
+  - op: respond
+    args:
+      status: 200
+      format: [json, csv]
+      columns: [id]
+      body: [{ id: "1" }]
+`)
+	exec := NewExecutor(repo)
+	res, err := exec.Run(context.Background(), "export.flow.yaml", &ExecRequest{Headers: map[string][]string{"Accept": {"text/csv"}}})
+	require.NoError(t, err)
+	require.Equal(t, FormatCSV, res.Format)
+	require.Equal(t, "Accept", res.Headers["Vary"])
+	_, body, err := res.Render()
+	require.NoError(t, err)
+	require.Equal(t, "id\n1\n", string(body))
+
+	_, err = exec.Run(context.Background(), "export.flow.yaml", &ExecRequest{Headers: map[string][]string{"Accept": {"application/xml"}}})
+	require.Equal(t, 406, err.(*StepError).Status)
+}
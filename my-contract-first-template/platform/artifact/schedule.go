@@ -0,0 +1,224 @@
+package artifact
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ScheduleDef is a registry entry that runs Flow on a cron schedule with a
+// synthetic request whose body is Body.
+type ScheduleDef struct {
+	ID   string `json:"id"`
+	Cron string `json:"cron"`
+	Flow string `json:"flow"`
+	// Jitter delays each run by a random duration below it, e.g. "30s".
+	Jitter string `json:"jitter,omitempty"`
+	Body   any    `json:"body,omitempty"`
+}
+
+// JobStatus is the state of one scheduled job.
+type JobStatus struct {
+	ID          string     `json:"id"`
+	Cron        string     `json:"cron"`
+	Flow        string     `json:"flow"`
+	Running     bool       `json:"running"`
+	NextRun     *time.Time `json:"nextRun,omitempty"`
+	LastRun     *time.Time `json:"lastRun,omitempty"`
+	LastStatus  int        `json:"lastStatus,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+	LastElapsed string     `json:"lastElapsed,omitempty"`
+	Runs        int        `json:"runs"`
+	Skipped     int        `json:"skipped"`
+}
+
+// SchedulerOptions configures a Scheduler. Zero values use the wall clock,
+// slog.Default and a time-seeded random source.
+type SchedulerOptions struct {
+	Clock  Clock
+	Logger *slog.Logger
+	Rand   *rand.Rand
+}
+
+// Scheduler runs scheduled flows through an Executor. A job whose previous
+// run is still going when it fires again is skipped, not queued.
+type Scheduler struct {
+	exec   *Executor
+	clock  Clock
+	logger *slog.Logger
+
+	randMu sync.Mutex
+	rand   *rand.Rand
+
+	jobs   []*scheduledJob
+	stop   chan struct{}
+	wg     sync.WaitGroup
+	runsWG sync.WaitGroup
+}
+
+type scheduledJob struct {
+	def    ScheduleDef
+	spec   *CronSchedule
+	jitter time.Duration
+
+	mu     sync.Mutex
+	status JobStatus
+}
+
+// NewScheduler validates defs.
+func NewScheduler(e *Executor, defs []ScheduleDef, opts SchedulerOptions) (*Scheduler, error) {
+	s := &Scheduler{exec: e, clock: opts.Clock, logger: opts.Logger, rand: opts.Rand}
+	if s.clock == nil {
+		s.clock = SystemClock()
+	}
+	if s.logger == nil {
+		s.logger = slog.Default()
+	}
+	if s.rand == nil {
+		s.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	seen := map[string]bool{}
+	for _, def := range defs {
+		if def.ID == "" || def.Flow == "" {
+			return nil, fmt.Errorf("schedule %q: id and flow are required", def.ID)
+		}
+		if seen[def.ID] {
+			return nil, fmt.Errorf("schedule %s defined twice", def.ID)
+		}
+		seen[def.ID] = true
+		spec, err := ParseCron(def.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %s: %w", def.ID, err)
+		}
+		var jitter time.Duration
+		if def.Jitter != "" {
+			if jitter, err = time.ParseDuration(def.Jitter); err != nil || jitter < 0 {
+				return nil, fmt.Errorf("schedule %s: invalid jitter %q", def.ID, def.Jitter)
+			}
+		}
+		s.jobs = append(s.jobs, &scheduledJob{
+			def:    def,
+			spec:   spec,
+			jitter: jitter,
+			status: JobStatus{ID: def.ID, Cron: def.Cron, Flow: def.Flow},
+		})
+	}
+	return s, nil
+}
+
+// Start begins firing jobs.
+func (s *Scheduler) Start() {
+	s.stop = make(chan struct{})
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(j)
+	}
+}
+
+// Stop stops firing jobs and waits for running ones to finish.
+func (s *Scheduler) Stop() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	s.wg.Wait()
+	s.runsWG.Wait()
+}
+
+func (s *Scheduler) loop(j *scheduledJob) {
+	defer s.wg.Done()
+	for {
+		now := s.clock.Now()
+		next := j.spec.Next(now)
+		if next.IsZero() {
+			s.logger.Warn("schedule never fires again", "schedule", j.def.ID)
+			return
+		}
+		next = next.Add(s.jitterFor(j))
+		j.mu.Lock()
+		j.status.NextRun = &next
+		j.mu.Unlock()
+
+		select {
+		case <-s.stop:
+			return
+		case <-s.clock.After(next.Sub(now)):
+		}
+
+		j.mu.Lock()
+		if j.status.Running {
+			j.status.Skipped++
+			j.mu.Unlock()
+			s.logger.Warn("scheduled run skipped; previous run still active", "schedule", j.def.ID)
+			continue
+		}
+		j.status.Running = true
+		j.mu.Unlock()
+		s.runsWG.Add(1)
+		go s.run(j)
+	}
+}
+
+func (s *Scheduler) jitterFor(j *scheduledJob) time.Duration {
+	if j.jitter <= 0 {
+		return 0
+	}
+	s.randMu.Lock()
+	defer s.randMu.Unlock()
+	return time.Duration(s.rand.Int63n(int64(j.jitter)))
+}
+
+func (s *Scheduler) run(j *scheduledJob) {
+	defer s.runsWG.Done()
+	started := s.clock.Now()
+	body := deepCopy(j.def.Body)
+	if body == nil {
+		body = map[string]any{}
+	}
+	res, err := s.exec.Run(context.Background(), j.def.Flow, &ExecRequest{
+		RequestID: newRequestID(),
+		Method:    "SCHEDULE",
+		Path:      j.def.ID,
+		Headers:   map[string][]string{"X-Schedule-Id": {j.def.ID}},
+		Body:      body,
+		Dataset:   map[string]any{},
+	})
+	if err != nil {
+		res = ResponseForError(err)
+		s.logger.Warn("scheduled run failed", "schedule", j.def.ID, "flow", j.def.Flow, "error", err.Error())
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.status.Running = false
+	j.status.Runs++
+	j.status.LastRun = &started
+	j.status.LastStatus = res.Status
+	j.status.LastElapsed = s.clock.Now().Sub(started).String()
+	j.status.LastError = ""
+	if err != nil {
+		j.status.LastError = err.Error()
+	}
+}
+
+// Status reports every job in registry order.
+func (s *Scheduler) Status() []JobStatus {
+	out := make([]JobStatus, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		j.mu.Lock()
+		out = append(out, j.status)
+		j.mu.Unlock()
+	}
+	return out
+}
+
+// Handler serves the job status list.
+func (s *Scheduler) Handler(c *gin.Context) {
+	c.JSON(http.StatusOK, map[string]any{"schedules": s.Status()})
+}
@@ -0,0 +1,118 @@
+package artifact
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronNext(t *testing.T) {
+	base := time.Date(2026, 3, 14, 10, 7, 30, 0, time.UTC) // a Saturday
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 3, 14, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 3, 14, 10, 15, 0, 0, time.UTC)},
+		{"5 9-17/4 * * *", time.Date(2026, 3, 14, 13, 5, 0, 0, time.UTC)},
+		{"0 0 * * 1-5", time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1,15 * *", time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC)}, // day 13 or any Friday
+		{"30 6 * * 7", time.Date(2026, 3, 15, 6, 30, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 90s", base.Add(90 * time.Second)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tc := range cases {
+		spec, err := ParseCron(tc.expr)
+		require.NoError(t, err, tc.expr)
+		require.Equal(t, tc.want, spec.Next(base), tc.expr)
+	}
+
+	for _, bad := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "@every 10ms", "x * * * *"} {
+		_, err := ParseCron(bad)
+		require.Error(t, err, bad)
+	}
+}
+
+// blockingStore holds Load of the "slow" dataset until release is closed.
+type blockingStore struct {
+	*MemoryStore
+	release chan struct{}
+}
+
+func (s *blockingStore) Load(ds string) ([]any, bool, error) {
+	if ds == "slow" {
+		<-s.release
+	}
+	return s.MemoryStore.Load(ds)
+}
+
+func TestSchedulerRunsJobsAndSkipsOverlaps(t *testing.T) {
+	repo := t.TempDir()
+	writeTestFlow(t, repo, "tick.flow.yaml", `
+version: 1
+steps:
+  - op: loadDataset
+    args: { dataset: slow }
+  - op: insertRecord
+    args: { dataset: ticks, record: "$request.body" }
+  - op: respond
+    args: { status: 204 }
+`)
+	store := &blockingStore{MemoryStore: NewMemoryStore(nil), release: make(chan struct{})}
+	exec := NewExecutor(repo, WithStore(store))
+	clock := NewManualClock(time.Date(2026, 1, 1, 0, 0, 30, 0, time.UTC))
+	sched, err := NewScheduler(exec, []ScheduleDef{
+		{ID: "tick", Cron: "* * * * *", Flow: "tick.flow.yaml", Body: map[string]any{"kind": "tick"}},
+	}, SchedulerOptions{Clock: clock})
+	require.NoError(t, err)
+	sched.Start()
+	defer sched.Stop()
+
+	waitForTimer := func() {
+		require.Eventually(t, func() bool { return clock.Waiters() == 1 }, time.Second, time.Millisecond)
+	}
+	waitForTimer()
+	require.Equal(t, time.Date(2026, 1, 1, 0, 1, 0, 0, time.UTC), *sched.Status()[0].NextRun)
+
+	clock.Advance(30 * time.Second) // first run starts and blocks on the slow dataset
+	require.Eventually(t, func() bool { return sched.Status()[0].Running }, time.Second, time.Millisecond)
+	waitForTimer()
+	clock.Advance(time.Minute) // fires while the first run is still active
+	require.Eventually(t, func() bool { return sched.Status()[0].Skipped == 1 }, time.Second, time.Millisecond)
+
+	close(store.release)
+	require.Eventually(t, func() bool { return sched.Status()[0].Runs == 1 }, time.Second, time.Millisecond)
+	st := sched.Status()[0]
+	require.False(t, st.Running)
+	require.Equal(t, 204, st.LastStatus)
+	require.Equal(t, time.Date(2026, 1, 1, 0, 1, 0, 0, time.UTC), *st.LastRun)
+
+	ticks, err := exec.ExportDataset("ticks")
+	require.NoError(t, err)
+	require.Equal(t, []any{map[string]any{"kind": "tick"}}, ticks)
+}
+
+func TestSchedulerJitterIsSeeded(t *testing.T) {
+	clock := NewManualClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	next := func(seed int64) time.Time {
+		sched, err := NewScheduler(NewExecutor(t.TempDir()), []ScheduleDef{
+			{ID: "j", Cron: "@hourly", Flow: "x.flow.yaml", Jitter: "10m"},
+		}, SchedulerOptions{Clock: clock, Rand: rand.New(rand.NewSource(seed))})
+		require.NoError(t, err)
+		sched.Start()
+		defer sched.Stop()
+		require.Eventually(t, func() bool { return sched.Status()[0].NextRun != nil }, time.Second, time.Millisecond)
+		return *sched.Status()[0].NextRun
+	}
+	first := next(42)
+	require.Equal(t, first, next(42))
+	require.False(t, first.Before(time.Date(2026, 1, 1, 1, 0, 0, 0, time.UTC)))
+	require.True(t, first.Before(time.Date(2026, 1, 1, 1, 10, 0, 0, time.UTC)))
+
+	_, err := NewScheduler(NewExecutor(t.TempDir()), []ScheduleDef{{ID: "bad", Cron: "* * *", Flow: "x"}}, SchedulerOptions{})
+	require.Error(t, err)
+}
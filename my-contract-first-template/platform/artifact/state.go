@@ -0,0 +1,265 @@
+package artifact
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Admin state operations hold the executor's write lock for their whole
+// duration, so in-flight flows either complete before they start or run
+// after they finish.
+
+var stateNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// validStateName rejects names that could escape the state directories.
+// Input problems are reported as *StepError so they map to an HTTP status the
+// same way flow errors do.
+func validStateName(kind, name string) error {
+	if !stateNamePattern.MatchString(name) {
+		return &StepError{Status: 400, Msg: fmt.Sprintf("invalid %s name: %q", kind, name)}
+	}
+	return nil
+}
+
+func (e *Executor) snapshotDir() string {
+	return filepath.Join(e.repoPath, ".runtime", "snapshots")
+}
+
+// ResetDatasets drops the saved state of the named datasets, or of every
+// dataset when none are given, so they are served from their seeds again.
+func (e *Executor) ResetDatasets(datasets ...string) ([]string, error) {
+	e.stateMu.Lock()
+	defer e.stateMu.Unlock()
+
+	if len(datasets) == 0 {
+		all, err := e.store.Datasets()
+		if err != nil {
+			return nil, err
+		}
+		datasets = all
+	}
+	for _, ds := range datasets {
+		if err := validStateName("dataset", ds); err != nil {
+			return nil, err
+		}
+	}
+	for _, ds := range datasets {
+		if err := e.store.Delete(ds); err != nil {
+			return nil, fmt.Errorf("reset %s: %w", ds, err)
+		}
+	}
+	e.ids.ResetSequences(datasets...)
+	return datasets, nil
+}
+
+// SaveSnapshot stores the saved state of every dataset under name.
+func (e *Executor) SaveSnapshot(name string) ([]string, error) {
+	if err := validStateName("snapshot", name); err != nil {
+		return nil, err
+	}
+	e.stateMu.Lock()
+	defer e.stateMu.Unlock()
+
+	state, err := e.stateSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	if err := writeJSONPretty(filepath.Join(e.snapshotDir(), name+".json"), state); err != nil {
+		return nil, fmt.Errorf("save snapshot: %w", err)
+	}
+	return sortedKeys(state), nil
+}
+
+// RestoreSnapshot replaces the saved state with the named snapshot. Datasets
+// not present in the snapshot are reset to their seeds. The snapshot is
+// checked before anything changes, and a failed restore puts the previous
+// state back.
+func (e *Executor) RestoreSnapshot(name string) ([]string, error) {
+	if err := validStateName("snapshot", name); err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(filepath.Join(e.snapshotDir(), name+".json"))
+	if os.IsNotExist(err) {
+		return nil, &StepError{Status: 404, Msg: "snapshot not found: " + name}
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state map[string][]any
+	if err := json.Unmarshal(b, &state); err != nil {
+		return nil, fmt.Errorf("parse snapshot %s: %w", name, err)
+	}
+
+	for ds, records := range state {
+		if err := validStateName("dataset", ds); err != nil {
+			return nil, err
+		}
+		for i, r := range records {
+			if _, ok := toMap(r); !ok {
+				return nil, &StepError{Status: 400, Msg: fmt.Sprintf("snapshot %s: %s record %d is not an object", name, ds, i)}
+			}
+		}
+	}
+
+	e.stateMu.Lock()
+	defer e.stateMu.Unlock()
+
+	previous, err := e.stateSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	if err := e.replaceState(state); err != nil {
+		if rollbackErr := e.replaceState(previous); rollbackErr != nil {
+			return nil, fmt.Errorf("%w; rolling back also failed: %v", err, rollbackErr)
+		}
+		return nil, err
+	}
+	e.ids.ResetSequences()
+	return sortedKeys(state), nil
+}
+
+// replaceState makes the saved datasets exactly those of state.
+func (e *Executor) replaceState(state map[string][]any) error {
+	current, err := e.store.Datasets()
+	if err != nil {
+		return err
+	}
+	for _, ds := range current {
+		if _, keep := state[ds]; !keep {
+			if err := e.store.Delete(ds); err != nil {
+				return fmt.Errorf("restore %s: %w", ds, err)
+			}
+		}
+	}
+	for _, ds := range sortedKeys(state) {
+		if err := e.writeState(ds, state[ds]); err != nil {
+			return fmt.Errorf("restore %s: %w", ds, err)
+		}
+	}
+	return nil
+}
+
+// ListSnapshots returns the names of stored snapshots.
+func (e *Executor) ListSnapshots() ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(e.snapshotDir(), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(matches))
+	for _, m := range matches {
+		names = append(names, strings.TrimSuffix(filepath.Base(m), ".json"))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ExportDataset returns the records a flow would currently load for dataset.
+func (e *Executor) ExportDataset(dataset string) ([]any, error) {
+	if err := validStateName("dataset", dataset); err != nil {
+		return nil, err
+	}
+	e.stateMu.RLock()
+	defer e.stateMu.RUnlock()
+
+	records, ok := toSlice(e.loadDataset(dataset, ""))
+	if !ok {
+		return nil, fmt.Errorf("dataset %s is not an array", dataset)
+	}
+	return records, nil
+}
+
+// ImportDataset replaces the saved records of dataset.
+func (e *Executor) ImportDataset(dataset string, records []any) error {
+	if err := validStateName("dataset", dataset); err != nil {
+		return err
+	}
+	for i, r := range records {
+		if _, ok := toMap(r); !ok {
+			return &StepError{Status: 400, Msg: fmt.Sprintf("record %d is not an object", i)}
+		}
+	}
+	e.stateMu.Lock()
+	defer e.stateMu.Unlock()
+	if err := e.writeState(dataset, records); err != nil {
+		return err
+	}
+	e.ids.ResetSequences(dataset)
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// RegisterStateAdmin mounts dataset reset, snapshot, import/export and
+// record history endpoints on an authenticated admin group.
+func RegisterStateAdmin(g *gin.RouterGroup, e *Executor) {
+	g.POST("/state/reset", func(c *gin.Context) {
+		var body struct {
+			Datasets []string `json:"datasets"`
+		}
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&body); err != nil {
+				c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+		}
+		reset, err := e.ResetDatasets(body.Datasets...)
+		respondState(c, map[string]any{"reset": reset}, err)
+	})
+	g.GET("/snapshots", func(c *gin.Context) {
+		names, err := e.ListSnapshots()
+		respondState(c, map[string]any{"snapshots": names}, err)
+	})
+	g.PUT("/snapshots/:name", func(c *gin.Context) {
+		datasets, err := e.SaveSnapshot(c.Param("name"))
+		respondState(c, map[string]any{"snapshot": c.Param("name"), "datasets": datasets}, err)
+	})
+	g.POST("/snapshots/:name/restore", func(c *gin.Context) {
+		datasets, err := e.RestoreSnapshot(c.Param("name"))
+		respondState(c, map[string]any{"restored": c.Param("name"), "datasets": datasets}, err)
+	})
+	g.GET("/datasets/:dataset", func(c *gin.Context) {
+		records, err := e.ExportDataset(c.Param("dataset"))
+		if err != nil {
+			respondState(c, nil, err)
+			return
+		}
+		c.JSON(http.StatusOK, records)
+	})
+	g.PUT("/datasets/:dataset", func(c *gin.Context) {
+		var records []any
+		if err := c.ShouldBindJSON(&records); err != nil {
+			c.JSON(http.StatusBadRequest, map[string]string{"error": "body must be a JSON array of records"})
+			return
+		}
+		err := e.ImportDataset(c.Param("dataset"), records)
+		respondState(c, map[string]any{"dataset": c.Param("dataset"), "records": len(records)}, err)
+	})
+	registerHistoryAdmin(g, e)
+}
+
+func respondState(c *gin.Context, body map[string]any, err error) {
+	if err != nil {
+		status := http.StatusInternalServerError
+		if se, ok := err.(*StepError); ok {
+			status = se.Status
+		}
+		c.JSON(status, map[string]string{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, body)
+}
@@ -0,0 +1,156 @@
+package artifact
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestSeed(t *testing.T, repo, dataset, content string) {
+	t.Helper()
+	dir := filepath.Join(repo, "data")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "seed."+dataset+".v1.json"), []byte(content), 0o644))
+}
+
+func TestSnapshotResetRestore(t *testing.T) {
+	repo := t.TempDir()
+	writeTestSeed(t, repo, "users", `[{"id":"seed"}]`)
+	exec := NewExecutor(repo)
+
+	require.NoError(t, exec.ImportDataset("users", []any{map[string]any{"id": "a"}, map[string]any{"id": "b"}}))
+	require.NoError(t, exec.ImportDataset("orders", []any{map[string]any{"id": "o1"}}))
+
+	saved, err := exec.SaveSnapshot("two-users")
+	require.NoError(t, err)
+	require.Equal(t, []string{"orders", "users"}, saved)
+
+	reset, err := exec.ResetDatasets("users")
+	require.NoError(t, err)
+	require.Equal(t, []string{"users"}, reset)
+	records, err := exec.ExportDataset("users")
+	require.NoError(t, err)
+	require.Equal(t, []any{map[string]any{"id": "seed"}}, records)
+
+	_, err = exec.ResetDatasets()
+	require.NoError(t, err)
+	records, err = exec.ExportDataset("orders")
+	require.NoError(t, err)
+	require.Empty(t, records)
+
+	restored, err := exec.RestoreSnapshot("two-users")
+	require.NoError(t, err)
+	require.Equal(t, []string{"orders", "users"}, restored)
+	records, err = exec.ExportDataset("users")
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+
+	names, err := exec.ListSnapshots()
+	require.NoError(t, err)
+	require.Equal(t, []string{"two-users"}, names)
+}
+
+func TestStateOperationsRejectBadInput(t *testing.T) {
+	exec := NewExecutor(t.TempDir())
+
+	_, err := exec.RestoreSnapshot("missing")
+	require.Equal(t, 404, err.(*StepError).Status)
+
+	_, err = exec.SaveSnapshot("../escape")
+	require.Equal(t, 400, err.(*StepError).Status)
+
+	err = exec.ImportDataset("users", []any{"not-an-object"})
+	require.Equal(t, 400, err.(*StepError).Status)
+}
+
+// failingSaveStore refuses to save one dataset.
+type failingSaveStore struct {
+	*MemoryStore
+	dataset string
+}
+
+func (s *failingSaveStore) Save(dataset string, records []any) error {
+	if dataset == s.dataset {
+		return errors.New("disk full")
+	}
+	return s.MemoryStore.Save(dataset, records)
+}
+
+func TestRestoreSnapshotChangesNothingOnFailure(t *testing.T) {
+	repo := t.TempDir()
+	store := &failingSaveStore{MemoryStore: NewMemoryStore(map[string][]any{
+		"users": {map[string]any{"id": "live"}},
+		"tags":  {map[string]any{"id": "t1"}},
+	})}
+	exec := NewExecutor(repo, WithStore(store))
+	dir := exec.snapshotDir()
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name+".json"), []byte(content), 0o644))
+	}
+	before, err := exec.stateSnapshot()
+	require.NoError(t, err)
+
+	write("escape", `{"users": [{"id": "a"}], "../../etc": [{"id": "x"}]}`)
+	_, err = exec.RestoreSnapshot("escape")
+	require.Equal(t, 400, err.(*StepError).Status)
+
+	store.dataset = "users"
+	write("partial", `{"orders": [{"id": "o1"}], "users": [{"id": "a"}]}`)
+	_, err = exec.RestoreSnapshot("partial")
+	require.ErrorContains(t, err, "restore users: disk full")
+
+	after, err := exec.stateSnapshot()
+	require.NoError(t, err)
+	require.Equal(t, before, after, "tags is back and orders is not left behind")
+}
+
+func TestRestoreIsAtomicForInFlightFlows(t *testing.T) {
+	repo := t.TempDir()
+	writeTestFlow(t, repo, "count.flow.yaml", `
+version: 1
+steps:
+  - op: loadDataset
+    args: { dataset: a }
+    out: a
+  - op: loadDataset
+    args: { dataset: b }
+    out: b
+  - op: respond
+    args:
+      status: 200
+      bodyFrom: "$ctx"
+`)
+	exec := NewExecutor(repo, WithStore(NewMemoryStore(nil)))
+	one := []any{map[string]any{"id": "1"}}
+	two := []any{map[string]any{"id": "1"}, map[string]any{"id": "2"}}
+	require.NoError(t, exec.ImportDataset("a", one))
+	require.NoError(t, exec.ImportDataset("b", one))
+	_, err := exec.SaveSnapshot("one")
+	require.NoError(t, err)
+	require.NoError(t, exec.ImportDataset("a", two))
+	require.NoError(t, exec.ImportDataset("b", two))
+	_, err = exec.SaveSnapshot("two")
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			_, _ = exec.RestoreSnapshot([]string{"one", "two"}[i%2])
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		res, err := exec.Run(context.Background(), "count.flow.yaml", &ExecRequest{})
+		require.NoError(t, err)
+		body := res.Body.(map[string]any)
+		require.Equal(t, len(body["a"].([]any)), len(body["b"].([]any)), "flow saw a half-restored state")
+	}
+	wg.Wait()
+}
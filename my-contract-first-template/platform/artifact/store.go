@@ -0,0 +1,145 @@
+package artifact
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Store persists the records of each dataset between flow runs. Seeds are not
+// part of a Store; a dataset that was never saved falls back to its seed file.
+type Store interface {
+	// Load returns the records of dataset and whether it has been saved.
+	Load(dataset string) ([]any, bool, error)
+	// Save replaces the records of dataset.
+	Save(dataset string, records []any) error
+	// Delete drops the saved records of dataset so it falls back to its seed.
+	Delete(dataset string) error
+	// Datasets lists the saved datasets in name order.
+	Datasets() ([]string, error)
+}
+
+// FileStore keeps one pretty-printed JSON file per dataset in a directory,
+// by default <repo>/.runtime/state.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore returns a Store backed by JSON files in dir.
+func NewFileStore(dir string) *FileStore { return &FileStore{dir: dir} }
+
+func (s *FileStore) path(dataset string) string {
+	return filepath.Join(s.dir, dataset+".json")
+}
+
+// Load implements Store.
+func (s *FileStore) Load(dataset string) ([]any, bool, error) {
+	b, err := os.ReadFile(s.path(dataset))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var data []any
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// Save implements Store.
+func (s *FileStore) Save(dataset string, records []any) error {
+	if records == nil {
+		records = []any{}
+	}
+	return writeJSONPretty(s.path(dataset), records)
+}
+
+// Delete implements Store.
+func (s *FileStore) Delete(dataset string) error {
+	if err := os.Remove(s.path(dataset)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Datasets implements Store.
+func (s *FileStore) Datasets() ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(matches))
+	for _, m := range matches {
+		names = append(names, strings.TrimSuffix(filepath.Base(m), ".json"))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// MemoryStore is an in-process Store, used to run flows against isolated
+// state. Records are deep-copied in and out.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	data map[string][]any
+}
+
+// NewMemoryStore returns a MemoryStore pre-populated with initial.
+func NewMemoryStore(initial map[string][]any) *MemoryStore {
+	s := &MemoryStore{data: map[string][]any{}}
+	for k, v := range initial {
+		s.data[k] = copyRecords(v)
+	}
+	return s
+}
+
+// Load implements Store.
+func (s *MemoryStore) Load(dataset string) ([]any, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.data[dataset]
+	if !ok {
+		return nil, false, nil
+	}
+	return copyRecords(v), true, nil
+}
+
+// Save implements Store.
+func (s *MemoryStore) Save(dataset string, records []any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[dataset] = copyRecords(records)
+	return nil
+}
+
+// Delete implements Store.
+func (s *MemoryStore) Delete(dataset string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, dataset)
+	return nil
+}
+
+// Datasets implements Store.
+func (s *MemoryStore) Datasets() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names := make([]string, 0, len(s.data))
+	for k := range s.data {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func copyRecords(records []any) []any {
+	out, ok := toSlice(deepCopy(records))
+	if !ok {
+		return []any{}
+	}
+	return out
+}
@@ -0,0 +1,182 @@
+package artifact
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TenantHeader selects a tenant explicitly when the tenants file does not
+// name another header.
+const TenantHeader = "X-Artifact-Tenant"
+
+// TenantsConfig lets one gateway host several contract repos. Each tenant
+// is served by its own registry, executor and dataset state.
+type TenantsConfig struct {
+	// Header names the request header that selects a tenant by name.
+	Header string `yaml:"header"`
+	// Default serves requests that match no tenant. Empty answers 404.
+	Default string         `yaml:"default"`
+	Tenants []TenantConfig `yaml:"tenants"`
+}
+
+// TenantConfig describes one tenant. Repo is resolved relative to the
+// tenants file. Tokens are expanded from the environment so secrets can stay
+// out of the file, e.g. adminToken: ${TEAM_A_ADMIN_TOKEN}.
+type TenantConfig struct {
+	Name       string   `yaml:"name"`
+	Repo       string   `yaml:"repo"`
+	BasePath   string   `yaml:"basePath"`
+	Hosts      []string `yaml:"hosts"`
+	PathPrefix string   `yaml:"pathPrefix"`
+	AdminToken string   `yaml:"adminToken"`
+	RepoToken  string   `yaml:"repoToken"`
+}
+
+// LoadTenants reads and validates a tenants file.
+func LoadTenants(path string) (*TenantsConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &TenantsConfig{}
+	if err := yaml.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if cfg.Header == "" {
+		cfg.Header = TenantHeader
+	}
+	if len(cfg.Tenants) == 0 {
+		return nil, fmt.Errorf("%s: no tenants defined", path)
+	}
+
+	names := map[string]bool{}
+	hosts := map[string]string{}
+	prefixes := map[string]string{}
+	for i := range cfg.Tenants {
+		t := &cfg.Tenants[i]
+		if err := validStateName("tenant", t.Name); err != nil {
+			return nil, err
+		}
+		if names[t.Name] {
+			return nil, fmt.Errorf("tenant %s defined twice", t.Name)
+		}
+		names[t.Name] = true
+		if t.Repo == "" {
+			return nil, fmt.Errorf("tenant %s: repo is required", t.Name)
+		}
+		if !filepath.IsAbs(t.Repo) {
+			t.Repo = filepath.Join(filepath.Dir(path), t.Repo)
+		}
+		for j, h := range t.Hosts {
+			h = strings.ToLower(h)
+			if other, dup := hosts[h]; dup {
+				return nil, fmt.Errorf("host %s claimed by tenants %s and %s", h, other, t.Name)
+			}
+			hosts[h] = t.Name
+			t.Hosts[j] = h
+		}
+		if t.PathPrefix != "" {
+			t.PathPrefix = "/" + strings.Trim(t.PathPrefix, "/")
+			if other, dup := prefixes[t.PathPrefix]; dup {
+				return nil, fmt.Errorf("path prefix %s claimed by tenants %s and %s", t.PathPrefix, other, t.Name)
+			}
+			prefixes[t.PathPrefix] = t.Name
+		}
+		t.AdminToken = os.ExpandEnv(t.AdminToken)
+		t.RepoToken = os.ExpandEnv(t.RepoToken)
+	}
+	if cfg.Default != "" && !names[cfg.Default] {
+		return nil, fmt.Errorf("default tenant %s is not defined", cfg.Default)
+	}
+	return cfg, nil
+}
+
+// TenantRouter dispatches requests to the handler of the selected tenant.
+// A path prefix wins over the host name, which wins over the tenant header;
+// the prefix is stripped before the tenant handler sees the request.
+type TenantRouter struct {
+	header   string
+	fallback string
+	handlers map[string]http.Handler
+	hosts    map[string]string
+	prefixes []TenantConfig
+}
+
+// NewTenantRouter builds a router from cfg and one handler per tenant.
+func NewTenantRouter(cfg *TenantsConfig, handlers map[string]http.Handler) (*TenantRouter, error) {
+	tr := &TenantRouter{
+		header:   cfg.Header,
+		fallback: cfg.Default,
+		handlers: handlers,
+		hosts:    map[string]string{},
+	}
+	for _, t := range cfg.Tenants {
+		if handlers[t.Name] == nil {
+			return nil, fmt.Errorf("no handler for tenant %s", t.Name)
+		}
+		for _, h := range t.Hosts {
+			tr.hosts[h] = t.Name
+		}
+		if t.PathPrefix != "" {
+			tr.prefixes = append(tr.prefixes, t)
+		}
+	}
+	// Longest prefix first so /team-a-beta is not captured by /team-a.
+	sort.Slice(tr.prefixes, func(i, j int) bool {
+		return len(tr.prefixes[i].PathPrefix) > len(tr.prefixes[j].PathPrefix)
+	})
+	return tr, nil
+}
+
+// Select returns the tenant for r and the path it should be served under.
+func (tr *TenantRouter) Select(r *http.Request) (tenant, path string, ok bool) {
+	p := r.URL.Path
+	for _, t := range tr.prefixes {
+		if p == t.PathPrefix || strings.HasPrefix(p, t.PathPrefix+"/") {
+			rest := strings.TrimPrefix(p, t.PathPrefix)
+			if rest == "" {
+				rest = "/"
+			}
+			return t.Name, rest, true
+		}
+	}
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if name, found := tr.hosts[strings.ToLower(host)]; found {
+		return name, p, true
+	}
+	if name := r.Header.Get(tr.header); name != "" {
+		_, found := tr.handlers[name]
+		return name, p, found
+	}
+	if tr.fallback != "" {
+		return tr.fallback, p, true
+	}
+	return "", p, false
+}
+
+func (tr *TenantRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, path, ok := tr.Select(r)
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":"unknown tenant"}`))
+		return
+	}
+	if path != r.URL.Path {
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = path
+		r2.URL.RawPath = ""
+		r = r2
+	}
+	tr.handlers[name].ServeHTTP(w, r)
+}
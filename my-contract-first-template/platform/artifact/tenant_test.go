@@ -0,0 +1,154 @@
+package artifact
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTenantsFile(t *testing.T, dir, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, "tenants.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestLoadTenants(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TEAM_A_TOKEN", "secret-a")
+	cfg, err := LoadTenants(writeTenantsFile(t, dir, `
+default: team-a
+tenants:
+  - name: team-a
+    repo: repos/a
+    hosts: [A.example.com]
+    pathPrefix: team-a/
+    adminToken: ${TEAM_A_TOKEN}
+  - name: team-b
+    repo: /srv/b
+`))
+	require.NoError(t, err)
+	require.Equal(t, TenantHeader, cfg.Header)
+	require.Equal(t, filepath.Join(dir, "repos/a"), cfg.Tenants[0].Repo)
+	require.Equal(t, "/srv/b", cfg.Tenants[1].Repo)
+	require.Equal(t, []string{"a.example.com"}, cfg.Tenants[0].Hosts)
+	require.Equal(t, "/team-a", cfg.Tenants[0].PathPrefix)
+	require.Equal(t, "secret-a", cfg.Tenants[0].AdminToken)
+
+	for name, content := range map[string]string{
+		"duplicate name":    "tenants: [{name: a, repo: x}, {name: a, repo: y}]",
+		"shared host":       "tenants: [{name: a, repo: x, hosts: [h]}, {name: b, repo: y, hosts: [h]}]",
+		"shared prefix":     "tenants: [{name: a, repo: x, pathPrefix: /p}, {name: b, repo: y, pathPrefix: p/}]",
+		"missing repo":      "tenants: [{name: a}]",
+		"unknown default":   "default: c\ntenants: [{name: a, repo: x}]",
+		"traversal in name": "tenants: [{name: ../a, repo: x}]",
+	} {
+		_, err := LoadTenants(writeTenantsFile(t, dir, content))
+		require.Error(t, err, name)
+	}
+}
+
+func TestTenantRouterSelect(t *testing.T) {
+	cfg := &TenantsConfig{Header: TenantHeader, Tenants: []TenantConfig{
+		{Name: "a", PathPrefix: "/team"},
+		{Name: "b", PathPrefix: "/team-b", Hosts: []string{"b.example.com"}},
+		{Name: "c"},
+	}}
+	noop := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	tr, err := NewTenantRouter(cfg, map[string]http.Handler{"a": noop, "b": noop, "c": noop})
+	require.NoError(t, err)
+
+	cases := []struct {
+		target, host, header string
+		tenant, path         string
+		ok                   bool
+	}{
+		{"/team/v1/users", "", "", "a", "/v1/users", true},
+		{"/team-b/v1/users", "", "", "b", "/v1/users", true},
+		{"/teams/v1/users", "", "", "", "/teams/v1/users", false},
+		{"/v1/users", "B.example.com:8787", "", "b", "/v1/users", true},
+		{"/v1/users", "", "c", "c", "/v1/users", true},
+		{"/v1/users", "", "nope", "nope", "/v1/users", false},
+		{"/team/v1/users", "", "c", "a", "/v1/users", true},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest(http.MethodGet, tc.target, nil)
+		if tc.host != "" {
+			r.Host = tc.host
+		}
+		if tc.header != "" {
+			r.Header.Set(TenantHeader, tc.header)
+		}
+		tenant, path, ok := tr.Select(r)
+		require.Equal(t, tc.ok, ok, tc.target)
+		if ok {
+			require.Equal(t, tc.tenant, tenant, tc.target)
+			require.Equal(t, tc.path, path, tc.target)
+		}
+	}
+}
+
+func TestTenantsDoNotShareState(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handlers := map[string]http.Handler{}
+	cfg := &TenantsConfig{Header: TenantHeader}
+	for _, name := range []string{"a", "b"} {
+		repo := t.TempDir()
+		writeTestFlow(t, repo, "notes.flow.yaml", recordFlow)
+		writeTestFlow(t, repo, "list.flow.yaml", `
+version: 1
+steps:
+  - op: loadDataset
+    args: { dataset: notes }
+    out: notes
+  - op: respond
+    args:
+      status: 200
+      bodyFrom: "$ctx.notes"
+`)
+		exec := NewExecutor(repo)
+		r := gin.New()
+		r.POST("/notes", func(c *gin.Context) {
+			req, err := NewExecRequestFromGin(c, RequestOptions{})
+			require.NoError(t, err)
+			res, err := exec.Run(c.Request.Context(), "notes.flow.yaml", req)
+			require.NoError(t, err)
+			c.Data(res.Status, "application/json", res.BodyJSON())
+		})
+		r.GET("/notes", func(c *gin.Context) {
+			res, err := exec.Run(c.Request.Context(), "list.flow.yaml", &ExecRequest{})
+			require.NoError(t, err)
+			c.Data(res.Status, "application/json", res.BodyJSON())
+		})
+		handlers[name] = r
+		cfg.Tenants = append(cfg.Tenants, TenantConfig{Name: name, Repo: repo, PathPrefix: "/" + name})
+	}
+	router, err := NewTenantRouter(cfg, handlers)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/a/notes", strings.NewReader(`{"id":"only-in-a"}`)))
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	list := func(tenant string) []any {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+tenant+"/notes", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		var notes []any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &notes))
+		return notes
+	}
+	require.Len(t, list("a"), 1)
+	require.Empty(t, list("b"))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/c/notes", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
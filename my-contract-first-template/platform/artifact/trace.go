@@ -0,0 +1,226 @@
+package artifact
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DebugHeader opts a single request into step tracing when the gateway has
+// debugging enabled.
+const DebugHeader = "X-Artifact-Debug"
+
+// TraceIDHeader carries the ID under which a recorded trace can be fetched.
+const TraceIDHeader = "X-Artifact-Trace-Id"
+
+// ExecTrace is the step-by-step record of one flow execution.
+type ExecTrace struct {
+	ID         string       `json:"id"`
+	Flow       string       `json:"flow"`
+	Method     string       `json:"method,omitempty"`
+	Path       string       `json:"path,omitempty"`
+	StartedAt  time.Time    `json:"startedAt"`
+	DurationMs float64      `json:"durationMs"`
+	Status     int          `json:"status"`
+	StepID     string       `json:"stepId,omitempty"`
+	Error      string       `json:"error,omitempty"`
+	Steps      []*StepTrace `json:"steps"`
+
+	started time.Time
+}
+
+// StepTrace records what a single step saw and produced.
+type StepTrace struct {
+	ID         string         `json:"id,omitempty"`
+	Index      int            `json:"index"`
+	Op         string         `json:"op"`
+	When       string         `json:"when,omitempty"`
+	Matched    bool           `json:"matched"`
+	Args       map[string]any `json:"args,omitempty"`
+	Out        string         `json:"out,omitempty"`
+	Output     any            `json:"output,omitempty"`
+	DurationMs float64        `json:"durationMs"`
+	Error      string         `json:"error,omitempty"`
+
+	started  time.Time
+	redactor *Redactor
+}
+
+type traceKey struct{}
+
+// NewExecTrace starts an empty trace for the given flow and request.
+func NewExecTrace(flowFile string, req *ExecRequest) *ExecTrace {
+	now := time.Now()
+	t := &ExecTrace{
+		ID:        fmt.Sprintf("tr_%d", now.UnixNano()),
+		Flow:      flowFile,
+		StartedAt: now.UTC(),
+		Steps:     []*StepTrace{},
+		started:   now,
+	}
+	if req != nil {
+		t.Method = req.Method
+		t.Path = req.Path
+	}
+	return t
+}
+
+// WithTrace returns a context that makes Executor.Run record into t.
+func WithTrace(ctx context.Context, t *ExecTrace) context.Context {
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+func traceFromContext(ctx context.Context) *ExecTrace {
+	t, _ := ctx.Value(traceKey{}).(*ExecTrace)
+	return t
+}
+
+func (t *ExecTrace) beginStep(index int, step FlowStep, redactor *Redactor) *StepTrace {
+	if t == nil {
+		return nil
+	}
+	st := &StepTrace{
+		ID:       step.ID,
+		Index:    index,
+		Op:       step.Op,
+		When:     step.When,
+		Out:      step.Out,
+		Matched:  true,
+		started:  time.Now(),
+		redactor: redactor,
+	}
+	t.Steps = append(t.Steps, st)
+	return st
+}
+
+func (t *ExecTrace) finish(res *ExecResponse, err error) {
+	if t == nil {
+		return
+	}
+	t.DurationMs = msSince(t.started)
+	if res != nil {
+		t.Status = res.Status
+	}
+	if err != nil {
+		t.Status = 500
+		t.Error = err.Error()
+		if se, ok := err.(*StepError); ok {
+			t.Status = se.Status
+			t.StepID = se.StepID
+		}
+	}
+}
+
+func (s *StepTrace) skip() {
+	if s == nil {
+		return
+	}
+	s.Matched = false
+	s.DurationMs = msSince(s.started)
+}
+
+func (s *StepTrace) resolve(args map[string]any, rt map[string]any) {
+	if s == nil || len(args) == 0 {
+		return
+	}
+	resolved := make(map[string]any, len(args))
+	for k, v := range args {
+		resolved[k] = deepCopy(resolveExpr(rt, v))
+	}
+	s.Args, _ = s.redactor.Trace(resolved).(map[string]any)
+}
+
+func (s *StepTrace) end(out any, err error) {
+	if s == nil {
+		return
+	}
+	s.DurationMs = msSince(s.started)
+	if err != nil {
+		s.Error = err.Error()
+		return
+	}
+	if s.Out != "" {
+		s.Output = s.redactor.Trace(deepCopy(out))
+	}
+}
+
+// resolveExpr replaces every "$path" string inside v with the value it
+// points at, leaving literals untouched.
+func resolveExpr(rt map[string]any, v any) any {
+	switch t := v.(type) {
+	case string:
+		return getExpr(rt, t, nil)
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, item := range t {
+			out[k] = resolveExpr(rt, item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(t))
+		for i, item := range t {
+			out[i] = resolveExpr(rt, item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func msSince(t time.Time) float64 {
+	return float64(time.Since(t).Microseconds()) / 1000
+}
+
+// TraceBuffer keeps the most recent traces in a fixed-size ring.
+type TraceBuffer struct {
+	mu    sync.RWMutex
+	items []*ExecTrace
+	next  int
+	full  bool
+}
+
+// NewTraceBuffer creates a ring holding up to size traces.
+func NewTraceBuffer(size int) *TraceBuffer {
+	if size <= 0 {
+		size = 100
+	}
+	return &TraceBuffer{items: make([]*ExecTrace, size)}
+}
+
+// Add stores t, evicting the oldest trace once the ring is full.
+func (b *TraceBuffer) Add(t *ExecTrace) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.items[b.next] = t
+	b.next = (b.next + 1) % len(b.items)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// List returns the buffered traces, newest first.
+func (b *TraceBuffer) List() []*ExecTrace {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	n := b.next
+	if b.full {
+		n = len(b.items)
+	}
+	out := make([]*ExecTrace, 0, n)
+	for i := 1; i <= n; i++ {
+		idx := (b.next - i + len(b.items)) % len(b.items)
+		out = append(out, b.items[idx])
+	}
+	return out
+}
+
+// Get returns the trace with the given ID, if it is still buffered.
+func (b *TraceBuffer) Get(id string) (*ExecTrace, bool) {
+	for _, t := range b.List() {
+		if t.ID == id {
+			return t, true
+		}
+	}
+	return nil, false
+}
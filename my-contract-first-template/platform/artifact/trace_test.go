@@ -0,0 +1,109 @@
+package artifact
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestFlow(t *testing.T, repo, name, content string) {
+	t.Helper()
+	dir := filepath.Join(repo, "flows")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+}
+
+func TestRunRecordsStepTrace(t *testing.T) {
+	repo := t.TempDir()
+	writeTestFlow(t, repo, "greet.flow.yaml", `
+version: 1
+name: Greet
+steps:
+  - id: name
+    op: set
+    args:
+      path: "$ctx.name"
+      value: "$request.query.name"
+  - id: skipped
+    op: respond
+    when: "$ctx.missing"
+    args:
+      status: 500
+  - id: reply
+    op: respond
+    args:
+      status: 200
+      bodyFrom: "$ctx"
+`)
+
+	req := &ExecRequest{Method: "GET", Path: "/greet", Query: map[string][]string{"name": {"alice"}}}
+	trace := NewExecTrace("greet.flow.yaml", req)
+	res, err := NewExecutor(repo).Run(WithTrace(context.Background(), trace), "greet.flow.yaml", req)
+	require.NoError(t, err)
+	require.Equal(t, 200, res.Status)
+
+	require.Equal(t, 200, trace.Status)
+	require.Len(t, trace.Steps, 3)
+	require.Equal(t, "alice", trace.Steps[0].Args["value"])
+	require.False(t, trace.Steps[1].Matched)
+	require.Nil(t, trace.Steps[1].Args)
+	require.True(t, trace.Steps[2].Matched)
+}
+
+func TestRunTraceCapturesFailingStep(t *testing.T) {
+	repo := t.TempDir()
+	writeTestFlow(t, repo, "broken.flow.yaml", `
+version: 1
+steps:
+  - id: bad
+    op: doesNotExist
+`)
+
+	trace := NewExecTrace("broken.flow.yaml", nil)
+	_, err := NewExecutor(repo).Run(WithTrace(context.Background(), trace), "broken.flow.yaml", &ExecRequest{})
+	require.Error(t, err)
+	require.Equal(t, 500, trace.Status)
+	require.Equal(t, "bad", trace.StepID)
+	require.Equal(t, "unknown op: doesNotExist", trace.Steps[0].Error)
+}
+
+func TestTraceBufferEvictsOldest(t *testing.T) {
+	buf := NewTraceBuffer(2)
+	for _, id := range []string{"a", "b", "c"} {
+		buf.Add(&ExecTrace{ID: id})
+	}
+
+	list := buf.List()
+	require.Len(t, list, 2)
+	require.Equal(t, "c", list[0].ID)
+	require.Equal(t, "b", list[1].ID)
+
+	_, ok := buf.Get("a")
+	require.False(t, ok)
+}
+
+func TestRunTraceRedactsArgsAndOutput(t *testing.T) {
+	repo := t.TempDir()
+	writeTestFlow(t, repo, "login.flow.yaml", `
+steps:
+  - op: set
+    args: { path: "$ctx.login", value: "$request.body" }
+  - op: set
+    args: { path: "$ctx.headers", value: "$request.headers" }
+  - op: respond
+    args: { status: 204 }
+`)
+	req := &ExecRequest{
+		Method:  "POST",
+		Headers: map[string][]string{"Authorization": {"Bearer s3cr3t"}, "Accept": {"*/*"}},
+		Body:    map[string]any{"user": "ann", "password": "hunter2"},
+	}
+	trace := NewExecTrace("login.flow.yaml", req)
+	_, err := NewExecutor(repo).Run(WithTrace(context.Background(), trace), "login.flow.yaml", req)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"user": "ann", "password": redactedValue}, trace.Steps[0].Args["value"])
+	require.Equal(t, map[string]any{"Authorization": redactedValue, "Accept": "*/*"}, trace.Steps[1].Args["value"])
+}
@@ -3,6 +3,7 @@ package artifact
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -11,6 +12,15 @@ type Registry struct {
 	Version   string        `json:"version"`
 	BasePath  string        `json:"basePath"`
 	Endpoints []EndpointDef `json:"endpoints"`
+	Schedules []ScheduleDef `json:"schedules,omitempty"`
+	// Versions declares the API versions endpoints may belong to;
+	// DefaultVersion answers requests without Accept-Version.
+	Versions       []APIVersion `json:"versions,omitempty"`
+	DefaultVersion string       `json:"defaultVersion,omitempty"`
+	// Policy sets CORS and security headers for every endpoint.
+	Policy *HTTPPolicy `json:"policy,omitempty"`
+	// Datasets opts datasets into history and soft delete.
+	Datasets map[string]DatasetDef `json:"datasets,omitempty"`
 }
 
 type EndpointDef struct {
@@ -18,8 +28,31 @@ type EndpointDef struct {
 	Method string `json:"method"`
 	Path   string `json:"path"`
 	Flow   string `json:"flow"`
+	// MaxBodyBytes overrides the gateway's request body limit.
+	MaxBodyBytes int64 `json:"maxBodyBytes,omitempty"`
+	// Type is empty for flow endpoints. "stream" serves dataset change
+	// events as SSE, limited to Dataset and Filter when set. "event" runs
+	// Flow for every event published on Topic.
+	Type    string `json:"type,omitempty"`
+	Dataset string `json:"dataset,omitempty"`
+	Filter  string `json:"filter,omitempty"`
+	Topic   string `json:"topic,omitempty"`
+	// Faults are injected when the gateway runs in fault injection mode.
+	Faults *FaultRule `json:"faults,omitempty"`
+	// Version names the registry version the endpoint belongs to.
+	// Deprecated and Sunset announce its retirement and Replacement, an
+	// endpoint id or URL, points clients at its successor.
+	Version     string     `json:"version,omitempty"`
+	Deprecated  *time.Time `json:"deprecated,omitempty"`
+	Sunset      *time.Time `json:"sunset,omitempty"`
+	Replacement string     `json:"replacement,omitempty"`
+	// Policy overrides the registry's CORS or security block.
+	Policy *HTTPPolicy `json:"policy,omitempty"`
 }
 
+// EndpointStream marks an endpoint that streams dataset changes.
+const EndpointStream = "stream"
+
 type Flow struct {
 	Version     int        `json:"version" yaml:"version"`
 	Name        string     `json:"name" yaml:"name"`
@@ -42,20 +75,31 @@ type InlineAction struct {
 }
 
 type ExecRequest struct {
-	Method  string              `json:"method"`
-	Path    string              `json:"path"`
-	Params  map[string]string   `json:"params"`
-	Query   map[string][]string `json:"query"`
-	Headers map[string][]string `json:"headers"`
-	Body    map[string]any      `json:"body"`
-	Dataset map[string]any      `json:"dataset"`
+	RequestID string              `json:"requestId,omitempty"`
+	Method    string              `json:"method"`
+	Path      string              `json:"path"`
+	Params    map[string]string   `json:"params"`
+	Query     map[string][]string `json:"query"`
+	Headers   map[string][]string `json:"headers"`
+	// Body is the decoded request body: any JSON value, a form as a map, or
+	// text as a string.
+	Body    any                       `json:"body"`
+	Files   map[string][]UploadedFile `json:"files,omitempty"`
+	Dataset map[string]any            `json:"dataset"`
 }
 
-func NewExecRequestFromGin(c *gin.Context) (*ExecRequest, error) {
-	params := map[string]string{}
-	for _, p := range c.Params {
-		params[p.Key] = p.Value
-	}
+// RequestOptions controls how NewExecRequestFromGin builds an ExecRequest.
+type RequestOptions struct {
+	// Overrides governs the X-Artifact-Request header. Nil rejects it.
+	Overrides *OverridePolicy
+	// MaxBodyBytes limits the request body; zero means DefaultMaxBodyBytes.
+	MaxBodyBytes int64
+	// BlobDir stores multipart file parts. Empty rejects file uploads.
+	BlobDir string
+}
+
+func NewExecRequestFromGin(c *gin.Context, opts RequestOptions) (*ExecRequest, error) {
+	params := paramsOf(c)
 	queryValues := c.Request.URL.Query()
 	q := make(map[string][]string, len(queryValues))
 	for key, values := range queryValues {
@@ -75,24 +119,28 @@ func NewExecRequestFromGin(c *gin.Context) (*ExecRequest, error) {
 		copy(copied, v)
 		h[k] = copied
 	}
-	body, err := readRequestBodyMap(c.Request.Body)
+	body, files, err := decodeRequestBody(c.Request, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read request body: %w", err)
 	}
+	requestID := RequestIDFrom(c)
+	if requestID == "" {
+		requestID = c.GetHeader(RequestIDHeader)
+	}
 	req := &ExecRequest{
-		Method:  c.Request.Method,
-		Path:    c.FullPath(),
-		Params:  params,
-		Query:   q,
-		Headers: h,
-		Body:    body,
-		Dataset: map[string]any{},
+		RequestID: requestID,
+		Method:    c.Request.Method,
+		Path:      c.FullPath(),
+		Params:    params,
+		Query:     q,
+		Headers:   h,
+		Body:      body,
+		Files:     files,
+		Dataset:   map[string]any{},
 	}
 
-	if overrideHeader := c.GetHeader("X-Artifact-Request"); overrideHeader != "" {
-		if err := applyRequestOverrides(req, overrideHeader); err != nil {
-			return nil, err
-		}
+	if err := applyOverridePolicy(c.Request, req, opts.Overrides); err != nil {
+		return nil, err
 	}
 
 	return req, nil
@@ -102,6 +150,11 @@ type ExecResponse struct {
 	Status  int               `json:"status"`
 	Headers map[string]string `json:"headers"`
 	Body    any               `json:"body"`
+	// Format selects how Render serialises Body; empty means JSON.
+	Format      string   `json:"format,omitempty"`
+	ContentType string   `json:"contentType,omitempty"`
+	Columns     []string `json:"columns,omitempty"`
+	XMLRoot     string   `json:"xmlRoot,omitempty"`
 }
 
 func (r *ExecResponse) BodyJSON() []byte {
@@ -117,6 +170,14 @@ type StepError struct {
 
 func (e *StepError) Error() string { return e.Msg }
 
+// ResponseForError renders a Run error the way the gateway returns it.
+func ResponseForError(err error) *ExecResponse {
+	if stepErr, ok := err.(*StepError); ok {
+		return &ExecResponse{Status: stepErr.Status, Body: map[string]any{"error": stepErr.Msg, "stepId": stepErr.StepID}}
+	}
+	return &ExecResponse{Status: 500, Body: map[string]any{"error": err.Error()}}
+}
+
 func applyRequestOverrides(req *ExecRequest, raw string) error {
 	var payload map[string]any
 	if err := json.Unmarshal([]byte(raw), &payload); err != nil {
@@ -178,12 +239,14 @@ func applyRequestOverrides(req *ExecRequest, raw string) error {
 		if !ok {
 			return fmt.Errorf("invalid X-Artifact-Request header: body must be an object")
 		}
-		if req.Body == nil {
-			req.Body = map[string]any{}
+		merged, ok := req.Body.(map[string]any)
+		if !ok {
+			merged = map[string]any{}
 		}
 		for k, v := range bodyMap {
-			req.Body[k] = v
+			merged[k] = v
 		}
+		req.Body = merged
 	}
 	if datasetRaw, ok := payload["dataset"]; ok {
 		datasetMap, ok := datasetRaw.(map[string]any)
@@ -19,7 +19,7 @@ func TestNewExecRequestFromGinCopiesHeadersAndQuery(t *testing.T) {
 	ctx.Request = req
 	ctx.Params = gin.Params{{Key: "id", Value: "123"}}
 
-	execReq, err := NewExecRequestFromGin(ctx)
+	execReq, err := NewExecRequestFromGin(ctx, RequestOptions{})
 	require.NoError(t, err)
 	require.Equal(t, []string{"bar", "baz"}, execReq.Query["foo"])
 	require.Equal(t, []string{"value"}, execReq.Headers["X-Test"])
@@ -59,7 +59,7 @@ func TestApplyRequestOverridesValidation(t *testing.T) {
 	require.Equal(t, "POST", base.Method)
 	require.Equal(t, "b", base.Query["q"][0])
 	require.Equal(t, "override", base.Headers["X-Test"][0])
-	require.Equal(t, "value", base.Body["key"])
+	require.Equal(t, "value", base.Body.(map[string]any)["key"])
 
 	// invalid query should return error
 	err = applyRequestOverrides(base, `{"query": []}`)
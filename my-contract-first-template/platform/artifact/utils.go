@@ -1,7 +1,6 @@
 package artifact
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -103,6 +102,14 @@ func queryToSimple(q map[string][]string) map[string]any {
 	return out
 }
 
+func stringMapToAny(m map[string]string) map[string]any {
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
 func getExpr(rt map[string]any, v any, def any) any {
 	if s, ok := v.(string); ok && strings.HasPrefix(s, "$") {
 		got := getByPath(rt, toPath(s))
@@ -140,7 +147,31 @@ func writeJSONPretty(path string, v any) error {
 	if err != nil {
 		return fmt.Errorf("marshal: %w", err)
 	}
-	if err := os.WriteFile(path, b, 0o644); err != nil {
+	// Write to a sibling temp file and rename so readers never observe a
+	// partially written file.
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("write file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write file: %w", err)
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write file: %w", err)
+	}
+	// Sync before the rename so a crash right after it cannot leave an
+	// empty file in place of the old state.
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
 		return fmt.Errorf("write file: %w", err)
 	}
 	return nil
@@ -204,40 +235,6 @@ func CleanJoin(base, p string) string {
 	return b + "/" + pp
 }
 
-func readRequestBodyMap(r io.Reader) (map[string]any, error) {
-	if r == nil {
-		return map[string]any{}, nil
-	}
-
-	var (
-		data []byte
-		err  error
-	)
-
-	switch rc := r.(type) {
-	case io.ReadCloser:
-		defer rc.Close()
-		data, err = io.ReadAll(rc)
-	default:
-		data, err = io.ReadAll(r)
-	}
-	if err != nil {
-		return map[string]any{}, err
-	}
-	if len(bytes.TrimSpace(data)) == 0 {
-		return map[string]any{}, nil
-	}
-
-	var m map[string]any
-	if err := json.Unmarshal(data, &m); err != nil {
-		return map[string]any{}, err
-	}
-	if m == nil {
-		m = map[string]any{}
-	}
-	return m, nil
-}
-
 func normalizeStrings(v any) []string {
 	switch val := v.(type) {
 	case nil:
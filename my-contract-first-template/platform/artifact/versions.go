@@ -0,0 +1,212 @@
+package artifact
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AcceptVersionHeader picks one of several endpoint versions served on the
+// same route; APIVersionHeader tells the client which one answered.
+const (
+	AcceptVersionHeader = "Accept-Version"
+	APIVersionHeader    = "API-Version"
+)
+
+// APIVersion declares a version endpoints may belong to. When BasePath is
+// set the version's endpoints are also mounted under it, e.g. /v2.
+type APIVersion struct {
+	Name     string `json:"name"`
+	BasePath string `json:"basePath,omitempty"`
+}
+
+// Route is a method and path served by one or more endpoint versions.
+// Endpoints[0] answers requests without Accept-Version.
+type Route struct {
+	Method    string
+	Path      string
+	Endpoints []EndpointDef
+}
+
+// Routes groups the flow endpoints by method and mount path. Every endpoint
+// is mounted under basePath, where endpoints sharing a method and path are
+// told apart by Accept-Version; an endpoint whose version has a base path is
+// mounted there as well. The default of a route is its unversioned
+// endpoint, else the registry's DefaultVersion, else the first declared
+// version it serves.
+func (r *Registry) Routes(basePath string) ([]Route, error) {
+	rank := map[string]int{}
+	versionBase := map[string]string{}
+	for i, v := range r.Versions {
+		if v.Name == "" {
+			return nil, fmt.Errorf("versions[%d]: name is required", i)
+		}
+		if _, dup := rank[v.Name]; dup {
+			return nil, fmt.Errorf("version %s declared twice", v.Name)
+		}
+		rank[v.Name] = i
+		versionBase[v.Name] = v.BasePath
+	}
+	if r.DefaultVersion != "" {
+		if _, ok := rank[r.DefaultVersion]; !ok {
+			return nil, fmt.Errorf("defaultVersion %s is not declared in versions", r.DefaultVersion)
+		}
+		rank[r.DefaultVersion] = -1
+	}
+
+	var routes []*Route
+	byKey := map[string]*Route{}
+	mount := func(ep EndpointDef, base string) error {
+		method := strings.ToUpper(ep.Method)
+		p := CleanJoin(base, ep.Path)
+		key := method + " " + p
+		rt, ok := byKey[key]
+		if !ok {
+			rt = &Route{Method: method, Path: p}
+			byKey[key] = rt
+			routes = append(routes, rt)
+		}
+		for _, other := range rt.Endpoints {
+			if other.ID == ep.ID {
+				return nil
+			}
+			if other.Version == ep.Version {
+				return fmt.Errorf("%s is served by both %s and %s", key, other.ID, ep.ID)
+			}
+		}
+		rt.Endpoints = append(rt.Endpoints, ep)
+		return nil
+	}
+	for _, ep := range r.Endpoints {
+		if ep.Type != "" {
+			continue
+		}
+		if ep.Version != "" {
+			if _, ok := versionBase[ep.Version]; !ok {
+				return nil, fmt.Errorf("endpoint %s: version %s is not declared in versions", ep.ID, ep.Version)
+			}
+		}
+		if err := mount(ep, basePath); err != nil {
+			return nil, err
+		}
+		if vb := versionBase[ep.Version]; vb != "" {
+			if err := mount(ep, vb); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	out := make([]Route, 0, len(routes))
+	for _, rt := range routes {
+		sort.SliceStable(rt.Endpoints, func(i, j int) bool {
+			return versionRank(rank, rt.Endpoints[i]) < versionRank(rank, rt.Endpoints[j])
+		})
+		out = append(out, *rt)
+	}
+	return out, nil
+}
+
+func versionRank(rank map[string]int, ep EndpointDef) int {
+	if ep.Version == "" {
+		return -2
+	}
+	return rank[ep.Version]
+}
+
+// Select returns the endpoint for an Accept-Version value. Routes without
+// versioned endpoints ignore the header; an unknown version is a 406.
+func (rt Route) Select(acceptVersion string) (EndpointDef, error) {
+	acceptVersion = strings.TrimSpace(acceptVersion)
+	if acceptVersion == "" || !rt.Versioned() {
+		return rt.Endpoints[0], nil
+	}
+	available := make([]string, 0, len(rt.Endpoints))
+	for _, ep := range rt.Endpoints {
+		if ep.Version == acceptVersion {
+			return ep, nil
+		}
+		if ep.Version != "" {
+			available = append(available, ep.Version)
+		}
+	}
+	return EndpointDef{}, &StepError{
+		Status: http.StatusNotAcceptable,
+		Msg:    fmt.Sprintf("version %s is not served; available: %s", acceptVersion, strings.Join(available, ", ")),
+	}
+}
+
+// Versioned reports whether any endpoint of the route declares a version.
+func (rt Route) Versioned() bool {
+	for _, ep := range rt.Endpoints {
+		if ep.Version != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// LifecycleOptions configures Lifecycle. Successor is the URL of the
+// replacement endpoint, if any.
+type LifecycleOptions struct {
+	Successor     string
+	EnforceSunset bool
+	Clock         Clock
+}
+
+// Lifecycle sets the version and deprecation headers of ep: Deprecation
+// (RFC 9745), Sunset (RFC 8594) and a successor-version Link. With
+// EnforceSunset, calls after the sunset are answered 410 Gone.
+func Lifecycle(ep EndpointDef, opts LifecycleOptions) gin.HandlerFunc {
+	clock := opts.Clock
+	if clock == nil {
+		clock = SystemClock()
+	}
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+		if ep.Version != "" {
+			h.Set(APIVersionHeader, ep.Version)
+		}
+		if ep.Deprecated != nil {
+			h.Set("Deprecation", "@"+strconv.FormatInt(ep.Deprecated.Unix(), 10))
+		}
+		if ep.Sunset != nil {
+			h.Set("Sunset", ep.Sunset.UTC().Format(http.TimeFormat))
+		}
+		if opts.Successor != "" {
+			h.Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, opts.Successor))
+		}
+		if opts.EnforceSunset && ep.Sunset != nil && !clock.Now().Before(*ep.Sunset) {
+			body := map[string]any{"error": "endpoint " + ep.ID + " was retired on " + ep.Sunset.UTC().Format(http.TimeFormat)}
+			if opts.Successor != "" {
+				body["replacement"] = opts.Successor
+			}
+			c.AbortWithStatusJSON(http.StatusGone, body)
+		}
+	}
+}
+
+// SuccessorURL resolves ep.Replacement: the id of another endpoint becomes
+// that endpoint's path under basePath (or its version's base path); anything
+// else is used as given.
+func (r *Registry) SuccessorURL(ep EndpointDef, basePath string) string {
+	if ep.Replacement == "" {
+		return ""
+	}
+	for _, other := range r.Endpoints {
+		if other.ID != ep.Replacement {
+			continue
+		}
+		base := basePath
+		for _, v := range r.Versions {
+			if v.Name == other.Version && v.BasePath != "" {
+				base = v.BasePath
+			}
+		}
+		return CleanJoin(base, other.Path)
+	}
+	return ep.Replacement
+}
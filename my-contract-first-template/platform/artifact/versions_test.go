@@ -0,0 +1,96 @@
+package artifact
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryRoutes(t *testing.T) {
+	reg := &Registry{
+		Versions:       []APIVersion{{Name: "2024-01"}, {Name: "2025-06", BasePath: "/v2"}},
+		DefaultVersion: "2025-06",
+		Endpoints: []EndpointDef{
+			{ID: "users.list.old", Method: "get", Path: "/users", Version: "2024-01"},
+			{ID: "users.list", Method: "GET", Path: "/users", Version: "2025-06"},
+			{ID: "health", Method: "GET", Path: "/health"},
+			{ID: "feed", Type: EndpointStream, Path: "/feed"},
+		},
+	}
+	routes, err := reg.Routes("/v1")
+	require.NoError(t, err)
+	require.Len(t, routes, 3)
+
+	users := routes[0]
+	require.Equal(t, "GET", users.Method)
+	require.Equal(t, "/v1/users", users.Path)
+	require.True(t, users.Versioned())
+	require.Equal(t, "users.list", users.Endpoints[0].ID, "defaultVersion answers without Accept-Version")
+	ep, err := users.Select("")
+	require.NoError(t, err)
+	require.Equal(t, "users.list", ep.ID)
+	ep, err = users.Select("2024-01")
+	require.NoError(t, err)
+	require.Equal(t, "users.list.old", ep.ID)
+	_, err = users.Select("1999")
+	var stepErr *StepError
+	require.ErrorAs(t, err, &stepErr)
+	require.Equal(t, http.StatusNotAcceptable, stepErr.Status)
+	require.Contains(t, stepErr.Msg, "2025-06, 2024-01")
+
+	require.Equal(t, "/v2/users", routes[1].Path)
+	require.Len(t, routes[1].Endpoints, 1)
+
+	require.Equal(t, "/v1/health", routes[2].Path)
+	require.False(t, routes[2].Versioned())
+	ep, err = routes[2].Select("2024-01")
+	require.NoError(t, err, "unversioned routes ignore the header")
+	require.Equal(t, "health", ep.ID)
+
+	require.Equal(t, "/v2/users", reg.SuccessorURL(EndpointDef{Replacement: "users.list"}, "/v1"))
+	require.Equal(t, "https://example.com/docs", reg.SuccessorURL(EndpointDef{Replacement: "https://example.com/docs"}, "/v1"))
+
+	for _, bad := range []*Registry{
+		{Endpoints: []EndpointDef{{ID: "a", Method: "GET", Path: "/a", Version: "v1"}}},
+		{DefaultVersion: "v1"},
+		{Versions: []APIVersion{{Name: "v1"}, {Name: "v1"}}},
+		{Endpoints: []EndpointDef{{ID: "a", Method: "GET", Path: "/a"}, {ID: "b", Method: "GET", Path: "/a"}}},
+	} {
+		_, err := bad.Routes("/v1")
+		require.Error(t, err, "%+v", bad)
+	}
+}
+
+func TestLifecycleHeadersAndSunset(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	deprecated := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	ep := EndpointDef{ID: "users.list.old", Version: "2024-01", Deprecated: &deprecated, Sunset: &sunset}
+	clock := NewManualClock(sunset.Add(-time.Hour))
+	r := gin.New()
+	r.GET("/users", Lifecycle(ep, LifecycleOptions{Successor: "/v2/users", EnforceSunset: true, Clock: clock}), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+		return w
+	}
+
+	w := get()
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "2024-01", w.Header().Get(APIVersionHeader))
+	require.Equal(t, "@1767225600", w.Header().Get("Deprecation"))
+	require.Equal(t, "Wed, 01 Jul 2026 00:00:00 GMT", w.Header().Get("Sunset"))
+	require.Equal(t, `</v2/users>; rel="successor-version"`, w.Header().Get("Link"))
+
+	clock.Advance(time.Hour)
+	w = get()
+	require.Equal(t, http.StatusGone, w.Code)
+	require.Contains(t, w.Body.String(), `"replacement":"/v2/users"`)
+	require.NotEmpty(t, w.Header().Get("Sunset"))
+}